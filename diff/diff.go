@@ -0,0 +1,196 @@
+// Package diff 计算两个文本之间的差异
+// 先使用Myers算法按行比较，再把修改的行块细化为字符级的范围
+package diff
+
+import (
+	"strings"
+	"time"
+
+	"github.com/example/gotextbuffer/textbuffer"
+)
+
+// Options 描述差异计算的选项
+type Options struct {
+	// Timeout 计算的最长时间，为0表示不限制
+	Timeout time.Duration
+	// MaxComputation 最多计算的步数，为0表示不限制；字符级的细化在每个行块内另有固定的上限
+	// 超出限制时剩余部分会被作为一处整体的差异
+	MaxComputation int
+	// IgnoreCharChanges 是否跳过字符级的细化，只计算行级差异
+	IgnoreCharChanges bool
+}
+
+// CharChange 表示一处字符级的差异
+type CharChange struct {
+	// OriginalRange 原始文本中被修改的范围
+	OriginalRange textbuffer.Range
+	// ModifiedRange 修改后文本中对应的范围
+	ModifiedRange textbuffer.Range
+	// ModifiedText 修改后文本中对应的内容
+	ModifiedText string
+}
+
+// Hunk 表示一个修改的行块
+// 行号从0开始，结束行不包含在行块内；StartLine等于EndLine表示空的行块
+type Hunk struct {
+	// OriginalStartLine 原始文本中行块的起始行
+	OriginalStartLine int
+	// OriginalEndLine 原始文本中行块的结束行（不包含）
+	OriginalEndLine int
+	// ModifiedStartLine 修改后文本中行块的起始行
+	ModifiedStartLine int
+	// ModifiedEndLine 修改后文本中行块的结束行（不包含）
+	ModifiedEndLine int
+	// OriginalRange 原始文本中行块覆盖的范围
+	OriginalRange textbuffer.Range
+	// ModifiedRange 修改后文本中行块覆盖的范围
+	ModifiedRange textbuffer.Range
+	// ModifiedText 修改后文本中行块的内容
+	ModifiedText string
+	// CharChanges 行块内的字符级差异，没有细化时为空
+	CharChanges []CharChange
+}
+
+// Result 表示差异计算的结果
+type Result struct {
+	// Hunks 修改的行块，按行号排序
+	Hunks []Hunk
+	// Quit 计算是否因为超出时间或步数限制而提前结束
+	// 此时结果仍然正确，但不一定是最小的
+	Quit bool
+}
+
+// Identical 判断两个文本是否相同
+func (r *Result) Identical() bool {
+	return len(r.Hunks) == 0
+}
+
+// EditOperations 将差异转换为把原始文本变为修改后文本的编辑
+// 编辑的范围基于原始文本，可以直接传给TextBuffer.ApplyEdits
+func (r *Result) EditOperations() []textbuffer.EditOperation {
+	var operations []textbuffer.EditOperation
+	for _, hunk := range r.Hunks {
+		if len(hunk.CharChanges) == 0 {
+			operations = append(operations, textbuffer.EditOperation{Range: hunk.OriginalRange, Text: hunk.ModifiedText})
+			continue
+		}
+		for _, charChange := range hunk.CharChanges {
+			operations = append(operations, textbuffer.EditOperation{Range: charChange.OriginalRange, Text: charChange.ModifiedText})
+		}
+	}
+	return operations
+}
+
+// ComputeBuffers 计算两个文本缓冲区之间的差异
+func ComputeBuffers(original, modified *textbuffer.TextBuffer, options Options) *Result {
+	return Compute(original.GetLines(), modified.GetLines(), options)
+}
+
+// ComputeSnapshots 计算两个快照之间的差异
+func ComputeSnapshots(original, modified *textbuffer.Snapshot, options Options) *Result {
+	return Compute(original.GetLines(), modified.GetLines(), options)
+}
+
+// ComputeText 计算两个字符串之间的差异
+func ComputeText(original, modified string, options Options) *Result {
+	return ComputeSnapshots(textbuffer.NewSnapshotFromText(original), textbuffer.NewSnapshotFromText(modified), options)
+}
+
+// Compute 计算两组行之间的差异，每一行都应包括它的换行符（最后一行除外）
+func Compute(originalLines, modifiedLines []string, options Options) *Result {
+	l := newLimits(options)
+	lineChanges, ok := computeChanges(originalLines, modifiedLines, l)
+
+	result := &Result{Quit: !ok}
+	for _, lineChange := range lineChanges {
+		hunk := Hunk{
+			OriginalStartLine: lineChange.originalStart,
+			OriginalEndLine:   lineChange.originalEnd,
+			ModifiedStartLine: lineChange.modifiedStart,
+			ModifiedEndLine:   lineChange.modifiedEnd,
+			OriginalRange:     lineRange(lineChange.originalStart, lineChange.originalEnd),
+			ModifiedRange:     lineRange(lineChange.modifiedStart, lineChange.modifiedEnd),
+			ModifiedText:      strings.Join(modifiedLines[lineChange.modifiedStart:lineChange.modifiedEnd], ""),
+		}
+
+		if !options.IgnoreCharChanges {
+			originalText := strings.Join(originalLines[lineChange.originalStart:lineChange.originalEnd], "")
+			var charsOK bool
+			hunk.CharChanges, charsOK = computeCharChanges(originalText, hunk.ModifiedText, lineChange, l)
+			if !charsOK {
+				result.Quit = true
+			}
+		}
+
+		result.Hunks = append(result.Hunks, hunk)
+	}
+
+	return result
+}
+
+// lineRange 获取行块[startLine, endLine)覆盖的范围
+func lineRange(startLine, endLine int) textbuffer.Range {
+	return textbuffer.NewRange(
+		textbuffer.Position{Line: startLine, Column: 0},
+		textbuffer.Position{Line: endLine, Column: 0},
+	)
+}
+
+// maxCharComputation 是细化一个行块时最多计算的步数，即使没有设置MaxComputation，
+// 大范围改写的行块也不会花费过多的时间；超出时行块中剩余的部分作为整体的差异
+const maxCharComputation = 1 << 20
+
+// computeCharChanges 计算一个行块内的字符级差异
+func computeCharChanges(originalText, modifiedText string, lineChange change, l *limits) ([]CharChange, bool) {
+	if originalText == "" || modifiedText == "" {
+		// 纯插入或纯删除的行块不需要细化
+		return nil, true
+	}
+
+	originalRunes := []rune(originalText)
+	modifiedRunes := []rune(modifiedText)
+	hunkLimits := l.child(maxCharComputation)
+	changes, ok := computeChanges(originalRunes, modifiedRunes, hunkLimits)
+	l.finish(hunkLimits, maxCharComputation)
+
+	originalPositions := newPositionMapper(originalRunes, lineChange.originalStart)
+	modifiedPositions := newPositionMapper(modifiedRunes, lineChange.modifiedStart)
+
+	charChanges := make([]CharChange, len(changes))
+	for i, c := range changes {
+		charChanges[i] = CharChange{
+			OriginalRange: textbuffer.NewRange(originalPositions.at(c.originalStart), originalPositions.at(c.originalEnd)),
+			ModifiedRange: textbuffer.NewRange(modifiedPositions.at(c.modifiedStart), modifiedPositions.at(c.modifiedEnd)),
+			ModifiedText:  string(modifiedRunes[c.modifiedStart:c.modifiedEnd]),
+		}
+	}
+	return charChanges, ok
+}
+
+// positionMapper 将行块内的字符偏移量转换为文档中的位置
+type positionMapper struct {
+	// 每一行在行块内的起始偏移量
+	lineStarts []int
+	// 行块的起始行
+	startLine int
+}
+
+// newPositionMapper 创建一个位置转换器
+func newPositionMapper(runes []rune, startLine int) positionMapper {
+	lineStarts := []int{0}
+	for i, ch := range runes {
+		if ch == '\n' {
+			lineStarts = append(lineStarts, i+1)
+		}
+	}
+	return positionMapper{lineStarts: lineStarts, startLine: startLine}
+}
+
+// at 获取行块内偏移量对应的位置
+func (pm positionMapper) at(offset int) textbuffer.Position {
+	line := len(pm.lineStarts) - 1
+	for line > 0 && pm.lineStarts[line] > offset {
+		line--
+	}
+	return textbuffer.Position{Line: pm.startLine + line, Column: offset - pm.lineStarts[line]}
+}
@@ -0,0 +1,151 @@
+package diff
+
+import (
+	"math/rand"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/example/gotextbuffer/textbuffer"
+)
+
+func TestCompute(t *testing.T) {
+	original := textbuffer.NewTextBufferWithText("a\nb\nc\nd\n")
+	modified := textbuffer.NewTextBufferWithText("a\nB\nc\nd\ne\n")
+
+	result := ComputeBuffers(original, modified, Options{})
+	if result.Quit {
+		t.Errorf("Expected computation to finish")
+	}
+	if len(result.Hunks) != 2 {
+		t.Fatalf("Expected 2 hunks, got %+v", result.Hunks)
+	}
+
+	// 修改的行
+	hunk := result.Hunks[0]
+	if hunk.OriginalStartLine != 1 || hunk.OriginalEndLine != 2 || hunk.ModifiedStartLine != 1 || hunk.ModifiedEndLine != 2 {
+		t.Errorf("Unexpected first hunk %+v", hunk)
+	}
+	if len(hunk.CharChanges) != 1 {
+		t.Fatalf("Expected 1 char change, got %+v", hunk.CharChanges)
+	}
+	expectedRange := textbuffer.NewRange(textbuffer.Position{Line: 1, Column: 0}, textbuffer.Position{Line: 1, Column: 1})
+	if hunk.CharChanges[0].OriginalRange != expectedRange || hunk.CharChanges[0].ModifiedText != "B" {
+		t.Errorf("Unexpected char change %+v", hunk.CharChanges[0])
+	}
+
+	// 插入的行
+	hunk = result.Hunks[1]
+	if hunk.OriginalStartLine != 4 || hunk.OriginalEndLine != 4 || hunk.ModifiedStartLine != 4 || hunk.ModifiedEndLine != 5 {
+		t.Errorf("Unexpected second hunk %+v", hunk)
+	}
+	if hunk.ModifiedText != "e\n" || !hunk.OriginalRange.IsEmpty() {
+		t.Errorf("Unexpected second hunk %+v", hunk)
+	}
+
+	// 相同的快照没有差异
+	snapshot := original.CreateSnapshot()
+	if !ComputeSnapshots(snapshot, snapshot, Options{}).Identical() {
+		t.Errorf("Expected identical snapshots")
+	}
+}
+
+func TestComputeEditOperations(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	words := []string{"foo", "bar", "baz", "\n", "\n", " ", "日本"}
+	randomText := func() string {
+		var builder strings.Builder
+		for i := random.Intn(30); i > 0; i-- {
+			builder.WriteString(words[random.Intn(len(words))])
+		}
+		return builder.String()
+	}
+
+	optionSets := []Options{
+		{},
+		{IgnoreCharChanges: true},
+		{MaxComputation: 5},
+	}
+
+	for iteration := 0; iteration < 300; iteration++ {
+		originalText := randomText()
+		modifiedText := randomText()
+
+		for _, options := range optionSets {
+			result := ComputeText(originalText, modifiedText, options)
+
+			// 把差异转换为编辑并应用到原始文本上，必须得到修改后的文本
+			buffer := textbuffer.NewTextBufferWithText(originalText)
+			if err := buffer.ApplyEdits(result.EditOperations(), nil, nil); err != nil {
+				t.Fatalf("ApplyEdits failed: %v", err)
+			}
+			if buffer.GetText() != modifiedText {
+				t.Fatalf("Options %+v: expected '%s', got '%s' (from '%s')", options, modifiedText, buffer.GetText(), originalText)
+			}
+		}
+	}
+}
+
+func TestComputeLimits(t *testing.T) {
+	original := strings.Repeat("a\nb\n", 200)
+	modified := strings.Repeat("b\na\n", 200)
+
+	result := ComputeText(original, modified, Options{MaxComputation: 1, IgnoreCharChanges: true})
+	if !result.Quit {
+		t.Errorf("Expected computation to quit")
+	}
+	if len(result.Hunks) != 1 {
+		t.Errorf("Expected a single hunk when quitting, got %d", len(result.Hunks))
+	}
+
+	result = ComputeText(original, modified, Options{})
+	if result.Quit {
+		t.Errorf("Expected computation to finish without limits")
+	}
+}
+
+func TestComputeLargeRewrite(t *testing.T) {
+	random := rand.New(rand.NewSource(2))
+	randomLines := func(count int) string {
+		var builder strings.Builder
+		for i := 0; i < count; i++ {
+			for j := 0; j < 20; j++ {
+				builder.WriteByte(byte('a' + random.Intn(26)))
+			}
+			builder.WriteByte('\n')
+		}
+		return builder.String()
+	}
+
+	tests := []struct {
+		lines   int
+		options Options
+	}{
+		{300, Options{}},
+		{5000, Options{IgnoreCharChanges: true}},
+	}
+	for _, test := range tests {
+		original, modified := randomLines(test.lines), randomLines(test.lines)
+
+		// 完全改写的文本，内存和时间都不能随编辑距离的平方增长
+		var before, after runtime.MemStats
+		runtime.ReadMemStats(&before)
+		start := time.Now()
+		result := ComputeText(original, modified, test.options)
+		elapsed := time.Since(start)
+		runtime.ReadMemStats(&after)
+
+		if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 64<<20 {
+			t.Errorf("%d lines: allocated %d MB", test.lines, allocated>>20)
+		}
+		if elapsed > 5*time.Second {
+			t.Errorf("%d lines: took %v", test.lines, elapsed)
+		}
+
+		buffer := textbuffer.NewTextBufferWithText(original)
+		if err := buffer.ApplyEdits(result.EditOperations(), nil, nil); err != nil || buffer.GetText() != modified {
+			t.Errorf("%d lines: edits do not produce the modified text (%v)", test.lines, err)
+		}
+	}
+}
@@ -0,0 +1,231 @@
+package diff
+
+import (
+	"time"
+)
+
+// change 表示两个序列之间的一处差异，原始序列的[originalStart, originalEnd)被替换为修改后序列的[modifiedStart, modifiedEnd)
+type change struct {
+	originalStart int
+	originalEnd   int
+	modifiedStart int
+	modifiedEnd   int
+}
+
+// limits 限制差异计算的时间和步数
+type limits struct {
+	// 截止时间，为零值表示不限制
+	deadline time.Time
+	// 剩余的计算步数，小于0表示不限制
+	remaining int
+}
+
+// newLimits 根据选项创建计算限制
+func newLimits(options Options) *limits {
+	l := &limits{remaining: -1}
+	if options.Timeout > 0 {
+		l.deadline = time.Now().Add(options.Timeout)
+	}
+	if options.MaxComputation > 0 {
+		l.remaining = options.MaxComputation
+	}
+	return l
+}
+
+// consume 消耗计算步数，超出限制时返回false
+func (l *limits) consume(steps int) bool {
+	if l.remaining >= 0 {
+		l.remaining -= steps
+		if l.remaining < 0 {
+			return false
+		}
+	}
+	if !l.deadline.IsZero() && time.Now().After(l.deadline) {
+		return false
+	}
+	return true
+}
+
+// child 创建一个最多消耗budget步的子限制，与父限制共享截止时间
+// 子限制消耗的步数在调用finish时计入父限制
+func (l *limits) child(budget int) *limits {
+	if l.remaining >= 0 {
+		budget = min(budget, l.remaining)
+	}
+	return &limits{deadline: l.deadline, remaining: budget}
+}
+
+// finish 把子限制消耗的步数计入父限制
+func (l *limits) finish(child *limits, budget int) {
+	if l.remaining >= 0 {
+		l.remaining = max(0, l.remaining-(min(budget, l.remaining)-max(child.remaining, 0)))
+	}
+}
+
+// computeChanges 使用Myers差异算法计算两个序列之间的差异
+// 超出计算限制时，尚未计算的部分作为整体的差异，并返回false
+func computeChanges[T comparable](original, modified []T, l *limits) ([]change, bool) {
+	// 去掉公共前缀和公共后缀
+	prefix := 0
+	for prefix < len(original) && prefix < len(modified) && original[prefix] == modified[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(original)-prefix && suffix < len(modified)-prefix &&
+		original[len(original)-1-suffix] == modified[len(modified)-1-suffix] {
+		suffix++
+	}
+
+	a := original[prefix : len(original)-suffix]
+	b := modified[prefix : len(modified)-suffix]
+	if len(a) == 0 && len(b) == 0 {
+		return nil, true
+	}
+
+	changes, ok := myers(a, b, l)
+
+	for i := range changes {
+		changes[i].originalStart += prefix
+		changes[i].originalEnd += prefix
+		changes[i].modifiedStart += prefix
+		changes[i].modifiedEnd += prefix
+	}
+	return changes, ok
+}
+
+// myers 使用线性空间的Myers算法计算两个序列之间的最短编辑脚本，并把相邻的插入和删除合并为差异
+// 每次找到编辑路径中间的蛇形（middle snake），再分别递归计算它两边的部分，
+// 空间与序列的长度成正比，而不是与编辑距离的平方成正比
+// 超出计算限制时，尚未计算的部分各自作为一处整体的差异，并返回false
+func myers[T comparable](a, b []T, l *limits) ([]change, bool) {
+	size := len(a) + len(b) + 2
+	m := &myersState[T]{
+		a:        a,
+		b:        b,
+		l:        l,
+		forward:  make([]int, 2*size+1),
+		backward: make([]int, 2*size+1),
+		ok:       true,
+	}
+	m.compare(0, len(a), 0, len(b))
+	return m.changes, m.ok
+}
+
+// myersState 保存一次差异计算的状态，两个方向的对角线数组在各次递归之间复用
+type myersState[T comparable] struct {
+	a, b []T
+	l    *limits
+	// forward[offset+k]和backward[offset+k]保存对角线k上能到达的最远x坐标
+	forward  []int
+	backward []int
+	changes  []change
+	ok       bool
+}
+
+// compare 计算a[aStart:aEnd]和b[bStart:bEnd]之间的差异
+func (m *myersState[T]) compare(aStart, aEnd, bStart, bEnd int) {
+	// 去掉公共前缀和公共后缀
+	for aStart < aEnd && bStart < bEnd && m.a[aStart] == m.b[bStart] {
+		aStart++
+		bStart++
+	}
+	for aStart < aEnd && bStart < bEnd && m.a[aEnd-1] == m.b[bEnd-1] {
+		aEnd--
+		bEnd--
+	}
+
+	switch {
+	case aStart == aEnd && bStart == bEnd:
+		return
+	case aStart == aEnd || bStart == bEnd || !m.ok:
+		// 纯插入、纯删除，或者已经超出计算限制
+		m.add(change{originalStart: aStart, originalEnd: aEnd, modifiedStart: bStart, modifiedEnd: bEnd})
+		return
+	}
+
+	x, y, u, v, ok := m.middleSnake(aStart, aEnd, bStart, bEnd)
+	if !ok {
+		m.ok = false
+		m.add(change{originalStart: aStart, originalEnd: aEnd, modifiedStart: bStart, modifiedEnd: bEnd})
+		return
+	}
+	m.compare(aStart, x, bStart, y)
+	m.compare(u, aEnd, v, bEnd)
+}
+
+// middleSnake 同时从两端搜索，找到最短编辑路径中间的蛇形(x, y)到(u, v)
+// 调用前公共前缀和公共后缀已经去掉，两个序列都不为空
+func (m *myersState[T]) middleSnake(aStart, aEnd, bStart, bEnd int) (x, y, u, v int, ok bool) {
+	n, mm := aEnd-aStart, bEnd-bStart
+	delta := n - mm
+	odd := delta%2 != 0
+	maxD := (n + mm + 1) / 2
+	offset := maxD + 1
+	forward := m.forward[:2*offset+1]
+	backward := m.backward[:2*offset+1]
+	forward[offset+1] = 0
+	backward[offset+1] = 0
+
+	for d := 0; d <= maxD; d++ {
+		if !m.l.consume(d + 1) {
+			return 0, 0, 0, 0, false
+		}
+
+		// 从起点向前搜索
+		for k := -d; k <= d; k += 2 {
+			var px int
+			if k == -d || (k != d && forward[offset+k-1] < forward[offset+k+1]) {
+				px = forward[offset+k+1]
+			} else {
+				px = forward[offset+k-1] + 1
+			}
+			py := px - k
+			sx, sy := px, py
+			for px < n && py < mm && m.a[aStart+px] == m.b[bStart+py] {
+				px++
+				py++
+			}
+			forward[offset+k] = px
+
+			// 与反向搜索的路径重叠
+			if reverse := delta - k; odd && reverse >= -(d-1) && reverse <= d-1 && px+backward[offset+reverse] >= n {
+				return aStart + sx, bStart + sy, aStart + px, bStart + py, true
+			}
+		}
+
+		// 从终点向后搜索，坐标从终点开始计算
+		for k := -d; k <= d; k += 2 {
+			var px int
+			if k == -d || (k != d && backward[offset+k-1] < backward[offset+k+1]) {
+				px = backward[offset+k+1]
+			} else {
+				px = backward[offset+k-1] + 1
+			}
+			py := px - k
+			sx, sy := px, py
+			for px < n && py < mm && m.a[aEnd-1-px] == m.b[bEnd-1-py] {
+				px++
+				py++
+			}
+			backward[offset+k] = px
+
+			if reverse := delta - k; !odd && reverse >= -d && reverse <= d && px+forward[offset+reverse] >= n {
+				return aEnd - px, bEnd - py, aEnd - sx, bEnd - sy, true
+			}
+		}
+	}
+
+	// 两个序列都不为空时总能在maxD步之内找到
+	return 0, 0, 0, 0, false
+}
+
+// add 添加一处差异，与前面相邻的差异合并
+func (m *myersState[T]) add(c change) {
+	if last := len(m.changes) - 1; last >= 0 &&
+		m.changes[last].originalEnd == c.originalStart && m.changes[last].modifiedEnd == c.modifiedStart {
+		m.changes[last].originalEnd = c.originalEnd
+		m.changes[last].modifiedEnd = c.modifiedEnd
+		return
+	}
+	m.changes = append(m.changes, c)
+}
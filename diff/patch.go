@@ -0,0 +1,341 @@
+package diff
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/example/gotextbuffer/textbuffer"
+)
+
+// PatchLine 表示补丁块中的一行
+type PatchLine struct {
+	// Kind 行的类型：' '表示上下文，'-'表示删除，'+'表示插入
+	Kind byte
+	// Text 行的内容，除了文件末尾没有换行符的行之外都包括换行符
+	Text string
+}
+
+// PatchHunk 表示补丁中的一个补丁块
+type PatchHunk struct {
+	// OriginalStart 原始文件中的起始行号（从1开始）
+	OriginalStart int
+	// OriginalCount 原始文件中的行数
+	OriginalCount int
+	// ModifiedStart 修改后文件中的起始行号（从1开始）
+	ModifiedStart int
+	// ModifiedCount 修改后文件中的行数
+	ModifiedCount int
+	// Lines 补丁块的所有行
+	Lines []PatchLine
+}
+
+// originalLines 获取补丁块期望在原始文件中看到的行
+func (h *PatchHunk) originalLines() []string {
+	var lines []string
+	for _, line := range h.Lines {
+		if line.Kind != '+' {
+			lines = append(lines, line.Text)
+		}
+	}
+	return lines
+}
+
+// modifiedText 获取补丁块在修改后文件中的文本
+func (h *PatchHunk) modifiedText() string {
+	var builder strings.Builder
+	for _, line := range h.Lines {
+		if line.Kind != '-' {
+			builder.WriteString(line.Text)
+		}
+	}
+	return builder.String()
+}
+
+// FilePatch 表示对一个文件的补丁
+type FilePatch struct {
+	// OriginalName 原始文件的名称
+	OriginalName string
+	// ModifiedName 修改后文件的名称
+	ModifiedName string
+	// Hunks 补丁块，按行号排序
+	Hunks []PatchHunk
+}
+
+// ParseError 表示解析补丁时的错误
+type ParseError struct {
+	// Line 出错的行号（从1开始）
+	Line int
+	// Reason 错误原因
+	Reason string
+}
+
+// Error 实现error接口
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("patch line %d: %s", e.Line, e.Reason)
+}
+
+// HunkError 表示一个补丁块无法应用的原因
+type HunkError struct {
+	// Hunk 补丁块的索引（从0开始）
+	Hunk int
+	// OriginalStart 补丁块期望的起始行号（从1开始）
+	OriginalStart int
+	// Reason 失败原因
+	Reason string
+}
+
+// Error 实现error接口
+func (e *HunkError) Error() string {
+	return fmt.Sprintf("hunk #%d at line %d: %s", e.Hunk+1, e.OriginalStart, e.Reason)
+}
+
+// ApplyError 表示补丁无法应用，包含所有失败的补丁块
+type ApplyError struct {
+	// Failures 失败的补丁块
+	Failures []HunkError
+}
+
+// Error 实现error接口
+func (e *ApplyError) Error() string {
+	messages := make([]string, len(e.Failures))
+	for i := range e.Failures {
+		messages[i] = e.Failures[i].Error()
+	}
+	return "patch does not apply: " + strings.Join(messages, "; ")
+}
+
+// ParseUnified 解析统一差异格式的文本，返回每个文件的补丁
+// "diff --git"、"index"等不属于统一差异格式的头部行会被忽略
+func ParseUnified(text string) ([]*FilePatch, error) {
+	lines := strings.SplitAfter(text, "\n")
+	var patches []*FilePatch
+	var current *FilePatch
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			current = &FilePatch{
+				OriginalName: parseFileName(line[4:]),
+				ModifiedName: parseFileName(lines[i+1][4:]),
+			}
+			patches = append(patches, current)
+			i++
+		case strings.HasPrefix(line, "@@ "):
+			if current == nil {
+				return nil, &ParseError{Line: i + 1, Reason: "hunk without file header"}
+			}
+			hunk, next, err := parseHunk(lines, i)
+			if err != nil {
+				return nil, err
+			}
+			current.Hunks = append(current.Hunks, *hunk)
+			i = next - 1
+		}
+	}
+
+	if len(patches) == 0 {
+		return nil, &ParseError{Line: 1, Reason: "no file header found"}
+	}
+	return patches, nil
+}
+
+// parseFileName 解析文件头中的文件名，去掉时间戳等附加信息
+func parseFileName(text string) string {
+	text = strings.TrimRight(text, "\r\n")
+	if index := strings.IndexByte(text, '\t'); index >= 0 {
+		text = text[:index]
+	}
+	return text
+}
+
+// parseHunk 解析从start行开始的补丁块，返回补丁块之后的行号
+func parseHunk(lines []string, start int) (*PatchHunk, int, error) {
+	header := strings.TrimRight(lines[start], "\r\n")
+	fields := strings.Fields(header)
+	if len(fields) < 4 || fields[3] != "@@" || !strings.HasPrefix(fields[1], "-") || !strings.HasPrefix(fields[2], "+") {
+		return nil, 0, &ParseError{Line: start + 1, Reason: "malformed hunk header"}
+	}
+
+	hunk := &PatchHunk{}
+	var err error
+	if hunk.OriginalStart, hunk.OriginalCount, err = parseHunkRange(fields[1][1:]); err != nil {
+		return nil, 0, &ParseError{Line: start + 1, Reason: err.Error()}
+	}
+	if hunk.ModifiedStart, hunk.ModifiedCount, err = parseHunkRange(fields[2][1:]); err != nil {
+		return nil, 0, &ParseError{Line: start + 1, Reason: err.Error()}
+	}
+
+	originalRemaining := hunk.OriginalCount
+	modifiedRemaining := hunk.ModifiedCount
+	i := start + 1
+	for ; i < len(lines) && (originalRemaining > 0 || modifiedRemaining > 0); i++ {
+		line := lines[i]
+		if line == "" {
+			break
+		}
+
+		kind := line[0]
+		text := line[1:]
+		if line == "\n" {
+			// 有些工具会去掉空的上下文行前面的空格
+			kind = ' '
+			text = "\n"
+		}
+
+		switch kind {
+		case ' ':
+			originalRemaining--
+			modifiedRemaining--
+		case '-':
+			originalRemaining--
+		case '+':
+			modifiedRemaining--
+		case '\\':
+			continue
+		default:
+			return nil, 0, &ParseError{Line: i + 1, Reason: fmt.Sprintf("unexpected line in hunk: %q", strings.TrimRight(line, "\n"))}
+		}
+		if originalRemaining < 0 || modifiedRemaining < 0 {
+			return nil, 0, &ParseError{Line: i + 1, Reason: "hunk has more lines than its header declares"}
+		}
+
+		hunk.Lines = append(hunk.Lines, PatchLine{Kind: kind, Text: text})
+	}
+
+	if originalRemaining > 0 || modifiedRemaining > 0 {
+		return nil, 0, &ParseError{Line: i + 1, Reason: "hunk is truncated"}
+	}
+
+	// 处理最后一行之后的"没有换行符"标记
+	if i < len(lines) && strings.HasPrefix(lines[i], "\\") {
+		if len(hunk.Lines) == 0 {
+			return nil, 0, &ParseError{Line: i + 1, Reason: "no newline marker without a preceding line"}
+		}
+		last := &hunk.Lines[len(hunk.Lines)-1]
+		last.Text = strings.TrimSuffix(last.Text, "\n")
+		i++
+	}
+
+	// 处理补丁块中间的"没有换行符"标记（同一行先被删除再被插入的情况）
+	for j := start + 1; j < i-1; j++ {
+		if strings.HasPrefix(lines[j+1], "\\") && !strings.HasPrefix(lines[j], "\\") {
+			markNoNewline(hunk, lines, start+1, j)
+		}
+	}
+
+	return hunk, i, nil
+}
+
+// markNoNewline 去掉补丁块中第lineIndex行对应的补丁行的换行符
+func markNoNewline(hunk *PatchHunk, lines []string, first, lineIndex int) {
+	index := 0
+	for j := first; j < lineIndex; j++ {
+		if !strings.HasPrefix(lines[j], "\\") {
+			index++
+		}
+	}
+	if index < len(hunk.Lines) {
+		hunk.Lines[index].Text = strings.TrimSuffix(hunk.Lines[index].Text, "\n")
+	}
+}
+
+// parseHunkRange 解析补丁块头中的"start,count"或"start"
+func parseHunkRange(text string) (int, int, error) {
+	startText, countText, hasCount := strings.Cut(text, ",")
+	start, err := strconv.Atoi(startText)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid line number %q", startText)
+	}
+	count := 1
+	if hasCount {
+		if count, err = strconv.Atoi(countText); err != nil {
+			return 0, 0, fmt.Errorf("invalid line count %q", countText)
+		}
+	}
+	return start, count, nil
+}
+
+// Apply 将补丁作为一个可撤销的操作应用到文本缓冲区
+// 补丁块的上下文与期望的位置不一致时，会在附近查找匹配的位置；
+// 任何补丁块无法应用时不修改文本，并返回包含所有失败原因的*ApplyError
+func Apply(buffer *textbuffer.TextBuffer, patch *FilePatch) error {
+	lines := documentLines(buffer.GetLines())
+
+	var operations []textbuffer.EditOperation
+	var failures []HunkError
+	minLine := 0
+
+	for index := range patch.Hunks {
+		hunk := &patch.Hunks[index]
+		expected := hunk.originalLines()
+
+		// 空范围的起始行号是它之前的行
+		target := hunk.OriginalStart - 1
+		if hunk.OriginalCount == 0 {
+			target = hunk.OriginalStart
+		}
+
+		line, reason := locateHunk(lines, expected, target, minLine)
+		if reason != "" {
+			failures = append(failures, HunkError{Hunk: index, OriginalStart: hunk.OriginalStart, Reason: reason})
+			continue
+		}
+
+		operations = append(operations, textbuffer.EditOperation{
+			Range: textbuffer.NewRange(
+				textbuffer.Position{Line: line, Column: 0},
+				textbuffer.Position{Line: line + len(expected), Column: 0},
+			),
+			Text: hunk.modifiedText(),
+		})
+		minLine = line + len(expected)
+	}
+
+	if len(failures) > 0 {
+		return &ApplyError{Failures: failures}
+	}
+
+	return buffer.ApplyEditsWithOptions(operations, textbuffer.EditOptions{Label: "Apply Patch"})
+}
+
+// locateHunk 查找补丁块在文本中的位置，优先使用期望的行号，然后向两侧扩展查找
+// 找不到时返回失败原因
+func locateHunk(lines, expected []string, target, minLine int) (int, string) {
+	if target < minLine {
+		target = minLine
+	}
+	if target > len(lines) {
+		return 0, fmt.Sprintf("hunk starts beyond the end of the document (%d lines)", len(lines))
+	}
+
+	for distance := 0; ; distance++ {
+		before := target - distance
+		after := target + distance
+		if before < minLine && after+len(expected) > len(lines) {
+			break
+		}
+		if after+len(expected) <= len(lines) && linesMatch(lines[after:], expected) {
+			return after, ""
+		}
+		if distance > 0 && before >= minLine && linesMatch(lines[before:], expected) {
+			return before, ""
+		}
+	}
+
+	return 0, "context does not match"
+}
+
+// linesMatch 判断lines是否以expected开头
+func linesMatch(lines, expected []string) bool {
+	if len(lines) < len(expected) {
+		return false
+	}
+	for i, line := range expected {
+		if lines[i] != line {
+			return false
+		}
+	}
+	return true
+}
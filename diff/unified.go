@@ -0,0 +1,143 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/example/gotextbuffer/textbuffer"
+)
+
+// DefaultContext 是统一差异格式中默认的上下文行数
+const DefaultContext = 3
+
+// noNewlineMarker 表示前一行没有换行符
+const noNewlineMarker = "\\ No newline at end of file"
+
+// UnifiedOptions 描述统一差异格式的生成选项
+type UnifiedOptions struct {
+	// OriginalName 原始文件的名称，显示在"---"行
+	OriginalName string
+	// ModifiedName 修改后文件的名称，显示在"+++"行
+	ModifiedName string
+	// Context 每个修改周围显示的上下文行数
+	Context int
+	// Options 差异计算的选项
+	Options Options
+}
+
+// DefaultUnifiedOptions 获取默认的统一差异格式选项
+func DefaultUnifiedOptions() UnifiedOptions {
+	return UnifiedOptions{
+		OriginalName: "a",
+		ModifiedName: "b",
+		Context:      DefaultContext,
+	}
+}
+
+// UnifiedBuffers 生成两个文本缓冲区之间的统一差异格式文本
+func UnifiedBuffers(original, modified *textbuffer.TextBuffer, options UnifiedOptions) string {
+	return UnifiedLines(original.GetLines(), modified.GetLines(), options)
+}
+
+// UnifiedText 生成两个字符串之间的统一差异格式文本
+func UnifiedText(original, modified string, options UnifiedOptions) string {
+	return UnifiedLines(
+		textbuffer.NewSnapshotFromText(original).GetLines(),
+		textbuffer.NewSnapshotFromText(modified).GetLines(),
+		options,
+	)
+}
+
+// UnifiedLines 生成两组行之间的统一差异格式文本，文本相同时返回空字符串
+func UnifiedLines(originalLines, modifiedLines []string, options UnifiedOptions) string {
+	originalLines = documentLines(originalLines)
+	modifiedLines = documentLines(modifiedLines)
+	diffOptions := options.Options
+	diffOptions.IgnoreCharChanges = true
+	result := Compute(originalLines, modifiedLines, diffOptions)
+	if result.Identical() {
+		return ""
+	}
+
+	context := options.Context
+	if context < 0 {
+		context = 0
+	}
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "--- %s\n", options.OriginalName)
+	fmt.Fprintf(&builder, "+++ %s\n", options.ModifiedName)
+
+	hunks := result.Hunks
+	for len(hunks) > 0 {
+		// 把上下文相互重叠的行块合并为一个补丁块
+		count := 1
+		for count < len(hunks) && hunks[count].OriginalStartLine-hunks[count-1].OriginalEndLine <= 2*context {
+			count++
+		}
+		group := hunks[:count]
+		hunks = hunks[count:]
+
+		originalStart := max(group[0].OriginalStartLine-context, 0)
+		originalEnd := min(group[len(group)-1].OriginalEndLine+context, len(originalLines))
+		modifiedStart := group[0].ModifiedStartLine - (group[0].OriginalStartLine - originalStart)
+		modifiedEnd := group[len(group)-1].ModifiedEndLine + (originalEnd - group[len(group)-1].OriginalEndLine)
+
+		fmt.Fprintf(&builder, "@@ -%s +%s @@\n",
+			formatHunkRange(originalStart, originalEnd-originalStart),
+			formatHunkRange(modifiedStart, modifiedEnd-modifiedStart))
+
+		line := originalStart
+		for _, hunk := range group {
+			for ; line < hunk.OriginalStartLine; line++ {
+				writePatchLine(&builder, ' ', originalLines[line])
+			}
+			for _, text := range originalLines[hunk.OriginalStartLine:hunk.OriginalEndLine] {
+				writePatchLine(&builder, '-', text)
+			}
+			for _, text := range modifiedLines[hunk.ModifiedStartLine:hunk.ModifiedEndLine] {
+				writePatchLine(&builder, '+', text)
+			}
+			line = hunk.OriginalEndLine
+		}
+		for ; line < originalEnd; line++ {
+			writePatchLine(&builder, ' ', originalLines[line])
+		}
+	}
+
+	return builder.String()
+}
+
+// documentLines 将空文本的行列表（只有一个空行）转换为没有行，
+// 这样空文本在补丁中不会表现为一个没有换行符的空行
+func documentLines(lines []string) []string {
+	if len(lines) == 1 && lines[0] == "" {
+		return nil
+	}
+	return lines
+}
+
+// formatHunkRange 格式化补丁块头中的行范围，行号从1开始
+// 空范围使用它之前的行号，只有一行时省略行数
+func formatHunkRange(start, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+// writePatchLine 写入补丁中的一行，没有换行符的行后面跟随标记行
+func writePatchLine(builder *strings.Builder, kind byte, text string) {
+	builder.WriteByte(kind)
+	if strings.HasSuffix(text, "\n") {
+		builder.WriteString(text)
+		return
+	}
+	builder.WriteString(text)
+	builder.WriteString("\n")
+	builder.WriteString(noNewlineMarker)
+	builder.WriteString("\n")
+}
@@ -0,0 +1,156 @@
+package diff
+
+import (
+	"errors"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/example/gotextbuffer/textbuffer"
+)
+
+func TestUnified(t *testing.T) {
+	original := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"
+	modified := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk"
+
+	options := DefaultUnifiedOptions()
+	options.Context = 1
+	expected := "--- a\n+++ b\n" +
+		"@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n" +
+		"@@ -10 +10,2 @@\n j\n+k\n\\ No newline at end of file\n"
+	if text := UnifiedText(original, modified, options); text != expected {
+		t.Errorf("Expected:\n%s\ngot:\n%s", expected, text)
+	}
+
+	// 上下文重叠的修改合并为一个补丁块
+	options.Context = 4
+	text := UnifiedText(original, modified, options)
+	if strings.Count(text, "@@ -") != 1 || !strings.Contains(text, "@@ -1,10 +1,11 @@\n") {
+		t.Errorf("Expected a single merged hunk, got:\n%s", text)
+	}
+
+	// 纯插入的补丁块使用之前的行号
+	options.Context = 0
+	text = UnifiedText("a\n", "x\na\n", options)
+	if !strings.Contains(text, "@@ -0,0 +1 @@\n+x\n") {
+		t.Errorf("Unexpected insertion hunk:\n%s", text)
+	}
+
+	if UnifiedText(original, original, options) != "" {
+		t.Errorf("Expected empty diff for identical text")
+	}
+}
+
+func TestParseUnified(t *testing.T) {
+	text := "diff --git a/x b/x\nindex 123..456 100644\n" +
+		"--- a/x\t2024-01-01\n+++ b/x\n" +
+		"@@ -1,2 +1,2 @@\n-a\n\\ No newline at end of file\n+A\n b\n"
+	patches, err := ParseUnified(text)
+	if err != nil {
+		t.Fatalf("ParseUnified failed: %v", err)
+	}
+	if len(patches) != 1 || patches[0].OriginalName != "a/x" || patches[0].ModifiedName != "b/x" {
+		t.Fatalf("Unexpected patches %+v", patches)
+	}
+	hunk := patches[0].Hunks[0]
+	if hunk.OriginalStart != 1 || hunk.OriginalCount != 2 || len(hunk.Lines) != 3 || hunk.Lines[0].Text != "a" {
+		t.Errorf("Unexpected hunk %+v", hunk)
+	}
+
+	var parseErr *ParseError
+	if _, err := ParseUnified("--- a\n+++ b\n@@ -1,2 +1 @@\n-a\n"); !errors.As(err, &parseErr) {
+		t.Errorf("Expected ParseError for truncated hunk, got %v", err)
+	}
+	if _, err := ParseUnified("--- a\n+++ b\n@@ -x +1 @@\n"); !errors.As(err, &parseErr) || parseErr.Line != 3 {
+		t.Errorf("Expected ParseError at line 3, got %v", err)
+	}
+	if _, err := ParseUnified("--- a\n+++ b\n@@ -0,0 +0,0 @@\n\\ No newline at end of file\n"); !errors.As(err, &parseErr) || parseErr.Line != 4 {
+		t.Errorf("Expected ParseError at line 4 for marker after empty hunk, got %v", err)
+	}
+}
+
+func TestApply(t *testing.T) {
+	original := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\n"
+	modified := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk"
+	patches, err := ParseUnified(UnifiedText(original, modified, DefaultUnifiedOptions()))
+	if err != nil {
+		t.Fatalf("ParseUnified failed: %v", err)
+	}
+
+	// 补丁应用为一个可撤销的操作
+	buffer := textbuffer.NewTextBufferWithText(original)
+	if err := Apply(buffer, patches[0]); err != nil {
+		t.Fatalf("Apply failed: %v", err)
+	}
+	if buffer.GetText() != modified {
+		t.Errorf("Expected '%s', got '%s'", modified, buffer.GetText())
+	}
+	if buffer.UndoLabel() != "Apply Patch" {
+		t.Errorf("Expected undo label 'Apply Patch', got '%s'", buffer.UndoLabel())
+	}
+	if _, err := buffer.Undo(); err != nil || buffer.GetText() != original {
+		t.Errorf("Expected undo to restore the original text, got '%s' (%v)", buffer.GetText(), err)
+	}
+
+	// 文本开头插入了行，补丁块会在附近找到匹配的位置
+	buffer = textbuffer.NewTextBufferWithText("x\ny\n" + original)
+	if err := Apply(buffer, patches[0]); err != nil {
+		t.Fatalf("Apply with offset failed: %v", err)
+	}
+	if buffer.GetText() != "x\ny\n"+modified {
+		t.Errorf("Unexpected text after applying with offset: '%s'", buffer.GetText())
+	}
+
+	// 上下文不匹配时不修改文本，并报告失败的补丁块
+	options := DefaultUnifiedOptions()
+	options.Context = 1
+	patches, _ = ParseUnified(UnifiedText(original, modified, options))
+	buffer = textbuffer.NewTextBufferWithText("a\nb\nc\nd\ne\nf\ng\nh\ni\nJ\n")
+	err = Apply(buffer, patches[0])
+	var applyErr *ApplyError
+	if !errors.As(err, &applyErr) || len(applyErr.Failures) != 1 || applyErr.Failures[0].Hunk != 1 {
+		t.Fatalf("Expected failure of the second hunk, got %v", err)
+	}
+	if buffer.GetText() != "a\nb\nc\nd\ne\nf\ng\nh\ni\nJ\n" || buffer.CanUndo() {
+		t.Errorf("Expected the buffer to be unchanged")
+	}
+}
+
+func TestUnifiedRoundTrip(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	words := []string{"foo", "bar", "\n", "\n", "\n", " "}
+	randomText := func() string {
+		var builder strings.Builder
+		for i := random.Intn(40); i > 0; i-- {
+			builder.WriteString(words[random.Intn(len(words))])
+		}
+		return builder.String()
+	}
+
+	for iteration := 0; iteration < 300; iteration++ {
+		originalText := randomText()
+		modifiedText := randomText()
+		options := DefaultUnifiedOptions()
+		options.Context = random.Intn(4)
+
+		text := UnifiedText(originalText, modifiedText, options)
+		if text == "" {
+			if originalText != modifiedText {
+				t.Fatalf("Expected a diff between '%s' and '%s'", originalText, modifiedText)
+			}
+			continue
+		}
+
+		patches, err := ParseUnified(text)
+		if err != nil {
+			t.Fatalf("ParseUnified failed: %v\n%s", err, text)
+		}
+		buffer := textbuffer.NewTextBufferWithText(originalText)
+		if err := Apply(buffer, patches[0]); err != nil {
+			t.Fatalf("Apply failed: %v\n%s", err, text)
+		}
+		if buffer.GetText() != modifiedText {
+			t.Fatalf("Expected '%q', got '%q' from patch:\n%s", modifiedText, buffer.GetText(), text)
+		}
+	}
+}
@@ -0,0 +1,378 @@
+// Package filewatch 监视文本缓冲区关联的文件，检测其他程序对文件的修改
+//
+// 在Linux上使用inotify监视文件所在的目录，其他平台或inotify不可用时定期检查文件的
+// 修改时间、大小和内容的哈希值。检测到修改后根据策略重新加载文本或通知调用方；
+// 重新加载时只把差异作为一个可撤销的操作应用，跟踪的选区和撤销历史都会保留
+package filewatch
+
+import (
+	"crypto/sha256"
+	"errors"
+	"io/fs"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/example/gotextbuffer/diff"
+	"github.com/example/gotextbuffer/textbuffer"
+)
+
+// ErrNoFile 表示文本缓冲区没有关联的文件
+var ErrNoFile = errors.New("buffer has no associated file")
+
+// Policy 表示检测到外部修改时的处理策略
+type Policy int
+
+const (
+	// PolicyReloadIfClean 文本没有被修改时自动重新加载，否则通知调用方，这是默认的策略
+	PolicyReloadIfClean Policy = iota
+	// PolicyNotify 总是只通知调用方，由调用方决定是否调用Reload
+	PolicyNotify
+	// PolicyKeepMine 保留文本缓冲区的内容，忽略外部修改
+	PolicyKeepMine
+)
+
+// EventKind 表示外部修改的类型
+type EventKind int
+
+const (
+	// EventModified 文件的内容被修改
+	EventModified EventKind = iota
+	// EventDeleted 文件被删除或移走
+	EventDeleted
+	// EventError 检查或重新加载文件时出错
+	EventError
+)
+
+// Action 表示对外部修改采取的处理
+type Action int
+
+const (
+	// ActionNotified 没有重新加载，需要调用方处理
+	ActionNotified Action = iota
+	// ActionReloaded 已经重新加载了文本
+	ActionReloaded
+	// ActionIgnored 根据策略忽略了修改
+	ActionIgnored
+)
+
+// Event 描述一次检测到的外部修改
+type Event struct {
+	// Kind 修改的类型
+	Kind EventKind
+	// Path 被修改的文件
+	Path string
+	// Action 采取的处理
+	Action Action
+	// Err 出错时的错误
+	Err error
+}
+
+// defaultPollInterval 是轮询文件状态的默认间隔
+const defaultPollInterval = time.Second
+
+// defaultDebounce 是处理修改之前等待的默认时间，用于合并一次保存产生的多个通知
+const defaultDebounce = 50 * time.Millisecond
+
+// Options 是监视文件的选项
+type Options struct {
+	// Policy 检测到外部修改时的处理策略
+	Policy Policy
+	// OnChange 检测到外部修改后调用，在监视的协程中执行
+	OnChange func(Event)
+	// ForcePolling 是否总是使用轮询，不使用inotify
+	ForcePolling bool
+	// PollInterval 轮询的间隔，为0时使用默认值
+	PollInterval time.Duration
+	// Debounce 收到通知后等待的时间，为0时使用默认值
+	Debounce time.Duration
+}
+
+// backend 在文件可能被修改时发出信号，具体的检查由Watcher完成
+type backend interface {
+	// signals 返回文件可能被修改时发出信号的通道
+	signals() <-chan struct{}
+	// close 停止监视
+	close() error
+}
+
+// Watcher 监视一个文本缓冲区关联的文件
+// 文本被保存到其他文件后改为监视新的文件
+type Watcher struct {
+	buffer  *textbuffer.TextBuffer
+	options Options
+	done    chan struct{}
+	once    sync.Once
+	wg      sync.WaitGroup
+	// 关联的文件变化时发送新的路径
+	paths          chan string
+	removeListener func()
+
+	// 以下字段只在监视的协程中访问
+	path    string
+	backend backend
+	// 文件是否已经被报告为删除，避免重复通知
+	deleted bool
+	// 最近一次通知调用方而没有重新加载的文件内容的哈希值，同一个修改只通知一次
+	notified *[sha256.Size]byte
+}
+
+// Watch 开始监视文本缓冲区关联的文件，使用完毕后需要调用Close
+func Watch(buffer *textbuffer.TextBuffer, options Options) (*Watcher, error) {
+	path := buffer.GetFilePath()
+	if path == "" {
+		return nil, ErrNoFile
+	}
+	if options.PollInterval <= 0 {
+		options.PollInterval = defaultPollInterval
+	}
+	if options.Debounce <= 0 {
+		options.Debounce = defaultDebounce
+	}
+
+	w := &Watcher{
+		buffer:  buffer,
+		options: options,
+		done:    make(chan struct{}),
+		paths:   make(chan string, 1),
+		path:    path,
+		backend: newBackend(path, options),
+	}
+	w.removeListener = buffer.OnSaved(w.saved)
+	w.wg.Add(1)
+	go w.run()
+	return w, nil
+}
+
+// newBackend 创建监视文件的后端，inotify不可用时使用轮询
+func newBackend(path string, options Options) backend {
+	if !options.ForcePolling {
+		if b, err := newNotifyBackend(path); err == nil {
+			return b
+		}
+	}
+	return newPollBackend(path, options.PollInterval)
+}
+
+// Close 停止监视，等待正在进行的处理完成
+func (w *Watcher) Close() error {
+	var err error
+	w.once.Do(func() {
+		w.removeListener()
+		close(w.done)
+		w.wg.Wait()
+		err = w.backend.close()
+	})
+	return err
+}
+
+// saved 在文本与文件一致之后把关联的文件路径交给监视的协程，只保留最新的路径
+func (w *Watcher) saved(event textbuffer.SavedEvent) {
+	for {
+		select {
+		case w.paths <- event.Path:
+			return
+		default:
+		}
+		select {
+		case <-w.paths:
+		default:
+		}
+	}
+}
+
+// follow 在文本与文件一致之后调用，之后的修改需要重新通知；路径变化时改为监视新的文件
+func (w *Watcher) follow(path string) {
+	w.notified = nil
+	if path == w.path {
+		return
+	}
+	w.backend.close()
+	w.path = path
+	w.backend = newBackend(path, w.options)
+	w.deleted = false
+
+	// 开始监视之前文件可能已经被修改
+	w.check()
+}
+
+// run 等待信号并处理外部修改
+func (w *Watcher) run() {
+	defer w.wg.Done()
+
+	signals := w.backend.signals()
+	for {
+		select {
+		case <-w.done:
+			return
+		case path := <-w.paths:
+			w.follow(path)
+			signals = w.backend.signals()
+			continue
+		case _, ok := <-signals:
+			if !ok {
+				return
+			}
+		}
+
+		// 等待一段时间，合并一次保存产生的多个信号
+		timer := time.NewTimer(w.options.Debounce)
+	debounce:
+		for {
+			select {
+			case <-w.done:
+				timer.Stop()
+				return
+			case <-signals:
+			case <-timer.C:
+				break debounce
+			}
+		}
+
+		w.check()
+	}
+}
+
+// check 检查文件是否被外部修改，并根据策略处理
+func (w *Watcher) check() {
+	changed, err := w.buffer.HasExternalChanges()
+	if err != nil {
+		w.emit(Event{Kind: EventError, Err: err})
+		return
+	}
+	if !changed {
+		return
+	}
+
+	content, err := readFile(w.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		if !w.deleted {
+			w.deleted = true
+			w.emit(Event{Kind: EventDeleted, Action: ActionNotified})
+		}
+		return
+	}
+	if err != nil {
+		w.emit(Event{Kind: EventError, Err: err})
+		return
+	}
+	w.deleted = false
+
+	// 判断文本是否被修改和重新加载之间文本可能被编辑，此时重新判断
+	for attempt := 1; ; attempt++ {
+		snapshot := w.buffer.CreateSnapshot()
+		dirty := w.buffer.IsDirty()
+
+		switch {
+		case snapshot.GetText() == content.text:
+			// 内容没有变化，例如自己保存的文件或只修改了时间，只记录文件的状态
+			err = reload(w.buffer, snapshot, content)
+			if err == nil {
+				return
+			}
+		case w.options.Policy == PolicyKeepMine:
+			w.emit(Event{Kind: EventModified, Action: ActionIgnored})
+			return
+		case w.options.Policy == PolicyNotify || dirty:
+			w.notify(content)
+			return
+		default:
+			err = reload(w.buffer, snapshot, content)
+			if err == nil {
+				w.emit(Event{Kind: EventModified, Action: ActionReloaded})
+				return
+			}
+		}
+
+		if !errors.Is(err, textbuffer.ErrVersionChanged) {
+			w.emit(Event{Kind: EventError, Err: err})
+			return
+		}
+		if attempt == maxReloadAttempts {
+			// 文本一直在被编辑，由调用方决定如何处理
+			w.notify(content)
+			return
+		}
+	}
+}
+
+// notify 通知调用方文件被修改，同一个内容已经通知过时不再通知
+func (w *Watcher) notify(content *fileContent) {
+	hash := sha256.Sum256([]byte(content.text))
+	if w.notified != nil && *w.notified == hash {
+		return
+	}
+	w.notified = &hash
+	w.emit(Event{Kind: EventModified, Action: ActionNotified})
+}
+
+// emit 通知调用方
+func (w *Watcher) emit(event Event) {
+	event.Path = w.path
+	if w.options.OnChange != nil {
+		w.options.OnChange(event)
+	}
+}
+
+// reloadDiffOptions 限制重新加载时计算差异的时间和步数，超出限制时差异不是最小的，但结果仍然正确
+var reloadDiffOptions = diff.Options{Timeout: time.Second, MaxComputation: 1 << 24}
+
+// maxReloadAttempts 是重新加载时文本被并发编辑后最多尝试的次数
+const maxReloadAttempts = 3
+
+// fileContent 是一次读取的文件内容
+type fileContent struct {
+	// path 文件的路径
+	path string
+	// info 读取内容之前获取的文件状态，读取期间的修改之后仍然可以被检测到
+	info fs.FileInfo
+	// text 解码后的文本
+	text string
+	// encoding 检测到的编码
+	encoding textbuffer.FileEncoding
+}
+
+// readFile 先获取文件状态，再读取并解码文件内容
+func readFile(path string) (*fileContent, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	encoding := textbuffer.DetectEncoding(data)
+	return &fileContent{path: path, info: info, text: textbuffer.DecodeText(data, encoding), encoding: encoding}, nil
+}
+
+// Reload 从关联的文件重新加载文本，丢弃文本缓冲区中未保存的修改
+// 只把文件内容与当前文本的差异作为一个可撤销的操作应用，跟踪的选区和撤销历史都会保留，
+// 重新加载之后文本缓冲区是未修改的。计算差异期间文本被编辑时重新计算，
+// 一直被编辑时返回textbuffer.ErrVersionChanged
+func Reload(buffer *textbuffer.TextBuffer) error {
+	path := buffer.GetFilePath()
+	if path == "" {
+		return ErrNoFile
+	}
+	content, err := readFile(path)
+	if err != nil {
+		return err
+	}
+
+	for attempt := 0; attempt < maxReloadAttempts; attempt++ {
+		if err = reload(buffer, buffer.CreateSnapshot(), content); !errors.Is(err, textbuffer.ErrVersionChanged) {
+			return err
+		}
+	}
+	return err
+}
+
+// reload 把文件内容与快照的差异应用到文本缓冲区，快照之后文本被编辑时返回textbuffer.ErrVersionChanged
+func reload(buffer *textbuffer.TextBuffer, snapshot *textbuffer.Snapshot, content *fileContent) error {
+	var operations []textbuffer.EditOperation
+	if result := diff.ComputeText(snapshot.GetText(), content.text, reloadDiffOptions); !result.Identical() {
+		operations = result.EditOperations()
+	}
+	return buffer.ReloadAtVersion(snapshot.VersionID(), operations, content.encoding, content.path, content.info)
+}
@@ -0,0 +1,232 @@
+package filewatch
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/example/gotextbuffer/textbuffer"
+)
+
+// openTemp 创建一个临时文件并打开为文本缓冲区
+func openTemp(t *testing.T, text string) (*textbuffer.TextBuffer, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "file.txt")
+	if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	buffer, err := textbuffer.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buffer, path
+}
+
+// writeExternal 模拟其他程序修改文件，保证修改时间发生变化
+func writeExternal(t *testing.T, path, text string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(path, later, later)
+}
+
+// waitEvent 等待一个事件
+func waitEvent(t *testing.T, events <-chan Event) Event {
+	t.Helper()
+	select {
+	case event := <-events:
+		return event
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for an event")
+		return Event{}
+	}
+}
+
+func TestReloadKeepsSelectionsAndHistory(t *testing.T) {
+	buffer, path := openTemp(t, "one\ntwo\nthree\n")
+	buffer.SetSelections([]textbuffer.Selection{textbuffer.NewSelection(textbuffer.Position{Line: 2, Column: 3}, textbuffer.Position{Line: 2, Column: 3})})
+
+	writeExternal(t, path, "zero\none\ntwo\nthree\n")
+	if err := Reload(buffer); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+	if buffer.GetText() != "zero\none\ntwo\nthree\n" || buffer.IsDirty() {
+		t.Errorf("Unexpected text %q after reload", buffer.GetText())
+	}
+	if changed, _ := buffer.HasExternalChanges(); changed {
+		t.Errorf("Expected no external changes after reload")
+	}
+
+	// 插入的行之后的光标随之移动
+	selections := buffer.GetSelections()
+	if len(selections) != 1 || selections[0].Active != (textbuffer.Position{Line: 3, Column: 3}) {
+		t.Errorf("Unexpected selections %+v", selections)
+	}
+
+	// 重新加载可以撤销
+	buffer.Undo()
+	if buffer.GetText() != "one\ntwo\nthree\n" || !buffer.IsDirty() {
+		t.Errorf("Unexpected text %q after undoing the reload", buffer.GetText())
+	}
+}
+
+func TestWatchPolicies(t *testing.T) {
+	for _, forcePolling := range []bool{false, true} {
+		events := make(chan Event, 10)
+		buffer, path := openTemp(t, "text\n")
+		watcher, err := Watch(buffer, Options{
+			OnChange:     func(event Event) { events <- event },
+			ForcePolling: forcePolling,
+			PollInterval: 10 * time.Millisecond,
+			Debounce:     10 * time.Millisecond,
+		})
+		if err != nil {
+			t.Fatalf("Watch failed: %v", err)
+		}
+
+		// 未修改的文本自动重新加载
+		writeExternal(t, path, "changed\n")
+		if event := waitEvent(t, events); event.Kind != EventModified || event.Action != ActionReloaded {
+			t.Errorf("Polling %v: unexpected event %+v", forcePolling, event)
+		}
+		if buffer.GetText() != "changed\n" {
+			t.Errorf("Polling %v: expected the buffer to be reloaded, got %q", forcePolling, buffer.GetText())
+		}
+
+		// 修改过的文本只通知
+		buffer.Insert(textbuffer.Position{Line: 0, Column: 0}, "mine ")
+		writeExternal(t, path, "theirs\n")
+		if event := waitEvent(t, events); event.Action != ActionNotified {
+			t.Errorf("Polling %v: unexpected event %+v", forcePolling, event)
+		}
+		if buffer.GetText() != "mine changed\n" {
+			t.Errorf("Polling %v: expected the buffer to be kept, got %q", forcePolling, buffer.GetText())
+		}
+
+		// 自己保存的文件不产生事件
+		if err := buffer.Save(); err != nil {
+			t.Fatal(err)
+		}
+		os.Remove(path)
+		if event := waitEvent(t, events); event.Kind != EventDeleted {
+			t.Errorf("Polling %v: unexpected event %+v", forcePolling, event)
+		}
+
+		watcher.Close()
+		watcher.Close()
+	}
+}
+
+func TestWatchKeepMine(t *testing.T) {
+	events := make(chan Event, 10)
+	buffer, path := openTemp(t, "text\n")
+	watcher, err := Watch(buffer, Options{
+		Policy:       PolicyKeepMine,
+		OnChange:     func(event Event) { events <- event },
+		ForcePolling: true,
+		PollInterval: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Watch failed: %v", err)
+	}
+	defer watcher.Close()
+
+	writeExternal(t, path, "theirs\n")
+	if event := waitEvent(t, events); event.Action != ActionIgnored {
+		t.Errorf("Unexpected event %+v", event)
+	}
+	if buffer.GetText() != "text\n" {
+		t.Errorf("Expected the buffer to be kept, got %q", buffer.GetText())
+	}
+
+	if _, err := Watch(textbuffer.NewTextBuffer(), Options{}); err != ErrNoFile {
+		t.Errorf("Expected ErrNoFile, got %v", err)
+	}
+}
+
+func TestCheckRecordsUnchangedContent(t *testing.T) {
+	buffer, path := openTemp(t, "text\n")
+	w := &Watcher{buffer: buffer, path: path}
+
+	// 只修改了时间的文件不产生事件，并记录新的文件状态
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(path, later, later)
+	w.check()
+	if changed, _ := buffer.HasExternalChanges(); changed {
+		t.Errorf("Expected the file info to be updated after a touch")
+	}
+	if buffer.GetText() != "text\n" || buffer.CanUndo() {
+		t.Errorf("Expected the buffer to be unchanged, got %q", buffer.GetText())
+	}
+}
+
+func TestReloadRejectsStaleVersion(t *testing.T) {
+	buffer, path := openTemp(t, "one\n")
+	writeExternal(t, path, "two\n")
+	content, err := readFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 快照之后的编辑不会被重新加载覆盖
+	snapshot := buffer.CreateSnapshot()
+	buffer.Insert(textbuffer.Position{Line: 0, Column: 0}, "my ")
+	if err := reload(buffer, snapshot, content); !errors.Is(err, textbuffer.ErrVersionChanged) {
+		t.Errorf("Expected ErrVersionChanged, got %v", err)
+	}
+	if buffer.GetText() != "my one\n" || !buffer.IsDirty() {
+		t.Errorf("Expected the edit to be kept, got %q", buffer.GetText())
+	}
+
+	// 使用新的快照重新加载
+	if err := Reload(buffer); err != nil || buffer.GetText() != "two\n" || buffer.IsDirty() {
+		t.Errorf("Unexpected reload result %q (%v)", buffer.GetText(), err)
+	}
+}
+
+func TestWatchFollowsSaveTo(t *testing.T) {
+	for _, forcePolling := range []bool{false, true} {
+		events := make(chan Event, 10)
+		buffer, path := openTemp(t, "text\n")
+		watcher, err := Watch(buffer, Options{
+			Policy:       PolicyNotify,
+			OnChange:     func(event Event) { events <- event },
+			ForcePolling: forcePolling,
+			PollInterval: 10 * time.Millisecond,
+			Debounce:     10 * time.Millisecond,
+		})
+		if err != nil {
+			t.Fatalf("Watch failed: %v", err)
+		}
+
+		// 另存为之后监视新的文件
+		other := filepath.Join(filepath.Dir(path), "other.txt")
+		if err := buffer.SaveTo(other); err != nil {
+			t.Fatal(err)
+		}
+		writeExternal(t, other, "theirs\n")
+		if event := waitEvent(t, events); event.Path != other || event.Action != ActionNotified {
+			t.Errorf("Polling %v: unexpected event %+v", forcePolling, event)
+		}
+
+		// 同一个修改只通知一次
+		later := time.Now().Add(2 * time.Minute)
+		os.Chtimes(other, later, later)
+		writeExternal(t, path, "old file\n")
+		select {
+		case event := <-events:
+			t.Errorf("Polling %v: unexpected event %+v", forcePolling, event)
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		writeExternal(t, other, "again\n")
+		if event := waitEvent(t, events); event.Path != other || event.Action != ActionNotified {
+			t.Errorf("Polling %v: unexpected event %+v", forcePolling, event)
+		}
+		watcher.Close()
+	}
+}
@@ -0,0 +1,88 @@
+//go:build linux
+
+package filewatch
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"syscall"
+	"unsafe"
+)
+
+// inotifyMask 是监视目录时关心的事件
+// 监视目录而不是文件本身，这样通过重命名原子地替换文件时也能收到通知
+const inotifyMask = syscall.IN_CLOSE_WRITE | syscall.IN_MODIFY | syscall.IN_MOVED_TO |
+	syscall.IN_MOVED_FROM | syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_ATTRIB
+
+// inotifyBackend 使用inotify监视文件所在的目录
+type inotifyBackend struct {
+	file      *os.File
+	name      string
+	signalsCh chan struct{}
+}
+
+// newNotifyBackend 创建一个inotify监视，路径是符号链接时监视链接指向的文件
+func newNotifyBackend(path string) (backend, error) {
+	if target, err := filepath.EvalSymlinks(path); err == nil {
+		path = target
+	}
+
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, os.NewSyscallError("inotify_init1", err)
+	}
+	if _, err := syscall.InotifyAddWatch(fd, filepath.Dir(path), inotifyMask); err != nil {
+		syscall.Close(fd)
+		return nil, os.NewSyscallError("inotify_add_watch", err)
+	}
+
+	// 非阻塞的文件描述符由运行时轮询，关闭文件会使阻塞的读取返回
+	b := &inotifyBackend{
+		file:      os.NewFile(uintptr(fd), "inotify"),
+		name:      filepath.Base(path),
+		signalsCh: make(chan struct{}, 1),
+	}
+	go b.run()
+	return b, nil
+}
+
+// run 读取inotify事件，与文件相关时发出信号
+func (b *inotifyBackend) run() {
+	defer close(b.signalsCh)
+
+	buffer := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := b.file.Read(buffer)
+		if err != nil {
+			return
+		}
+
+		relevant := false
+		for offset := 0; offset+syscall.SizeofInotifyEvent <= n; {
+			event := (*syscall.InotifyEvent)(unsafe.Pointer(&buffer[offset]))
+			nameStart := offset + syscall.SizeofInotifyEvent
+			name := buffer[nameStart : nameStart+int(event.Len)]
+			name = bytes.TrimRight(name, "\x00")
+			if string(name) == b.name || event.Mask&syscall.IN_Q_OVERFLOW != 0 {
+				relevant = true
+			}
+			offset = nameStart + int(event.Len)
+		}
+
+		if relevant {
+			select {
+			case b.signalsCh <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+func (b *inotifyBackend) signals() <-chan struct{} {
+	return b.signalsCh
+}
+
+func (b *inotifyBackend) close() error {
+	return b.file.Close()
+}
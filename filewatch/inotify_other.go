@@ -0,0 +1,10 @@
+//go:build !linux
+
+package filewatch
+
+import "errors"
+
+// newNotifyBackend 在没有inotify的平台上不可用，总是使用轮询
+func newNotifyBackend(path string) (backend, error) {
+	return nil, errors.New("inotify is not supported on this platform")
+}
@@ -0,0 +1,89 @@
+package filewatch
+
+import (
+	"crypto/sha256"
+	"os"
+	"sync"
+	"time"
+)
+
+// fileState 是轮询时比较的文件状态
+type fileState struct {
+	exists  bool
+	modTime time.Time
+	size    int64
+	hash    [sha256.Size]byte
+}
+
+// pollBackend 定期检查文件的修改时间和大小，变化时再比较内容的哈希值，
+// 只修改了时间而内容不变时不发出信号
+type pollBackend struct {
+	path      string
+	signalsCh chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+	state     fileState
+}
+
+// newPollBackend 创建一个轮询的监视
+func newPollBackend(path string, interval time.Duration) *pollBackend {
+	p := &pollBackend{
+		path:      path,
+		signalsCh: make(chan struct{}, 1),
+		done:      make(chan struct{}),
+	}
+	p.state = p.stat(fileState{})
+	go p.run(interval)
+	return p
+}
+
+// stat 获取文件的当前状态，修改时间和大小都没有变化时沿用之前的哈希值
+func (p *pollBackend) stat(previous fileState) fileState {
+	info, err := os.Stat(p.path)
+	if err != nil {
+		return fileState{}
+	}
+
+	state := fileState{exists: true, modTime: info.ModTime(), size: info.Size()}
+	if previous.exists && state.modTime.Equal(previous.modTime) && state.size == previous.size {
+		state.hash = previous.hash
+		return state
+	}
+	if data, err := os.ReadFile(p.path); err == nil {
+		state.hash = sha256.Sum256(data)
+	}
+	return state
+}
+
+// run 定期检查文件的状态
+func (p *pollBackend) run(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-p.done:
+			return
+		case <-ticker.C:
+		}
+
+		state := p.stat(p.state)
+		changed := state.exists != p.state.exists || state.hash != p.state.hash
+		p.state = state
+		if changed {
+			select {
+			case p.signalsCh <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+func (p *pollBackend) signals() <-chan struct{} {
+	return p.signalsCh
+}
+
+func (p *pollBackend) close() error {
+	p.closeOnce.Do(func() { close(p.done) })
+	return nil
+}
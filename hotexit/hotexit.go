@@ -0,0 +1,224 @@
+// Package hotexit 在退出时备份所有未保存的文本缓冲区，下次启动时恢复
+//
+// 每个文本缓冲区备份为备份目录中的一个JSON文件，记录文本内容、关联的文件、编码、换行符、
+// 选区以及可选的撤销历史。这样退出时不需要询问是否保存修改；恢复时如果关联的文件
+// 在备份之后被修改或删除，会给出警告
+package hotexit
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/example/gotextbuffer/textbuffer"
+)
+
+// formatVersion 是备份文件的格式版本
+const formatVersion = 1
+
+// backupExtension 是备份文件的扩展名
+const backupExtension = ".backup.json"
+
+// ErrUnsupportedVersion 表示备份文件的格式版本不受支持
+var ErrUnsupportedVersion = errors.New("unsupported backup version")
+
+// Entry 是一个需要备份的文本缓冲区
+type Entry struct {
+	// ID 在所有文本缓冲区中唯一的标识，例如文件路径或未命名文档的名称
+	ID string
+	// Buffer 文本缓冲区
+	Buffer *textbuffer.TextBuffer
+}
+
+// Options 是备份的选项
+type Options struct {
+	// IncludeUndoHistory 是否备份撤销/重做历史
+	IncludeUndoHistory bool
+}
+
+// Warning 表示恢复时需要提示用户的情况
+type Warning int
+
+const (
+	// WarningNone 没有需要提示的情况
+	WarningNone Warning = iota
+	// WarningFileChanged 关联的文件在备份之后被修改，恢复的文本没有合并这些修改
+	WarningFileChanged
+	// WarningFileDeleted 关联的文件在备份之后被删除
+	WarningFileDeleted
+)
+
+// String 获取警告的描述
+func (w Warning) String() string {
+	switch w {
+	case WarningFileChanged:
+		return "file changed on disk"
+	case WarningFileDeleted:
+		return "file deleted from disk"
+	default:
+		return "none"
+	}
+}
+
+// backup 是备份文件的内容
+type backup struct {
+	Version    int                     `json:"version"`
+	ID         string                  `json:"id"`
+	Path       string                  `json:"path,omitempty"`
+	Content    string                  `json:"content"`
+	Encoding   textbuffer.FileEncoding `json:"encoding"`
+	EndOfLine  textbuffer.EndOfLine    `json:"endOfLine"`
+	Selections []textbuffer.Selection  `json:"selections,omitempty"`
+	// 文本缓冲区最近一次加载或保存时文件的状态
+	FileModTime time.Time `json:"fileModTime"`
+	FileSize    int64     `json:"fileSize,omitempty"`
+	// 撤销/重做历史，没有备份时为空
+	UndoHistory *textbuffer.UndoHistory `json:"undoHistory,omitempty"`
+	BackupTime  time.Time               `json:"backupTime"`
+}
+
+// NeedsBackup 判断文本缓冲区是否需要备份：有未保存的修改，或者是有内容的未命名文档
+func NeedsBackup(buffer *textbuffer.TextBuffer) bool {
+	if buffer.GetFilePath() == "" {
+		return buffer.GetLength() > 0
+	}
+	return buffer.IsDirty()
+}
+
+// Backup 把需要备份的文本缓冲区写入备份目录，并删除目录中其他的备份
+// 单个文本缓冲区备份失败不影响其他文本缓冲区，所有错误合并后返回
+func Backup(dir string, entries []Entry, options Options) error {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	var errs []error
+	written := make(map[string]bool)
+	for _, entry := range entries {
+		if !NeedsBackup(entry.Buffer) {
+			continue
+		}
+		name := backupName(entry.ID)
+		if err := writeBackup(filepath.Join(dir, name), newBackup(entry, options)); err != nil {
+			errs = append(errs, fmt.Errorf("backing up %s: %w", entry.ID, err))
+			continue
+		}
+		written[name] = true
+	}
+
+	names, err := backupNames(dir)
+	if err != nil {
+		errs = append(errs, err)
+	}
+	for _, name := range names {
+		if !written[name] {
+			if err := os.Remove(filepath.Join(dir, name)); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// newBackup 记录文本缓冲区的状态
+func newBackup(entry Entry, options Options) *backup {
+	buffer := entry.Buffer
+	b := &backup{
+		Version:    formatVersion,
+		ID:         entry.ID,
+		Content:    buffer.GetText(),
+		Encoding:   buffer.GetEncoding(),
+		EndOfLine:  buffer.GetEndOfLine(),
+		Selections: buffer.GetSelections(),
+		BackupTime: time.Now(),
+	}
+	if info, ok := buffer.GetFileInfo(); ok {
+		b.Path = info.Path
+		b.FileModTime = info.ModTime
+		b.FileSize = info.Size
+	}
+	if options.IncludeUndoHistory {
+		history := buffer.ExportUndoHistory()
+		b.UndoHistory = &history
+	}
+	return b
+}
+
+// backupName 根据标识生成备份文件名
+func backupName(id string) string {
+	hash := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(hash[:8]) + backupExtension
+}
+
+// backupNames 获取备份目录中所有备份文件的名称，按名称排序
+func backupNames(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if entry.Type().IsRegular() && strings.HasSuffix(entry.Name(), backupExtension) {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// writeBackup 通过临时文件和重命名写入备份，中断时不会留下不完整的备份
+func writeBackup(path string, b *backup) (err error) {
+	data, err := json.Marshal(b)
+	if err != nil {
+		return err
+	}
+
+	temp, err := os.CreateTemp(filepath.Dir(path), ".backup-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			temp.Close()
+			os.Remove(temp.Name())
+		}
+	}()
+
+	if _, err = temp.Write(data); err != nil {
+		return err
+	}
+	if err = temp.Sync(); err != nil {
+		return err
+	}
+	if err = temp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), path)
+}
+
+// Clear 删除备份目录中的所有备份
+func Clear(dir string) error {
+	names, err := backupNames(dir)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, name := range names {
+		if err := os.Remove(filepath.Join(dir, name)); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
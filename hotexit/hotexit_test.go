@@ -0,0 +1,148 @@
+package hotexit
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/example/gotextbuffer/textbuffer"
+)
+
+func TestBackupAndRestore(t *testing.T) {
+	dir := t.TempDir()
+	backupDir := filepath.Join(dir, "backups")
+	path := filepath.Join(dir, "file.txt")
+	if err := os.WriteFile(path, []byte("\xEF\xBB\xBFone\r\ntwo\r\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	dirty, err := textbuffer.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dirty.Insert(textbuffer.Position{Line: 1, Column: 3}, "!")
+	selection := textbuffer.NewSelection(textbuffer.Position{Line: 1, Column: 4}, textbuffer.Position{Line: 1, Column: 4})
+	dirty.SetSelections([]textbuffer.Selection{selection})
+
+	untitled := textbuffer.NewTextBuffer()
+	untitled.Insert(textbuffer.Position{}, "scratch")
+
+	clean, _ := textbuffer.OpenFile(path)
+
+	entries := []Entry{{ID: path, Buffer: dirty}, {ID: "Untitled-1", Buffer: untitled}, {ID: "clean", Buffer: clean}}
+	if err := Backup(backupDir, entries, Options{IncludeUndoHistory: true}); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+
+	restored, err := Restore(backupDir)
+	if err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	if len(restored) != 2 {
+		t.Fatalf("Expected 2 restored buffers, got %d", len(restored))
+	}
+
+	byID := make(map[string]*Restored)
+	for _, r := range restored {
+		byID[r.ID] = r
+	}
+
+	r := byID[path]
+	if r == nil || r.Warning != WarningNone || !r.UndoHistoryRestored {
+		t.Fatalf("Unexpected restored file %+v", r)
+	}
+	if r.Buffer.GetText() != "one\r\ntwo!\r\n" || r.Buffer.GetFilePath() != path || !r.Buffer.IsDirty() {
+		t.Errorf("Unexpected restored text %q", r.Buffer.GetText())
+	}
+	if r.Buffer.GetEncoding() != (textbuffer.FileEncoding{Encoding: textbuffer.EncodingUTF8, BOM: true}) || r.EndOfLine != textbuffer.EndOfLineCRLF {
+		t.Errorf("Unexpected encoding %s or end of line %s", r.Buffer.GetEncoding(), r.EndOfLine)
+	}
+	if selections := r.Buffer.GetSelections(); len(selections) != 1 || selections[0] != selection {
+		t.Errorf("Unexpected selections %+v", selections)
+	}
+
+	// 撤销到文件的状态后不再是修改过的
+	r.Buffer.Undo()
+	if r.Buffer.GetText() != "one\r\ntwo\r\n" || r.Buffer.IsDirty() {
+		t.Errorf("Expected the saved state after undo, got %q", r.Buffer.GetText())
+	}
+
+	if u := byID["Untitled-1"]; u == nil || u.Buffer.GetText() != "scratch" || u.Path != "" || !NeedsBackup(u.Buffer) {
+		t.Errorf("Unexpected restored untitled buffer %+v", u)
+	}
+
+	// 没有需要备份的文本缓冲区时删除旧的备份
+	if err := Backup(backupDir, []Entry{{ID: "clean", Buffer: clean}}, Options{}); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+	if restored, _ := Restore(backupDir); len(restored) != 0 {
+		t.Errorf("Expected stale backups to be removed, got %d", len(restored))
+	}
+}
+
+func TestRestoreWarnings(t *testing.T) {
+	dir := t.TempDir()
+	backupDir := filepath.Join(dir, "backups")
+	changedPath := filepath.Join(dir, "changed.txt")
+	deletedPath := filepath.Join(dir, "deleted.txt")
+	os.WriteFile(changedPath, []byte("base\n"), 0o644)
+	os.WriteFile(deletedPath, []byte("base\n"), 0o644)
+
+	var entries []Entry
+	for _, path := range []string{changedPath, deletedPath} {
+		buffer, err := textbuffer.OpenFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		buffer.Insert(textbuffer.Position{Line: 1, Column: 0}, "mine\n")
+		entries = append(entries, Entry{ID: path, Buffer: buffer})
+	}
+	if err := Backup(backupDir, entries, Options{IncludeUndoHistory: true}); err != nil {
+		t.Fatalf("Backup failed: %v", err)
+	}
+
+	os.WriteFile(changedPath, []byte("theirs\n"), 0o644)
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(changedPath, later, later)
+	os.Remove(deletedPath)
+
+	restored, err := Restore(backupDir)
+	if err != nil || len(restored) != 2 {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	for _, r := range restored {
+		if r.Buffer.GetText() != "base\nmine\n" || !r.Buffer.IsDirty() {
+			t.Errorf("%s: unexpected text %q", r.ID, r.Buffer.GetText())
+		}
+		switch r.ID {
+		case changedPath:
+			if r.Warning != WarningFileChanged || r.Buffer.GetFilePath() != changedPath {
+				t.Errorf("Expected a changed file warning, got %s", r.Warning)
+			}
+			// 历史中没有与文件一致的状态
+			r.Buffer.Undo()
+			if !r.Buffer.IsDirty() {
+				t.Errorf("Expected the buffer to stay dirty after undo")
+			}
+		case deletedPath:
+			if r.Warning != WarningFileDeleted || r.Path != deletedPath || r.Buffer.GetFilePath() != "" {
+				t.Errorf("Expected a deleted file warning, got %s", r.Warning)
+			}
+		}
+	}
+
+	// 损坏的备份被跳过
+	os.WriteFile(filepath.Join(backupDir, "broken"+backupExtension), []byte("{"), 0o600)
+	restored, err = Restore(backupDir)
+	if err == nil || len(restored) != 2 {
+		t.Errorf("Expected an error for the broken backup and 2 restored buffers, got %v, %d", err, len(restored))
+	}
+
+	if err := Clear(backupDir); err != nil {
+		t.Fatalf("Clear failed: %v", err)
+	}
+	if restored, _ := Restore(backupDir); len(restored) != 0 {
+		t.Errorf("Expected no backups after Clear")
+	}
+}
@@ -0,0 +1,113 @@
+package hotexit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/example/gotextbuffer/textbuffer"
+)
+
+// Restored 是从备份恢复的一个文本缓冲区
+type Restored struct {
+	// ID 备份时的标识
+	ID string
+	// Path 关联的文件，未命名文档为空
+	// 文件被删除时文本缓冲区没有关联的文件，需要调用方通过SaveTo重新保存到这个路径
+	Path string
+	// Buffer 恢复的文本缓冲区，包含未保存的修改
+	Buffer *textbuffer.TextBuffer
+	// EndOfLine 备份时文本使用的换行符
+	EndOfLine textbuffer.EndOfLine
+	// UndoHistoryRestored 是否恢复了撤销/重做历史
+	UndoHistoryRestored bool
+	// Warning 需要提示用户的情况
+	Warning Warning
+}
+
+// Restore 恢复备份目录中的所有文本缓冲区，备份文件保持不变
+// 无法读取的备份被跳过，它们的错误合并后与恢复成功的文本缓冲区一起返回
+func Restore(dir string) ([]*Restored, error) {
+	names, err := backupNames(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var restored []*Restored
+	var errs []error
+	for _, name := range names {
+		r, err := restoreFile(filepath.Join(dir, name))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("restoring %s: %w", name, err))
+			continue
+		}
+		restored = append(restored, r)
+	}
+	return restored, errors.Join(errs...)
+}
+
+// restoreFile 从一个备份文件恢复文本缓冲区
+func restoreFile(path string) (*Restored, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var b backup
+	if err := json.Unmarshal(data, &b); err != nil {
+		return nil, err
+	}
+	if b.Version != formatVersion {
+		return nil, fmt.Errorf("%w: %d", ErrUnsupportedVersion, b.Version)
+	}
+
+	r := &Restored{ID: b.ID, Path: b.Path, EndOfLine: b.EndOfLine}
+	if b.Path != "" {
+		info, err := os.Stat(b.Path)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			r.Warning = WarningFileDeleted
+		case err != nil:
+			return nil, err
+		case !info.ModTime().Equal(b.FileModTime) || info.Size() != b.FileSize:
+			r.Warning = WarningFileChanged
+		}
+
+		// 从文件加载，使文本缓冲区关联到文件，未保存的修改作为一个操作应用在文件内容之上
+		if r.Warning != WarningFileDeleted {
+			if r.Buffer, err = textbuffer.OpenFile(b.Path); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if r.Buffer == nil {
+		r.Buffer = textbuffer.NewTextBuffer()
+	}
+
+	r.Buffer.SetEncoding(b.Encoding)
+	r.Buffer.SetText(b.Content)
+	if b.UndoHistory != nil {
+		history := *b.UndoHistory
+		if r.Warning != WarningNone {
+			// 文件已经变化，历史中没有任何状态与文件一致
+			history.SavedOperationID = unreachableOperationID(history)
+		}
+		r.UndoHistoryRestored = r.Buffer.ImportUndoHistory(history) == nil
+	}
+	r.Buffer.SetSelections(b.Selections)
+	return r, nil
+}
+
+// unreachableOperationID 获取一个历史中不存在的操作标识
+func unreachableOperationID(history textbuffer.UndoHistory) uint64 {
+	id := history.SavedOperationID
+	for _, operation := range history.Undo {
+		id = max(id, operation.ID)
+	}
+	for _, operation := range history.Redo {
+		id = max(id, operation.ID)
+	}
+	return id + 1
+}
@@ -0,0 +1,169 @@
+package journal
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+
+	"github.com/example/gotextbuffer/textbuffer"
+)
+
+// 交换文件以魔数和格式版本开头，之后是一系列记录。
+// 每个记录由4字节的负载长度、4字节的负载CRC32校验和以及负载组成（小端序）；
+// 负载的第一个字节是记录类型，之后是序号和记录的内容
+var magic = []byte{'G', 'T', 'B', 'J', 1}
+
+const (
+	// recordHeader 描述日志开始时的基准文件，序号为0
+	recordHeader byte = 'H'
+	// recordEdits 一组按顺序应用的编辑
+	recordEdits byte = 'E'
+)
+
+// maxRecordSize 是单个记录的最大长度，超过时认为记录已损坏
+const maxRecordSize = 1 << 30
+
+// 读取记录时的错误
+var (
+	errTruncated = errors.New("truncated record")
+	errCorrupted = errors.New("corrupted record")
+)
+
+// record 是交换文件中的一条记录
+type record struct {
+	kind     byte
+	sequence uint64
+	// 基准文件的大小和SHA-256哈希值，只用于头部记录
+	baseSize int64
+	baseHash [sha256.Size]byte
+	// 编辑记录中的编辑
+	edits []textbuffer.Edit
+}
+
+// encode 将记录编码为带长度和校验和的字节
+func (r *record) encode() []byte {
+	payload := []byte{r.kind}
+	payload = binary.AppendUvarint(payload, r.sequence)
+
+	switch r.kind {
+	case recordHeader:
+		payload = binary.AppendUvarint(payload, uint64(r.baseSize))
+		payload = append(payload, r.baseHash[:]...)
+	case recordEdits:
+		payload = binary.AppendUvarint(payload, uint64(len(r.edits)))
+		for _, edit := range r.edits {
+			payload = binary.AppendUvarint(payload, uint64(edit.Offset))
+			payload = appendString(payload, edit.OldText)
+			payload = appendString(payload, edit.NewText)
+		}
+	}
+
+	data := make([]byte, 8, 8+len(payload))
+	binary.LittleEndian.PutUint32(data[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(data[4:8], crc32.ChecksumIEEE(payload))
+	return append(data, payload...)
+}
+
+// appendString 写入带长度的字符串
+func appendString(data []byte, text string) []byte {
+	data = binary.AppendUvarint(data, uint64(len(text)))
+	return append(data, text...)
+}
+
+// readMagic 读取并检查交换文件的魔数
+func readMagic(reader *bufio.Reader) error {
+	header := make([]byte, len(magic))
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return fmt.Errorf("%w: missing file header", errTruncated)
+	}
+	if string(header) != string(magic) {
+		return fmt.Errorf("%w: not a journal file", errCorrupted)
+	}
+	return nil
+}
+
+// readRecord 读取一条记录，到达文件末尾时返回io.EOF
+// 记录不完整时返回errTruncated，校验失败时返回errCorrupted
+func readRecord(reader *bufio.Reader) (*record, error) {
+	var prefix [8]byte
+	n, err := io.ReadFull(reader, prefix[:])
+	if n == 0 && err == io.EOF {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, errTruncated
+	}
+
+	length := binary.LittleEndian.Uint32(prefix[0:4])
+	if length == 0 || length > maxRecordSize {
+		return nil, fmt.Errorf("%w: invalid length %d", errCorrupted, length)
+	}
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(reader, payload); err != nil {
+		return nil, errTruncated
+	}
+	if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(prefix[4:8]) {
+		return nil, fmt.Errorf("%w: checksum mismatch", errCorrupted)
+	}
+
+	r, ok := decodePayload(payload)
+	if !ok {
+		return nil, fmt.Errorf("%w: malformed payload", errCorrupted)
+	}
+	return r, nil
+}
+
+// decodePayload 解码记录的负载
+func decodePayload(payload []byte) (*record, bool) {
+	d := decoder{data: payload[1:], ok: true}
+	r := &record{kind: payload[0], sequence: d.uvarint()}
+
+	switch r.kind {
+	case recordHeader:
+		r.baseSize = int64(d.uvarint())
+		copy(r.baseHash[:], d.bytes(sha256.Size))
+	case recordEdits:
+		count := d.uvarint()
+		for i := uint64(0); i < count && d.ok; i++ {
+			offset := int(d.uvarint())
+			oldText := string(d.bytes(int(d.uvarint())))
+			newText := string(d.bytes(int(d.uvarint())))
+			r.edits = append(r.edits, textbuffer.Edit{Offset: offset, OldText: oldText, NewText: newText})
+		}
+	default:
+		return nil, false
+	}
+	return r, d.ok && len(d.data) == 0
+}
+
+// decoder 从负载中依次读取字段，出错后ok为false
+type decoder struct {
+	data []byte
+	ok   bool
+}
+
+// uvarint 读取一个无符号变长整数
+func (d *decoder) uvarint() uint64 {
+	value, n := binary.Uvarint(d.data)
+	if n <= 0 {
+		d.ok = false
+		return 0
+	}
+	d.data = d.data[n:]
+	return value
+}
+
+// bytes 读取指定长度的字节
+func (d *decoder) bytes(length int) []byte {
+	if length < 0 || length > len(d.data) {
+		d.ok = false
+		return nil
+	}
+	value := d.data[:length]
+	d.data = d.data[length:]
+	return value
+}
@@ -0,0 +1,290 @@
+// Package journal 把文本缓冲区的编辑记录到文档旁边的交换文件中，进程崩溃后可以恢复未保存的修改
+//
+// 交换文件以描述基准文件（日志开始时磁盘上的文件）的头部记录开始，之后每次编辑追加一条记录。
+// 每条记录带有递增的序号和CRC32校验和，并定期同步到磁盘。Recover在基准文件的内容上
+// 重放记录，遇到不完整或损坏的记录时停止，并报告恢复了哪些内容
+package journal
+
+import (
+	"crypto/sha256"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/example/gotextbuffer/textbuffer"
+)
+
+// ErrNoFile 表示文本缓冲区没有关联的文件
+var ErrNoFile = errors.New("buffer has no associated file")
+
+// defaultSyncInterval 是同步交换文件的默认间隔
+const defaultSyncInterval = time.Second
+
+// Options 是记录日志的选项
+type Options struct {
+	// SyncInterval 同步交换文件的间隔，为0时使用默认值
+	SyncInterval time.Duration
+}
+
+// SwapPath 获取文件对应的交换文件路径，与文件位于同一目录
+func SwapPath(path string) string {
+	return filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".swp")
+}
+
+// Journal 把一个文本缓冲区的编辑记录到交换文件
+type Journal struct {
+	buffer       *textbuffer.TextBuffer
+	path         string
+	swapPath     string
+	syncInterval time.Duration
+
+	mutex sync.Mutex
+	file  *os.File
+	// 最后一条记录的序号
+	sequence uint64
+	// 下一个要记录的事件的起始版本号
+	nextVersion int
+	// 提前到达的事件，按起始版本号索引；并发的编辑可能以不同于应用的顺序通知
+	pending map[int]textbuffer.ContentChangeEvent
+	// 是否有尚未同步的写入
+	unsynced bool
+	// 第一次写入失败的错误
+	err error
+
+	removeListener      func()
+	removeSavedListener func()
+	done                chan struct{}
+	once                sync.Once
+	wg                  sync.WaitGroup
+}
+
+// Open 开始为文本缓冲区记录日志，覆盖已有的交换文件
+// 需要恢复的交换文件应该在调用Open之前通过Recover读取
+func Open(buffer *textbuffer.TextBuffer, options Options) (*Journal, error) {
+	path := buffer.GetFilePath()
+	if path == "" {
+		return nil, ErrNoFile
+	}
+	if options.SyncInterval <= 0 {
+		options.SyncInterval = defaultSyncInterval
+	}
+
+	j := &Journal{
+		buffer:       buffer,
+		path:         path,
+		swapPath:     SwapPath(path),
+		syncInterval: options.SyncInterval,
+		done:         make(chan struct{}),
+	}
+
+	// 先注册监听器再获取快照，快照之后的编辑都不会遗漏
+	j.removeListener = buffer.OnContentChanged(j.contentChanged)
+	j.removeSavedListener = buffer.OnSaved(j.saved)
+	j.mutex.Lock()
+	err := j.start()
+	j.mutex.Unlock()
+	if err != nil {
+		j.removeListener()
+		j.removeSavedListener()
+		return nil, err
+	}
+
+	j.wg.Add(1)
+	go j.syncLoop()
+	return j, nil
+}
+
+// SwapPath 获取交换文件的路径，文本被保存到其他文件后随之变化
+func (j *Journal) SwapPath() string {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	return j.swapPath
+}
+
+// Reset 以磁盘上的文件为新的基准重新开始记录
+// 文本缓冲区保存或重新加载文件之后会自动调用，只有在其他方式使文件与文本一致时才需要手动调用
+func (j *Journal) Reset() error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	return j.start()
+}
+
+// saved 在文本与文件一致之后重新开始记录
+// 文本被保存到其他文件时，删除原来的交换文件，改为记录新的文件
+func (j *Journal) saved(event textbuffer.SavedEvent) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	select {
+	case <-j.done:
+		// 已经关闭
+		return
+	default:
+	}
+
+	if event.Path != j.path {
+		if j.file != nil {
+			j.file.Close()
+			j.file = nil
+		}
+		os.Remove(j.swapPath)
+		j.path = event.Path
+		j.swapPath = SwapPath(event.Path)
+	}
+	if err := j.start(); err != nil && j.err == nil {
+		j.err = err
+	}
+}
+
+// start 写入新的交换文件，记录基准文件和文本缓冲区相对于它的修改
+// 调用方必须持有锁
+func (j *Journal) start() error {
+	if j.file != nil {
+		j.file.Close()
+		j.file = nil
+	}
+
+	data, err := os.ReadFile(j.path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	snapshot := j.buffer.CreateSnapshot()
+
+	file, err := os.OpenFile(j.swapPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	j.file = file
+	j.sequence = 0
+	j.nextVersion = snapshot.VersionID()
+	j.pending = make(map[int]textbuffer.ContentChangeEvent)
+	j.err = nil
+
+	header := &record{kind: recordHeader, baseSize: int64(len(data)), baseHash: sha256.Sum256(data)}
+	if _, err := file.Write(append(append([]byte(nil), magic...), header.encode()...)); err != nil {
+		return err
+	}
+
+	// 文本缓冲区已经被修改过时，把与基准文件的差异记录为第一条编辑
+	baseText := textbuffer.DecodeText(data, textbuffer.DetectEncoding(data))
+	if text := snapshot.GetText(); text != baseText {
+		j.write([]textbuffer.Edit{{Offset: 0, OldText: baseText, NewText: text}})
+	}
+	if j.err != nil {
+		return j.err
+	}
+	j.unsynced = false
+	return file.Sync()
+}
+
+// contentChanged 按版本号的顺序记录内容变化事件
+func (j *Journal) contentChanged(event textbuffer.ContentChangeEvent) {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	start := event.VersionID - len(event.Edits)
+	if j.file == nil || start < j.nextVersion {
+		// 已经包含在快照中的编辑
+		return
+	}
+	j.pending[start] = event
+
+	for {
+		next, ok := j.pending[j.nextVersion]
+		if !ok {
+			return
+		}
+		delete(j.pending, j.nextVersion)
+		j.write(next.Edits)
+		j.nextVersion = next.VersionID
+	}
+}
+
+// write 追加一条编辑记录，写入失败后不再写入
+// 调用方必须持有锁
+func (j *Journal) write(edits []textbuffer.Edit) {
+	if j.err != nil {
+		return
+	}
+	j.sequence++
+	r := &record{kind: recordEdits, sequence: j.sequence, edits: edits}
+	if _, err := j.file.Write(r.encode()); err != nil {
+		j.err = err
+		return
+	}
+	j.unsynced = true
+}
+
+// syncLoop 定期把写入同步到磁盘
+func (j *Journal) syncLoop() {
+	defer j.wg.Done()
+
+	ticker := time.NewTicker(j.syncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-j.done:
+			return
+		case <-ticker.C:
+			j.Sync()
+		}
+	}
+}
+
+// Sync 立即把尚未同步的写入同步到磁盘
+func (j *Journal) Sync() error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	if j.file == nil || !j.unsynced || j.err != nil {
+		return j.err
+	}
+	if err := j.file.Sync(); err != nil {
+		j.err = err
+		return err
+	}
+	j.unsynced = false
+	return nil
+}
+
+// Err 获取写入交换文件时发生的第一个错误
+func (j *Journal) Err() error {
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+	return j.err
+}
+
+// Close 停止记录，同步并关闭交换文件，交换文件被保留
+func (j *Journal) Close() error {
+	var err error
+	j.once.Do(func() {
+		close(j.done)
+		j.wg.Wait()
+		j.removeListener()
+		j.removeSavedListener()
+
+		err = j.Sync()
+		j.mutex.Lock()
+		defer j.mutex.Unlock()
+		if j.file != nil {
+			if closeErr := j.file.Close(); err == nil {
+				err = closeErr
+			}
+			j.file = nil
+		}
+	})
+	return err
+}
+
+// Discard 停止记录并删除交换文件，用于文件已经保存或不需要恢复的情况
+func (j *Journal) Discard() error {
+	j.Close()
+	err := os.Remove(j.SwapPath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
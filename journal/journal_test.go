@@ -0,0 +1,188 @@
+package journal
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/example/gotextbuffer/textbuffer"
+)
+
+// openTemp 创建一个临时文件并打开为文本缓冲区
+func openTemp(t *testing.T, text string) (*textbuffer.TextBuffer, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "file.txt")
+	if err := os.WriteFile(path, []byte(text), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	buffer, err := textbuffer.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return buffer, path
+}
+
+func TestJournalRecover(t *testing.T) {
+	buffer, path := openTemp(t, "hello\nworld\n")
+	// 开始记录之前的修改也会被恢复
+	buffer.Insert(textbuffer.Position{Line: 0, Column: 0}, "> ")
+
+	journal, err := Open(buffer, Options{})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	buffer.Insert(textbuffer.Position{Line: 1, Column: 5}, "!")
+	buffer.Replace(textbuffer.NewRange(textbuffer.Position{Line: 0, Column: 2}, textbuffer.Position{Line: 0, Column: 7}), "日本語")
+	buffer.Undo()
+	buffer.Redo()
+	buffer.Delete(textbuffer.NewRange(textbuffer.Position{Line: 1, Column: 0}, textbuffer.Position{Line: 2, Column: 0}))
+	if err := journal.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	recovery, err := Recover(path)
+	if err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+	if recovery.Text != buffer.GetText() {
+		t.Errorf("Expected '%s', got '%s'", buffer.GetText(), recovery.Text)
+	}
+	if recovery.Records != 6 || recovery.LastSequence != 6 || recovery.Truncated || recovery.Corrupted || recovery.Err != nil {
+		t.Errorf("Unexpected recovery %+v", recovery)
+	}
+
+	// 保存之后重新开始记录
+	journal, _ = Open(buffer, Options{})
+	buffer.Save()
+	journal.Reset()
+	buffer.Insert(textbuffer.Position{Line: 0, Column: 0}, "x")
+	journal.Close()
+	recovery, err = Recover(path)
+	if err != nil || recovery.Text != buffer.GetText() || recovery.Records != 1 {
+		t.Errorf("Unexpected recovery %+v, %v", recovery, err)
+	}
+
+	// 基准文件被修改后无法重放
+	os.WriteFile(path, []byte("other"), 0o644)
+	if _, err := Recover(path); !errors.Is(err, ErrBaseChanged) {
+		t.Errorf("Expected ErrBaseChanged, got %v", err)
+	}
+
+	journal, _ = Open(buffer, Options{})
+	if err := journal.Discard(); err != nil {
+		t.Errorf("Discard failed: %v", err)
+	}
+	if _, err := Recover(path); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected the swap file to be removed, got %v", err)
+	}
+}
+
+func TestRecoverDamagedJournal(t *testing.T) {
+	buffer, path := openTemp(t, "abc")
+	journal, err := Open(buffer, Options{})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	for _, text := range []string{"1", "2", "3"} {
+		buffer.Insert(textbuffer.Position{Line: 0, Column: 3}, text)
+	}
+	journal.Close()
+
+	data, err := os.ReadFile(SwapPath(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 末尾的记录不完整
+	os.WriteFile(SwapPath(path), data[:len(data)-2], 0o600)
+	recovery, err := Recover(path)
+	if err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+	if recovery.Text != "abc21" || recovery.Records != 2 || !recovery.Truncated || recovery.Corrupted {
+		t.Errorf("Unexpected recovery of a truncated journal %+v", recovery)
+	}
+
+	// 最后一条记录的内容被修改
+	damaged := append([]byte(nil), data...)
+	damaged[len(damaged)-1] = 'x'
+	os.WriteFile(SwapPath(path), damaged, 0o600)
+	recovery, err = Recover(path)
+	if err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+	if recovery.Text != "abc21" || recovery.Records != 2 || recovery.Truncated || !recovery.Corrupted {
+		t.Errorf("Unexpected recovery of a corrupted journal %+v", recovery)
+	}
+
+	os.WriteFile(SwapPath(path), []byte("garbage"), 0o600)
+	if _, err := Recover(path); err == nil {
+		t.Errorf("Expected an error for an invalid journal")
+	}
+}
+
+func TestJournalResetsOnSave(t *testing.T) {
+	buffer, path := openTemp(t, "abc")
+	journal, err := Open(buffer, Options{})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer journal.Close()
+
+	buffer.Insert(textbuffer.Position{Line: 0, Column: 3}, "1")
+	if err := buffer.Save(); err != nil {
+		t.Fatal(err)
+	}
+	buffer.Insert(textbuffer.Position{Line: 0, Column: 4}, "2")
+	journal.Sync()
+
+	// 保存之后以保存的文件为基准，只记录之后的编辑
+	recovery, err := Recover(path)
+	if err != nil {
+		t.Fatalf("Recover failed: %v", err)
+	}
+	if recovery.Text != "abc12" || recovery.Records != 1 || recovery.Err != nil {
+		t.Errorf("Unexpected recovery after save %+v", recovery)
+	}
+
+	// 另存为其他文件时交换文件随之移动
+	other := filepath.Join(filepath.Dir(path), "other.txt")
+	if err := buffer.SaveTo(other); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Recover(path); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected the old swap file to be removed, got %v", err)
+	}
+	if journal.SwapPath() != SwapPath(other) {
+		t.Errorf("Expected swap path %s, got %s", SwapPath(other), journal.SwapPath())
+	}
+	buffer.Insert(textbuffer.Position{Line: 0, Column: 0}, ">")
+	journal.Sync()
+	recovery, err = Recover(other)
+	if err != nil || recovery.Text != ">abc12" || recovery.Records != 1 {
+		t.Errorf("Unexpected recovery after save as %+v, %v", recovery, err)
+	}
+}
+
+func TestReplayRollsBackRecord(t *testing.T) {
+	text := []rune("hello")
+	r := &record{kind: recordEdits, sequence: 1, edits: []textbuffer.Edit{
+		{Offset: 0, OldText: "h", NewText: "J"},
+		{Offset: 5, OldText: "", NewText: "!"},
+		{Offset: 1, OldText: "x", NewText: "y"},
+	}}
+	text, err := replay(text, r)
+	if !errors.Is(err, errCorrupted) {
+		t.Errorf("Expected a corrupted record, got %v", err)
+	}
+	if string(text) != "hello" {
+		t.Errorf("Expected the record to be rolled back, got '%s'", string(text))
+	}
+
+	r.edits = r.edits[:2]
+	if text, err = replay(text, r); err != nil || string(text) != "Jello!" {
+		t.Errorf("Expected 'Jello!', got '%s', %v", string(text), err)
+	}
+}
@@ -0,0 +1,125 @@
+package journal
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"slices"
+	"unicode/utf8"
+
+	"github.com/example/gotextbuffer/textbuffer"
+)
+
+// ErrBaseChanged 表示基准文件在日志开始之后被修改，记录的编辑无法重放
+var ErrBaseChanged = errors.New("base file changed since the journal was started")
+
+// Recovery 描述从交换文件恢复的结果
+type Recovery struct {
+	// Text 重放所有完好的记录之后的文本
+	Text string
+	// Encoding 基准文件的编码
+	Encoding textbuffer.FileEncoding
+	// Records 重放的编辑记录数量
+	Records int
+	// Edits 重放的编辑数量
+	Edits int
+	// LastSequence 最后一条重放的记录的序号
+	LastSequence uint64
+	// Truncated 交换文件末尾有不完整的记录，通常是写入时进程崩溃
+	Truncated bool
+	// Corrupted 交换文件中有校验失败、序号不连续或无法应用的记录，之后的记录都被忽略
+	Corrupted bool
+	// Err 描述导致停止重放的问题，全部记录都被重放时为nil
+	Err error
+}
+
+// Recover 读取文件的交换文件，在磁盘上的文件内容之上重放记录的编辑
+// 交换文件不存在时返回的错误满足errors.Is(err, fs.ErrNotExist)；
+// 不完整或损坏的记录不会导致错误，而是在Recovery中报告，之前的记录仍然被恢复
+func Recover(path string) (*Recovery, error) {
+	swap, err := os.Open(SwapPath(path))
+	if err != nil {
+		return nil, err
+	}
+	defer swap.Close()
+
+	reader := bufio.NewReader(swap)
+	if err := readMagic(reader); err != nil {
+		return nil, err
+	}
+	header, err := readRecord(reader)
+	if err == io.EOF {
+		err = errTruncated
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading journal header: %w", err)
+	}
+	if header.kind != recordHeader || header.sequence != 0 {
+		return nil, fmt.Errorf("%w: missing journal header", errCorrupted)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if int64(len(data)) != header.baseSize || sha256.Sum256(data) != header.baseHash {
+		return nil, ErrBaseChanged
+	}
+
+	encoding := textbuffer.DetectEncoding(data)
+	text := []rune(textbuffer.DecodeText(data, encoding))
+	recovery := &Recovery{Encoding: encoding}
+
+	for {
+		r, err := readRecord(reader)
+		if err == io.EOF {
+			break
+		}
+		if err == nil {
+			switch {
+			case r.kind != recordEdits:
+				err = fmt.Errorf("%w: unexpected record type %q", errCorrupted, r.kind)
+			case r.sequence != recovery.LastSequence+1:
+				err = fmt.Errorf("%w: expected sequence %d, got %d", errCorrupted, recovery.LastSequence+1, r.sequence)
+			default:
+				text, err = replay(text, r)
+			}
+		}
+		if err != nil {
+			recovery.Truncated = errors.Is(err, errTruncated)
+			recovery.Corrupted = errors.Is(err, errCorrupted)
+			recovery.Err = err
+			break
+		}
+
+		recovery.Records++
+		recovery.Edits += len(r.edits)
+		recovery.LastSequence = r.sequence
+	}
+
+	recovery.Text = string(text)
+	return recovery, nil
+}
+
+// replay 在文本上原地应用一条记录中的编辑，任何一个编辑无法应用时撤销这条记录中已经应用的编辑
+// 只移动编辑之后的文本，不复制整个文本
+func replay(text []rune, r *record) ([]rune, error) {
+	for i, edit := range r.edits {
+		oldText := []rune(edit.OldText)
+		if edit.Offset < 0 || edit.Offset+len(oldText) > len(text) ||
+			!slices.Equal(text[edit.Offset:edit.Offset+len(oldText)], oldText) {
+			for j := i - 1; j >= 0; j-- {
+				applied := r.edits[j]
+				end := applied.Offset + utf8.RuneCountInString(applied.NewText)
+				text = slices.Replace(text, applied.Offset, end, []rune(applied.OldText)...)
+			}
+			return text, fmt.Errorf("%w: edit in record %d does not match the text", errCorrupted, r.sequence)
+		}
+		text = slices.Replace(text, edit.Offset, edit.Offset+len(oldText), []rune(edit.NewText)...)
+	}
+	return text, nil
+}
@@ -44,7 +44,7 @@ func main() {
 	fmt.Println("Text after delete:", buffer.GetText())
 
 	// 撤销操作
-	err = buffer.Undo()
+	_, err = buffer.Undo()
 	if err != nil {
 		fmt.Println("Error:", err)
 		return
@@ -54,7 +54,7 @@ func main() {
 	fmt.Println("Text after undo:", buffer.GetText())
 
 	// 重做操作
-	err = buffer.Redo()
+	_, err = buffer.Redo()
 	if err != nil {
 		fmt.Println("Error:", err)
 		return
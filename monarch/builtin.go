@@ -0,0 +1,48 @@
+package monarch
+
+import (
+	"embed"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// builtinDefinitions 内置的语言定义，每个语言一个JSON文件
+//
+//go:embed languages/*.json
+var builtinDefinitions embed.FS
+
+// builtinLexers 缓存已经编译的内置词法分析器
+var builtinLexers sync.Map
+
+// Builtin 获取内置语言的词法分析器，支持"go"、"json"、"markdown"和"shell"
+func Builtin(name string) (*Lexer, error) {
+	if lexer, ok := builtinLexers.Load(name); ok {
+		return lexer.(*Lexer), nil
+	}
+
+	data, err := builtinDefinitions.ReadFile(path.Join("languages", name+".json"))
+	if err != nil {
+		return nil, fmt.Errorf("unknown builtin language %q", name)
+	}
+	lexer, err := Load(data)
+	if err != nil {
+		return nil, err
+	}
+
+	actual, _ := builtinLexers.LoadOrStore(name, lexer)
+	return actual.(*Lexer), nil
+}
+
+// BuiltinNames 获取所有内置语言的名称，按字母顺序排列
+func BuiltinNames() []string {
+	entries, _ := builtinDefinitions.ReadDir("languages")
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, strings.TrimSuffix(entry.Name(), ".json"))
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,152 @@
+package monarch
+
+import (
+	"unicode/utf8"
+
+	"github.com/example/gotextbuffer/textbuffer"
+)
+
+// Lexer 是编译后的词法分析器，实现了textbuffer.Tokenizer接口
+// Lexer是不可变的，可以在多个goroutine和文本缓冲区之间共享
+type Lexer struct {
+	name         string
+	start        string
+	defaultToken string
+	states       map[string][]compiledRule
+}
+
+// Name 获取语言的名称
+func (l *Lexer) Name() string {
+	return l.name
+}
+
+// State 是词法分析器在行尾的状态栈
+type State struct {
+	stack []string
+}
+
+// Clone 复制状态
+func (s *State) Clone() textbuffer.TokenizerState {
+	stack := make([]string, len(s.stack))
+	copy(stack, s.stack)
+	return &State{stack: stack}
+}
+
+// Equals 判断两个状态是否相等
+func (s *State) Equals(other textbuffer.TokenizerState) bool {
+	o, ok := other.(*State)
+	if !ok || len(s.stack) != len(o.stack) {
+		return false
+	}
+	for i := range s.stack {
+		if s.stack[i] != o.stack[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Current 获取当前状态的名称
+func (s *State) Current() string {
+	return s.stack[len(s.stack)-1]
+}
+
+// InitialState 获取第一行的初始状态
+func (l *Lexer) InitialState() textbuffer.TokenizerState {
+	return &State{stack: []string{l.start}}
+}
+
+// Tokenize 对一行文本进行分词
+func (l *Lexer) Tokenize(line string, state textbuffer.TokenizerState) ([]textbuffer.Token, textbuffer.TokenizerState) {
+	s, ok := state.(*State)
+	if !ok || len(s.stack) == 0 {
+		s = l.InitialState().(*State)
+	}
+
+	var tokens []textbuffer.Token
+	add := func(column int, tokenType string) {
+		if n := len(tokens); n > 0 && tokens[n-1].Type == tokenType {
+			return
+		}
+		tokens = append(tokens, textbuffer.Token{StartColumn: column, Type: tokenType})
+	}
+
+	offset := 0
+	column := 0
+	// 不消耗文本的状态变化次数，用于避免死循环
+	emptyMatches := 0
+	for offset < len(line) {
+		rule, length, matched := l.match(s.Current(), line[offset:])
+		if !matched || (length == 0 && (rule.next == "" || emptyMatches >= maxStackDepth)) {
+			// 没有规则匹配时，把一个字符作为默认的词法单元
+			_, size := utf8.DecodeRuneInString(line[offset:])
+			add(column, l.defaultToken)
+			offset += size
+			column++
+			emptyMatches = 0
+			continue
+		}
+
+		text := line[offset : offset+length]
+		if length > 0 {
+			add(column, rule.tokenFor(text, l.defaultToken))
+			emptyMatches = 0
+		} else {
+			emptyMatches++
+		}
+		offset += length
+		column += utf8.RuneCountInString(text)
+		s.stack = l.transition(s.stack, rule.next)
+	}
+
+	return tokens, s
+}
+
+// match 在当前状态中查找第一个匹配文本开头的规则，返回匹配的长度（以字节为单位）
+func (l *Lexer) match(state string, text string) (compiledRule, int, bool) {
+	for _, rule := range l.states[state] {
+		if location := rule.regex.FindStringIndex(text); location != nil {
+			return rule, location[1], true
+		}
+	}
+	return compiledRule{}, 0, false
+}
+
+// tokenFor 获取匹配的文本的词法单元类型
+func (r compiledRule) tokenFor(text string, defaultToken string) string {
+	for _, c := range r.cases {
+		if c.words[text] {
+			return c.token
+		}
+	}
+	if r.token == "" {
+		return defaultToken
+	}
+	return r.token
+}
+
+// transition 根据规则的Next计算新的状态栈，不修改原来的状态栈
+func (l *Lexer) transition(stack []string, next string) []string {
+	switch next {
+	case "":
+		return stack
+	case NextPop:
+		if len(stack) > 1 {
+			return stack[: len(stack)-1 : len(stack)-1]
+		}
+		return stack
+	case NextPopAll:
+		return []string{l.start}
+	case NextPush:
+		next = stack[len(stack)-1]
+	}
+
+	if len(next) > 0 && next[0] == '@' {
+		next = next[1:]
+	}
+	result := append(stack[:len(stack):len(stack)], next)
+	if len(result) > maxStackDepth {
+		result = result[len(result)-maxStackDepth:]
+	}
+	return result
+}
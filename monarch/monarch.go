@@ -0,0 +1,225 @@
+// Package monarch 实现了Monarch风格的声明式词法分析器
+//
+// 词法分析器由一组状态组成，每个状态是按顺序尝试的规则列表。规则用正则表达式匹配文本，
+// 并指定词法单元的类型，以及匹配之后压入或弹出的状态。定义可以用Go结构体编写，
+// 也可以从JSON加载，编译后的词法分析器实现了textbuffer.Tokenizer接口
+package monarch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	// NextPop 弹出当前状态
+	NextPop = "@pop"
+	// NextPush 再次压入当前状态
+	NextPush = "@push"
+	// NextPopAll 弹出所有状态，回到初始状态
+	NextPopAll = "@popall"
+
+	// CaseDefault 是Cases中没有其他条件匹配时使用的键
+	CaseDefault = "@default"
+
+	// defaultStart 是没有指定初始状态时使用的状态名称
+	defaultStart = "root"
+	// defaultToken 是没有规则匹配的文本使用的词法单元类型
+	defaultToken = "source"
+	// maxStackDepth 是状态栈的最大深度，超过时丢弃最早的状态
+	maxStackDepth = 100
+)
+
+// ErrInvalidDefinition 表示词法分析器的定义无效
+var ErrInvalidDefinition = errors.New("invalid lexer definition")
+
+// Definition 是词法分析器的声明式定义
+type Definition struct {
+	// Name 语言的名称
+	Name string `json:"name"`
+	// Start 初始状态的名称，为空时使用"root"
+	Start string `json:"start,omitempty"`
+	// IgnoreCase 正则表达式是否忽略大小写
+	IgnoreCase bool `json:"ignoreCase,omitempty"`
+	// DefaultToken 没有规则匹配的文本使用的词法单元类型，为空时使用"source"
+	DefaultToken string `json:"defaultToken,omitempty"`
+	// TokenPostfix 添加到每个词法单元类型之后的后缀，例如".go"
+	TokenPostfix string `json:"tokenPostfix,omitempty"`
+	// Lists 命名的单词列表，可以在Cases中用"@名称"引用
+	Lists map[string][]string `json:"lists,omitempty"`
+	// States 所有状态的规则
+	States map[string][]Rule `json:"states"`
+}
+
+// Rule 是一个状态中的一条规则
+type Rule struct {
+	// Regex 匹配文本的正则表达式（Go regexp语法），从当前位置开始匹配
+	Regex string `json:"regex,omitempty"`
+	// Token 匹配的文本的词法单元类型
+	Token string `json:"token,omitempty"`
+	// Cases 根据匹配的文本选择词法单元类型，键为"@列表名称"或"@default"，
+	// 其他键按字面值与匹配的文本比较
+	Cases map[string]string `json:"cases,omitempty"`
+	// Next 匹配之后的状态变化：状态名称表示压入该状态，
+	// 也可以是"@pop"、"@push"或"@popall"
+	Next string `json:"next,omitempty"`
+	// Include 包含另一个状态的所有规则，设置时忽略其他字段
+	Include string `json:"include,omitempty"`
+}
+
+// Load 从JSON加载并编译词法分析器
+func Load(data []byte) (*Lexer, error) {
+	var definition Definition
+	if err := json.Unmarshal(data, &definition); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidDefinition, err)
+	}
+	return Compile(&definition)
+}
+
+// compiledRule 是编译后的规则
+type compiledRule struct {
+	regex *regexp.Regexp
+	token string
+	cases []compiledCase
+	next  string
+}
+
+// compiledCase 是编译后的一个条件
+type compiledCase struct {
+	words map[string]bool
+	token string
+}
+
+// Compile 编译词法分析器的定义
+func Compile(definition *Definition) (*Lexer, error) {
+	lexer := &Lexer{
+		name:         definition.Name,
+		start:        definition.Start,
+		defaultToken: definition.DefaultToken,
+		states:       make(map[string][]compiledRule, len(definition.States)),
+	}
+	if lexer.start == "" {
+		lexer.start = defaultStart
+	}
+	if lexer.defaultToken == "" {
+		lexer.defaultToken = defaultToken
+	}
+	if _, ok := definition.States[lexer.start]; !ok {
+		return nil, fmt.Errorf("%w: missing start state %q", ErrInvalidDefinition, lexer.start)
+	}
+
+	for name := range definition.States {
+		rules, err := compileState(definition, name, nil)
+		if err != nil {
+			return nil, err
+		}
+		lexer.states[name] = rules
+	}
+
+	return lexer, nil
+}
+
+// compileState 编译一个状态的规则，展开包含的状态
+// including记录正在展开的状态，用于检测循环包含
+func compileState(definition *Definition, name string, including []string) ([]compiledRule, error) {
+	for _, included := range including {
+		if included == name {
+			return nil, fmt.Errorf("%w: state %q includes itself", ErrInvalidDefinition, name)
+		}
+	}
+	including = append(including, name)
+
+	var rules []compiledRule
+	for index, rule := range definition.States[name] {
+		if rule.Include != "" {
+			target := strings.TrimPrefix(rule.Include, "@")
+			if _, ok := definition.States[target]; !ok {
+				return nil, fmt.Errorf("%w: state %q includes unknown state %q", ErrInvalidDefinition, name, target)
+			}
+			included, err := compileState(definition, target, including)
+			if err != nil {
+				return nil, err
+			}
+			rules = append(rules, included...)
+			continue
+		}
+
+		compiled, err := compileRule(definition, rule)
+		if err != nil {
+			return nil, fmt.Errorf("%w: state %q rule %d: %v", ErrInvalidDefinition, name, index, err)
+		}
+		rules = append(rules, compiled)
+	}
+
+	return rules, nil
+}
+
+// compileRule 编译一条规则
+func compileRule(definition *Definition, rule Rule) (compiledRule, error) {
+	if rule.Regex == "" {
+		return compiledRule{}, errors.New("missing regex")
+	}
+
+	pattern := `^(?:` + rule.Regex + `)`
+	if definition.IgnoreCase {
+		pattern = `(?i)` + pattern
+	}
+	regex, err := regexp.Compile(pattern)
+	if err != nil {
+		return compiledRule{}, err
+	}
+
+	switch next := rule.Next; {
+	case next == "", next == NextPop, next == NextPush, next == NextPopAll:
+	default:
+		if _, ok := definition.States[strings.TrimPrefix(next, "@")]; !ok {
+			return compiledRule{}, fmt.Errorf("unknown next state %q", next)
+		}
+	}
+
+	compiled := compiledRule{
+		regex: regex,
+		token: withPostfix(rule.Token, definition.TokenPostfix),
+		next:  rule.Next,
+	}
+
+	// 条件按键排序，保证匹配的顺序是确定的，"@default"总是最后使用
+	keys := make([]string, 0, len(rule.Cases))
+	for key := range rule.Cases {
+		if key != CaseDefault {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		words := make(map[string]bool)
+		if listName, ok := strings.CutPrefix(key, "@"); ok {
+			list, ok := definition.Lists[listName]
+			if !ok {
+				return compiledRule{}, fmt.Errorf("unknown list %q", listName)
+			}
+			for _, word := range list {
+				words[word] = true
+			}
+		} else {
+			words[key] = true
+		}
+		compiled.cases = append(compiled.cases, compiledCase{words: words, token: withPostfix(rule.Cases[key], definition.TokenPostfix)})
+	}
+	if token, ok := rule.Cases[CaseDefault]; ok {
+		compiled.token = withPostfix(token, definition.TokenPostfix)
+	}
+
+	return compiled, nil
+}
+
+// withPostfix 给词法单元类型添加后缀，空的类型保持为空
+func withPostfix(token, postfix string) string {
+	if token == "" || postfix == "" {
+		return token
+	}
+	return token + postfix
+}
@@ -0,0 +1,157 @@
+package monarch
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/example/gotextbuffer/textbuffer"
+)
+
+// tokenTypes 把一行的分词结果转换为"文本:类型"的列表，便于比较
+func tokenTypes(line string, tokens []textbuffer.Token) []string {
+	runes := []rune(line)
+	result := make([]string, len(tokens))
+	for i, token := range tokens {
+		end := len(runes)
+		if i+1 < len(tokens) {
+			end = tokens[i+1].StartColumn
+		}
+		result[i] = string(runes[token.StartColumn:end]) + ":" + token.Type
+	}
+	return result
+}
+
+func expectTokens(t *testing.T, lexer *Lexer, state textbuffer.TokenizerState, line string, expected ...string) textbuffer.TokenizerState {
+	t.Helper()
+	tokens, endState := lexer.Tokenize(line, state.Clone())
+	actual := tokenTypes(line, tokens)
+	if len(actual) != len(expected) {
+		t.Fatalf("Line %q: expected %q, got %q", line, expected, actual)
+	}
+	for i := range expected {
+		if actual[i] != expected[i] {
+			t.Fatalf("Line %q: expected %q, got %q", line, expected, actual)
+		}
+	}
+	return endState
+}
+
+func TestCompile(t *testing.T) {
+	lexer, err := Compile(&Definition{
+		Name:         "test",
+		TokenPostfix: ".t",
+		Lists:        map[string][]string{"keywords": {"let"}},
+		States: map[string][]Rule{
+			"root": {
+				{Regex: `\s+`, Token: "white"},
+				{Regex: `\w+`, Cases: map[string]string{"@keywords": "keyword", CaseDefault: "identifier"}},
+				{Regex: `\(`, Token: "delimiter", Next: NextPush},
+				{Regex: `\)`, Token: "delimiter", Next: NextPop},
+				{Regex: `"`, Token: "string", Next: "string"},
+			},
+			"string": {
+				{Regex: `"`, Token: "string", Next: NextPop},
+				{Include: "@chars"},
+			},
+			"chars": {
+				{Regex: `[^"]+`, Token: "string"},
+			},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Compile failed: %v", err)
+	}
+
+	state := expectTokens(t, lexer, lexer.InitialState(), `let x = "a`,
+		"let:keyword.t", " :white.t", "x:identifier.t", " :white.t", "=:source", " :white.t", `"a:string.t`)
+	if state.(*State).Current() != "string" {
+		t.Errorf("Expected to end in the string state, got %v", state)
+	}
+	state = expectTokens(t, lexer, state, `b" ((`, `b":string.t`, " :white.t", "((:delimiter.t")
+	if state.Equals(lexer.InitialState()) || len(state.(*State).stack) != 3 {
+		t.Errorf("Expected two pushed states, got %v", state)
+	}
+	state = expectTokens(t, lexer, state, "))", ")):delimiter.t")
+	if !state.Equals(lexer.InitialState()) {
+		t.Errorf("Expected the initial state, got %v", state)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	definitions := []*Definition{
+		{States: map[string][]Rule{"other": {{Regex: "a"}}}},
+		{States: map[string][]Rule{"root": {{Regex: "("}}}},
+		{States: map[string][]Rule{"root": {{Regex: "a", Next: "missing"}}}},
+		{States: map[string][]Rule{"root": {{Include: "root"}}}},
+		{States: map[string][]Rule{"root": {{Regex: "a", Cases: map[string]string{"@missing": "x"}}}}},
+	}
+	for i, definition := range definitions {
+		if _, err := Compile(definition); !errors.Is(err, ErrInvalidDefinition) {
+			t.Errorf("Definition %d: expected ErrInvalidDefinition, got %v", i, err)
+		}
+	}
+	if _, err := Load([]byte("{")); !errors.Is(err, ErrInvalidDefinition) {
+		t.Errorf("Expected ErrInvalidDefinition for malformed JSON, got %v", err)
+	}
+}
+
+func TestBuiltinGo(t *testing.T) {
+	lexer, err := Builtin("go")
+	if err != nil {
+		t.Fatalf("Builtin failed: %v", err)
+	}
+
+	state := expectTokens(t, lexer, lexer.InitialState(), `func f(s string) { /* (`,
+		"func:keyword.go", " :white.go", "f:identifier.go", "(:delimiter.bracket.go", "s:identifier.go", " :white.go",
+		"string:type.go", "):delimiter.bracket.go", " :white.go", "{:delimiter.bracket.go", " :white.go", "/* (:comment.go")
+	state = expectTokens(t, lexer, state, `*/ return "}" + 0x1F`,
+		"*/:comment.go", " :white.go", "return:keyword.go", " :white.go", `"}":string.go`, " :white.go", "+:operator.go",
+		" :white.go", "0x1F:number.go")
+	if !state.Equals(lexer.InitialState()) {
+		t.Errorf("Expected the initial state, got %v", state)
+	}
+}
+
+func TestBuiltinLanguages(t *testing.T) {
+	names := BuiltinNames()
+	if len(names) != 4 || names[0] != "go" || names[1] != "json" || names[2] != "markdown" || names[3] != "shell" {
+		t.Fatalf("Unexpected builtin names %v", names)
+	}
+	if _, err := Builtin("cobol"); err == nil {
+		t.Errorf("Expected error for unknown language")
+	}
+
+	lexer, _ := Builtin("json")
+	expectTokens(t, lexer, lexer.InitialState(), `{"a": [1.5, true]}`,
+		"{:delimiter.bracket.json", `"a":string.json`, "::delimiter.json", " :white.json", "[:delimiter.bracket.json",
+		"1.5:number.json", ",:delimiter.json", " :white.json", "true:keyword.json", "]}:delimiter.bracket.json")
+
+	lexer, _ = Builtin("markdown")
+	state := expectTokens(t, lexer, lexer.InitialState(), "# Title", "# Title:keyword.heading.md")
+	expectTokens(t, lexer, state, "Some **bold** and `code`",
+		"Some :text.md", "**bold**:strong.md", " and :text.md", "`code`:string.code.md")
+	state = expectTokens(t, lexer, state, "```go", "```go:string.code.md")
+	state = expectTokens(t, lexer, state, "# not a heading", "# not a heading:string.code.md")
+	state = expectTokens(t, lexer, state, "```", "```:string.code.md")
+	if !state.Equals(lexer.InitialState()) {
+		t.Errorf("Expected code block to end, got %v", state)
+	}
+
+	lexer, _ = Builtin("shell")
+	expectTokens(t, lexer, lexer.InitialState(), `if [ "$x" ]; then echo $(pwd) # done`,
+		"if:keyword.sh", " :white.sh", "[:delimiter.bracket.sh", " :white.sh", `":string.sh`, "$x:variable.sh",
+		`":string.sh`, " :white.sh", "]:delimiter.bracket.sh", ";:operator.sh", " :white.sh", "then:keyword.sh",
+		" :white.sh", "echo:predefined.sh", " :white.sh", "$(:delimiter.bracket.sh", "pwd:predefined.sh",
+		"):delimiter.bracket.sh", " :white.sh", "# done:comment.sh")
+}
+
+func TestLexerWithTextBuffer(t *testing.T) {
+	lexer, _ := Builtin("go")
+	buffer := textbuffer.NewTextBufferWithText("f(\")\",\n/* ) */ x)")
+	buffer.SetTokenizer(lexer)
+
+	// 字符串和注释中的括号被忽略
+	if match, ok := buffer.MatchBracket(textbuffer.Position{Line: 0, Column: 1}); !ok || !match.Equals(textbuffer.Position{Line: 1, Column: 9}) {
+		t.Errorf("Expected match at (1, 9), got %v %v", match, ok)
+	}
+}
@@ -0,0 +1,81 @@
+package ot
+
+import (
+	"github.com/example/gotextbuffer/textbuffer"
+)
+
+// BufferClient 把文本缓冲区与Client连接：本地编辑转换为操作发送给服务器，
+// 服务器的操作应用到文本缓冲区但不推入撤销栈，本地的撤销只恢复本地的编辑，跟踪的选区随之调整
+// 本地编辑和调用BufferClient的方法需要在同一个协程中进行
+type BufferClient struct {
+	buffer *textbuffer.TextBuffer
+	client *Client
+	// 文本缓冲区的长度，用于把编辑转换为操作
+	length int
+	// 是否正在应用服务器的操作，期间的内容变化不发送给服务器
+	applyingServer bool
+	// 本地编辑转换或发送失败时的第一个错误
+	err            error
+	removeListener func()
+}
+
+// NewBufferClient 连接文本缓冲区和服务器，revision是文本缓冲区内容对应的服务器版本号
+func NewBufferClient(buffer *textbuffer.TextBuffer, revision int, send func(revision int, operation *Operation)) *BufferClient {
+	bc := &BufferClient{buffer: buffer, length: buffer.GetLength()}
+	bc.client = NewClient(revision, send, bc.applyOperation)
+	bc.removeListener = buffer.OnContentChanged(bc.contentChanged)
+	return bc
+}
+
+// Client 获取客户端的状态机
+func (bc *BufferClient) Client() *Client {
+	return bc.client
+}
+
+// Err 获取处理本地编辑时发生的第一个错误
+func (bc *BufferClient) Err() error {
+	return bc.err
+}
+
+// ApplyServer 处理服务器广播的操作
+func (bc *BufferClient) ApplyServer(operation *Operation) error {
+	return bc.client.ApplyServer(operation)
+}
+
+// ServerAck 处理服务器的确认
+func (bc *BufferClient) ServerAck() error {
+	return bc.client.ServerAck()
+}
+
+// Close 停止监听文本缓冲区的编辑
+func (bc *BufferClient) Close() {
+	bc.removeListener()
+}
+
+// contentChanged 把本地编辑转换为操作交给客户端
+func (bc *BufferClient) contentChanged(event textbuffer.ContentChangeEvent) {
+	if bc.applyingServer || bc.err != nil {
+		return
+	}
+
+	operation, err := FromEdits(bc.length, event.Edits)
+	if err == nil {
+		bc.length = operation.TargetLength()
+		err = bc.client.ApplyClient(operation)
+	}
+	if err != nil {
+		bc.err = err
+	}
+}
+
+// applyOperation 把服务器的操作应用到文本缓冲区
+func (bc *BufferClient) applyOperation(operation *Operation) error {
+	bc.applyingServer = true
+	defer func() { bc.applyingServer = false }()
+
+	if err := operation.ApplyToBuffer(bc.buffer, textbuffer.EditOptions{Label: "Remote Edit", SkipUndo: true}); err != nil {
+		return err
+	}
+	bc.length = operation.TargetLength()
+	return nil
+}
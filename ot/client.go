@@ -0,0 +1,128 @@
+package ot
+
+import "errors"
+
+// ErrNoPendingOperation 表示收到确认时没有等待确认的操作
+var ErrNoPendingOperation = errors.New("no pending operation")
+
+// ClientState 表示客户端的状态
+type ClientState int
+
+const (
+	// StateSynchronized 没有等待服务器确认的操作
+	StateSynchronized ClientState = iota
+	// StateAwaitingConfirm 已经发送了一个操作，等待服务器确认
+	StateAwaitingConfirm
+	// StateAwaitingWithBuffer 等待服务器确认，同时缓冲了之后的本地操作
+	StateAwaitingWithBuffer
+)
+
+// String 获取状态的名称
+func (s ClientState) String() string {
+	switch s {
+	case StateAwaitingConfirm:
+		return "AwaitingConfirm"
+	case StateAwaitingWithBuffer:
+		return "AwaitingWithBuffer"
+	default:
+		return "Synchronized"
+	}
+}
+
+// Client 是客户端的状态机，同一时刻最多只有一个操作等待服务器确认
+// 等待期间的本地操作被合并到缓冲中，收到确认后再发送；服务器的操作先与未确认的操作转换再应用
+type Client struct {
+	// 客户端已知的服务器版本号
+	revision int
+	// 等待服务器确认的操作
+	outstanding *Operation
+	// 等待发送的本地操作
+	buffer *Operation
+	// send 把操作发送给服务器，revision是操作所基于的版本号
+	send func(revision int, operation *Operation)
+	// apply 把服务器的操作应用到本地文档
+	apply func(operation *Operation) error
+}
+
+// NewClient 创建一个客户端，revision是本地文档对应的服务器版本号
+func NewClient(revision int, send func(revision int, operation *Operation), apply func(operation *Operation) error) *Client {
+	return &Client{revision: revision, send: send, apply: apply}
+}
+
+// Revision 获取客户端已知的服务器版本号
+func (c *Client) Revision() int {
+	return c.revision
+}
+
+// State 获取客户端的状态
+func (c *Client) State() ClientState {
+	switch {
+	case c.buffer != nil:
+		return StateAwaitingWithBuffer
+	case c.outstanding != nil:
+		return StateAwaitingConfirm
+	default:
+		return StateSynchronized
+	}
+}
+
+// ApplyClient 处理一个已经应用到本地文档的操作
+func (c *Client) ApplyClient(operation *Operation) error {
+	switch c.State() {
+	case StateSynchronized:
+		c.outstanding = operation
+		c.send(c.revision, operation)
+	case StateAwaitingConfirm:
+		c.buffer = operation
+	default:
+		buffer, err := Compose(c.buffer, operation)
+		if err != nil {
+			return err
+		}
+		c.buffer = buffer
+	}
+	return nil
+}
+
+// ApplyServer 处理服务器广播的其他客户端的操作，转换后应用到本地文档
+func (c *Client) ApplyServer(operation *Operation) error {
+	if c.outstanding != nil {
+		outstanding, transformed, err := Transform(c.outstanding, operation)
+		if err != nil {
+			return err
+		}
+		if c.buffer != nil {
+			var buffer *Operation
+			if buffer, transformed, err = Transform(c.buffer, transformed); err != nil {
+				return err
+			}
+			c.buffer = buffer
+		}
+		c.outstanding = outstanding
+		operation = transformed
+	}
+
+	c.revision++
+	return c.apply(operation)
+}
+
+// ServerAck 处理服务器对等待确认的操作的确认，有缓冲的操作时发送它
+func (c *Client) ServerAck() error {
+	if c.outstanding == nil {
+		return ErrNoPendingOperation
+	}
+
+	c.revision++
+	c.outstanding, c.buffer = c.buffer, nil
+	if c.outstanding != nil {
+		c.send(c.revision, c.outstanding)
+	}
+	return nil
+}
+
+// ServerReconnect 在重新连接服务器后重新发送等待确认的操作
+func (c *Client) ServerReconnect() {
+	if c.outstanding != nil {
+		c.send(c.revision, c.outstanding)
+	}
+}
@@ -0,0 +1,299 @@
+// Package ot 实现文本的操作转换（Operational Transformation），用于多个用户通过中心服务器编辑同一个文档
+//
+// 操作由保留、插入和删除三种部分依次组成，覆盖整个文档；长度以rune为单位，与TextBuffer的偏移量一致。
+// Transform转换两个并发的操作，Compose合并两个先后的操作；Client和Server实现了ot.js中的
+// 客户端状态机（已同步、等待确认、等待确认并缓冲）和服务器，BufferClient把Client与TextBuffer连接起来
+package ot
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/example/gotextbuffer/textbuffer"
+)
+
+// ErrLengthMismatch 表示操作的长度与文档或另一个操作不一致
+var ErrLengthMismatch = errors.New("operation length mismatch")
+
+// Component 是操作的一个部分，Retain、Insert和Delete中只有一个有效
+type Component struct {
+	// Retain 保留的字符数
+	Retain int
+	// Insert 插入的文本
+	Insert string
+	// Delete 删除的字符数
+	Delete int
+}
+
+// isRetain 判断是否是保留
+func (c Component) isRetain() bool {
+	return c.Retain > 0
+}
+
+// isInsert 判断是否是插入
+func (c Component) isInsert() bool {
+	return c.Insert != ""
+}
+
+// isDelete 判断是否是删除
+func (c Component) isDelete() bool {
+	return c.Delete > 0
+}
+
+// Operation 是对整个文档的一次操作
+// 相邻的同类部分会被合并，插入总是位于相邻的删除之前，因此效果相同的操作有相同的表示
+type Operation struct {
+	components []Component
+	// 应用操作之前文档的长度
+	baseLength int
+	// 应用操作之后文档的长度
+	targetLength int
+}
+
+// NewOperation 创建一个空的操作，之后依次添加保留、插入和删除
+func NewOperation() *Operation {
+	return &Operation{}
+}
+
+// Components 获取操作的各个部分
+func (o *Operation) Components() []Component {
+	return append([]Component(nil), o.components...)
+}
+
+// BaseLength 获取应用操作之前文档的长度
+func (o *Operation) BaseLength() int {
+	return o.baseLength
+}
+
+// TargetLength 获取应用操作之后文档的长度
+func (o *Operation) TargetLength() int {
+	return o.targetLength
+}
+
+// Retain 保留n个字符
+func (o *Operation) Retain(n int) *Operation {
+	if n <= 0 {
+		return o
+	}
+	o.baseLength += n
+	o.targetLength += n
+	if last := len(o.components) - 1; last >= 0 && o.components[last].isRetain() {
+		o.components[last].Retain += n
+	} else {
+		o.components = append(o.components, Component{Retain: n})
+	}
+	return o
+}
+
+// Insert 插入文本
+func (o *Operation) Insert(text string) *Operation {
+	if text == "" {
+		return o
+	}
+	o.targetLength += utf8.RuneCountInString(text)
+
+	last := len(o.components) - 1
+	switch {
+	case last >= 0 && o.components[last].isInsert():
+		o.components[last].Insert += text
+	case last >= 0 && o.components[last].isDelete():
+		// 插入放在删除之前
+		if last > 0 && o.components[last-1].isInsert() {
+			o.components[last-1].Insert += text
+		} else {
+			o.components = append(o.components, o.components[last])
+			o.components[last] = Component{Insert: text}
+		}
+	default:
+		o.components = append(o.components, Component{Insert: text})
+	}
+	return o
+}
+
+// Delete 删除n个字符
+func (o *Operation) Delete(n int) *Operation {
+	if n <= 0 {
+		return o
+	}
+	o.baseLength += n
+	if last := len(o.components) - 1; last >= 0 && o.components[last].isDelete() {
+		o.components[last].Delete += n
+	} else {
+		o.components = append(o.components, Component{Delete: n})
+	}
+	return o
+}
+
+// add 添加一个部分
+func (o *Operation) add(c Component) *Operation {
+	switch {
+	case c.isRetain():
+		return o.Retain(c.Retain)
+	case c.isInsert():
+		return o.Insert(c.Insert)
+	default:
+		return o.Delete(c.Delete)
+	}
+}
+
+// IsNoop 判断操作是否不改变文档
+func (o *Operation) IsNoop() bool {
+	return len(o.components) == 0 || (len(o.components) == 1 && o.components[0].isRetain())
+}
+
+// Equals 判断两个操作是否相同
+func (o *Operation) Equals(other *Operation) bool {
+	if o.baseLength != other.baseLength || o.targetLength != other.targetLength || len(o.components) != len(other.components) {
+		return false
+	}
+	for i, c := range o.components {
+		if c != other.components[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// String 获取操作的可读表示
+func (o *Operation) String() string {
+	parts := make([]string, len(o.components))
+	for i, c := range o.components {
+		switch {
+		case c.isRetain():
+			parts[i] = fmt.Sprintf("retain %d", c.Retain)
+		case c.isInsert():
+			parts[i] = fmt.Sprintf("insert %q", c.Insert)
+		default:
+			parts[i] = fmt.Sprintf("delete %d", c.Delete)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Apply 把操作应用到文本上
+func (o *Operation) Apply(text string) (string, error) {
+	runes := []rune(text)
+	if len(runes) != o.baseLength {
+		return "", fmt.Errorf("%w: operation expects length %d, text has %d", ErrLengthMismatch, o.baseLength, len(runes))
+	}
+
+	var builder strings.Builder
+	index := 0
+	for _, c := range o.components {
+		switch {
+		case c.isRetain():
+			builder.WriteString(string(runes[index : index+c.Retain]))
+			index += c.Retain
+		case c.isInsert():
+			builder.WriteString(c.Insert)
+		default:
+			index += c.Delete
+		}
+	}
+	return builder.String(), nil
+}
+
+// EditOperations 把操作转换为基于应用之前文档的编辑，可以传给TextBuffer.ApplyEdits
+// 相邻的插入和删除合并为一个替换
+func (o *Operation) EditOperations(buffer *textbuffer.TextBuffer) []textbuffer.EditOperation {
+	var operations []textbuffer.EditOperation
+	offset := 0
+	for i := 0; i < len(o.components); i++ {
+		c := o.components[i]
+		if c.isRetain() {
+			offset += c.Retain
+			continue
+		}
+
+		text, length := c.Insert, c.Delete
+		if c.isInsert() && i+1 < len(o.components) && o.components[i+1].isDelete() {
+			i++
+			length = o.components[i].Delete
+		}
+		operations = append(operations, textbuffer.EditOperation{
+			Range: textbuffer.NewRange(buffer.GetPositionAt(offset), buffer.GetPositionAt(offset+length)),
+			Text:  text,
+		})
+		offset += length
+	}
+	return operations
+}
+
+// ApplyToBuffer 按options把操作作为一个操作应用到文本缓冲区
+// 调用方需要保证期间没有其他的编辑
+func (o *Operation) ApplyToBuffer(buffer *textbuffer.TextBuffer, options textbuffer.EditOptions) error {
+	if length := buffer.GetLength(); length != o.baseLength {
+		return fmt.Errorf("%w: operation expects length %d, buffer has %d", ErrLengthMismatch, o.baseLength, length)
+	}
+	if o.IsNoop() {
+		return nil
+	}
+	return buffer.ApplyEditsWithOptions(o.EditOperations(buffer), options)
+}
+
+// FromEdits 把一组按顺序应用的编辑转换为一个操作，例如TextBuffer内容变化事件中的编辑
+// baseLength是应用编辑之前文档的长度
+func FromEdits(baseLength int, edits []textbuffer.Edit) (*Operation, error) {
+	operation := NewOperation().Retain(baseLength)
+	length := baseLength
+	for _, edit := range edits {
+		oldLength := utf8.RuneCountInString(edit.OldText)
+		if edit.Offset < 0 || edit.Offset+oldLength > length {
+			return nil, fmt.Errorf("%w: edit at offset %d exceeds length %d", ErrLengthMismatch, edit.Offset, length)
+		}
+		single := NewOperation().Retain(edit.Offset).Delete(oldLength).Insert(edit.NewText).Retain(length - edit.Offset - oldLength)
+
+		var err error
+		if operation, err = Compose(operation, single); err != nil {
+			return nil, err
+		}
+		length = operation.targetLength
+	}
+	return operation, nil
+}
+
+// MarshalJSON 按ot.js的格式编码操作：正数表示保留，负数表示删除，字符串表示插入
+func (o *Operation) MarshalJSON() ([]byte, error) {
+	values := make([]any, len(o.components))
+	for i, c := range o.components {
+		switch {
+		case c.isRetain():
+			values[i] = c.Retain
+		case c.isInsert():
+			values[i] = c.Insert
+		default:
+			values[i] = -c.Delete
+		}
+	}
+	return json.Marshal(values)
+}
+
+// UnmarshalJSON 解码ot.js格式的操作
+func (o *Operation) UnmarshalJSON(data []byte) error {
+	var values []json.RawMessage
+	if err := json.Unmarshal(data, &values); err != nil {
+		return err
+	}
+
+	*o = Operation{}
+	for _, value := range values {
+		var text string
+		if err := json.Unmarshal(value, &text); err == nil {
+			o.Insert(text)
+			continue
+		}
+		var n int
+		if err := json.Unmarshal(value, &n); err != nil || n == 0 {
+			return fmt.Errorf("invalid operation component %s", value)
+		}
+		if n > 0 {
+			o.Retain(n)
+		} else {
+			o.Delete(-n)
+		}
+	}
+	return nil
+}
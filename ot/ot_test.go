@@ -0,0 +1,356 @@
+package ot
+
+import (
+	"encoding/json"
+	"math/rand"
+	"testing"
+
+	"github.com/example/gotextbuffer/textbuffer"
+)
+
+// randomText 生成随机文本，包含多字节字符和换行
+func randomText(random *rand.Rand, maxLength int) string {
+	alphabet := []rune("abc xyz\n日本")
+	runes := make([]rune, random.Intn(maxLength+1))
+	for i := range runes {
+		runes[i] = alphabet[random.Intn(len(alphabet))]
+	}
+	return string(runes)
+}
+
+// randomOperation 生成一个可以应用到文本上的随机操作
+func randomOperation(random *rand.Rand, text string) *Operation {
+	operation := NewOperation()
+	remaining := len([]rune(text))
+	for remaining > 0 {
+		n := 1 + random.Intn(min(remaining, 5))
+		switch random.Intn(3) {
+		case 0:
+			operation.Retain(n)
+			remaining -= n
+		case 1:
+			operation.Insert(randomText(random, 4))
+		default:
+			operation.Delete(n)
+			remaining -= n
+		}
+	}
+	if random.Intn(2) == 0 {
+		operation.Insert(randomText(random, 4))
+	}
+	return operation
+}
+
+func TestOperationBuilder(t *testing.T) {
+	operation := NewOperation().Retain(2).Retain(1).Delete(2).Insert("ab").Insert("c").Delete(1).Insert("日")
+	expected := []Component{{Retain: 3}, {Insert: "abc日"}, {Delete: 3}}
+	components := operation.Components()
+	if len(components) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, operation)
+	}
+	for i := range expected {
+		if components[i] != expected[i] {
+			t.Errorf("Expected %v, got %v", expected, operation)
+		}
+	}
+	if operation.BaseLength() != 6 || operation.TargetLength() != 7 {
+		t.Errorf("Unexpected lengths %d, %d", operation.BaseLength(), operation.TargetLength())
+	}
+
+	text, err := operation.Apply("123456")
+	if err != nil || text != "123abc日" {
+		t.Errorf("Unexpected result '%s', %v", text, err)
+	}
+	if _, err := operation.Apply("12345"); err == nil {
+		t.Errorf("Expected a length mismatch")
+	}
+
+	// ot.js的JSON格式
+	data, _ := json.Marshal(operation)
+	if string(data) != `[3,"abc日",-3]` {
+		t.Errorf("Unexpected JSON %s", data)
+	}
+	decoded := NewOperation()
+	if err := json.Unmarshal(data, decoded); err != nil || !decoded.Equals(operation) {
+		t.Errorf("Unexpected decoded operation %v, %v", decoded, err)
+	}
+	if !NewOperation().Retain(3).IsNoop() || operation.IsNoop() {
+		t.Errorf("Unexpected IsNoop")
+	}
+}
+
+func TestFromEditsAndApplyToBuffer(t *testing.T) {
+	buffer := textbuffer.NewTextBufferWithText("hello\nworld")
+	var operations []*Operation
+	length := buffer.GetLength()
+	buffer.OnContentChanged(func(event textbuffer.ContentChangeEvent) {
+		operation, err := FromEdits(length, event.Edits)
+		if err != nil {
+			t.Fatalf("FromEdits failed: %v", err)
+		}
+		length = operation.TargetLength()
+		operations = append(operations, operation)
+	})
+
+	buffer.ApplyEdits([]textbuffer.EditOperation{
+		{Range: textbuffer.NewRange(textbuffer.Position{Line: 0, Column: 0}, textbuffer.Position{Line: 0, Column: 1}), Text: "J"},
+		{Range: textbuffer.NewRange(textbuffer.Position{Line: 1, Column: 5}, textbuffer.Position{Line: 1, Column: 5}), Text: "!"},
+	}, nil, nil)
+	buffer.Delete(textbuffer.NewRange(textbuffer.Position{Line: 0, Column: 5}, textbuffer.Position{Line: 1, Column: 0}))
+
+	// 在另一个文本缓冲区上重放操作
+	replica := textbuffer.NewTextBufferWithText("hello\nworld")
+	for _, operation := range operations {
+		if err := operation.ApplyToBuffer(replica, textbuffer.EditOptions{}); err != nil {
+			t.Fatalf("ApplyToBuffer failed: %v", err)
+		}
+	}
+	if replica.GetText() != "Jelloworld!" || replica.GetText() != buffer.GetText() {
+		t.Errorf("Expected '%s', got '%s'", buffer.GetText(), replica.GetText())
+	}
+}
+
+func TestTransformTieBreaking(t *testing.T) {
+	a := NewOperation().Retain(1).Insert("a").Retain(1)
+	b := NewOperation().Retain(1).Insert("b").Retain(1)
+	aPrime, bPrime, err := Transform(a, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	left, _ := a.Apply("xy")
+	left, _ = bPrime.Apply(left)
+	right, _ := b.Apply("xy")
+	right, _ = aPrime.Apply(right)
+	if left != "xaby" || right != left {
+		t.Errorf("Expected 'xaby' on both sides, got '%s' and '%s'", left, right)
+	}
+}
+
+func TestComposeAndTransformRandom(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	for iteration := 0; iteration < 500; iteration++ {
+		text := randomText(random, 20)
+
+		// 合并的操作等于依次应用两个操作
+		a := randomOperation(random, text)
+		afterA, err := a.Apply(text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b := randomOperation(random, afterA)
+		composed, err := Compose(a, b)
+		if err != nil {
+			t.Fatalf("Compose failed: %v", err)
+		}
+		expected, _ := b.Apply(afterA)
+		if actual, _ := composed.Apply(text); actual != expected {
+			t.Fatalf("Compose(%v, %v) on %q: expected %q, got %q", a, b, text, expected, actual)
+		}
+
+		// 转换后两种顺序得到相同的文档
+		c := randomOperation(random, text)
+		aPrime, cPrime, err := Transform(a, c)
+		if err != nil {
+			t.Fatalf("Transform failed: %v", err)
+		}
+		left, err1 := cPrime.Apply(afterA)
+		afterC, _ := c.Apply(text)
+		right, err2 := aPrime.Apply(afterC)
+		if err1 != nil || err2 != nil || left != right {
+			t.Fatalf("Transform(%v, %v) on %q diverged: %q vs %q (%v, %v)", a, c, text, left, right, err1, err2)
+		}
+	}
+
+	if _, err := Compose(NewOperation().Retain(1), NewOperation().Retain(2)); err == nil {
+		t.Errorf("Expected a length mismatch")
+	}
+	if _, _, err := Transform(NewOperation().Retain(1), NewOperation().Retain(2)); err == nil {
+		t.Errorf("Expected a length mismatch")
+	}
+}
+
+func TestClientStates(t *testing.T) {
+	var sent []*Operation
+	var applied []*Operation
+	client := NewClient(0, func(revision int, operation *Operation) {
+		sent = append(sent, operation)
+	}, func(operation *Operation) error {
+		applied = append(applied, operation)
+		return nil
+	})
+
+	// 文档"ab"
+	client.ApplyClient(NewOperation().Insert("x").Retain(2))
+	if client.State() != StateAwaitingConfirm || len(sent) != 1 {
+		t.Fatalf("Expected AwaitingConfirm, got %s", client.State())
+	}
+	client.ApplyClient(NewOperation().Retain(3).Insert("y"))
+	client.ApplyClient(NewOperation().Retain(4).Insert("z"))
+	if client.State() != StateAwaitingWithBuffer || len(sent) != 1 {
+		t.Fatalf("Expected AwaitingWithBuffer, got %s", client.State())
+	}
+
+	// 服务器的操作与未确认和缓冲的操作转换
+	client.ApplyServer(NewOperation().Retain(1).Insert("S").Retain(1))
+	if len(applied) != 1 || applied[0].BaseLength() != 5 || client.Revision() != 1 {
+		t.Errorf("Unexpected applied operation %v", applied)
+	}
+
+	client.ServerAck()
+	if client.State() != StateAwaitingConfirm || len(sent) != 2 || sent[1].BaseLength() != 4 {
+		t.Errorf("Expected the buffer to be sent, got %s, %v", client.State(), sent)
+	}
+	client.ServerAck()
+	if client.State() != StateSynchronized || client.Revision() != 3 {
+		t.Errorf("Expected Synchronized at revision 3, got %s at %d", client.State(), client.Revision())
+	}
+	if err := client.ServerAck(); err != ErrNoPendingOperation {
+		t.Errorf("Expected ErrNoPendingOperation, got %v", err)
+	}
+}
+
+// message 是服务器发给客户端的消息
+type message struct {
+	operation *Operation
+	ack       bool
+}
+
+// request 是客户端发给服务器的操作
+type request struct {
+	revision  int
+	operation *Operation
+}
+
+func TestCollaborationConverges(t *testing.T) {
+	random := rand.New(rand.NewSource(7))
+	for iteration := 0; iteration < 30; iteration++ {
+		initial := randomText(random, 20)
+		server := NewServer(textbuffer.NewTextBufferWithText(initial))
+
+		const clientCount = 3
+		buffers := make([]*textbuffer.TextBuffer, clientCount)
+		clients := make([]*BufferClient, clientCount)
+		outbox := make([][]request, clientCount)
+		inbox := make([][]message, clientCount)
+		for i := range clients {
+			buffers[i] = textbuffer.NewTextBufferWithText(initial)
+			clients[i] = NewBufferClient(buffers[i], 0, func(revision int, operation *Operation) {
+				outbox[i] = append(outbox[i], request{revision, operation})
+			})
+		}
+
+		// 服务器处理一个客户端的请求，向发送者确认，向其他客户端广播
+		deliverToServer := func(i int) {
+			r := outbox[i][0]
+			outbox[i] = outbox[i][1:]
+			operation, err := server.ReceiveOperation(r.revision, r.operation)
+			if err != nil {
+				t.Fatalf("ReceiveOperation failed: %v", err)
+			}
+			for j := range inbox {
+				inbox[j] = append(inbox[j], message{operation: operation, ack: j == i})
+			}
+		}
+		deliverToClient := func(i int) {
+			m := inbox[i][0]
+			inbox[i] = inbox[i][1:]
+			var err error
+			if m.ack {
+				err = clients[i].ServerAck()
+			} else {
+				err = clients[i].ApplyServer(m.operation)
+			}
+			if err != nil {
+				t.Fatalf("Client %d failed: %v", i, err)
+			}
+		}
+
+		for step := 0; step < 100; step++ {
+			i := random.Intn(clientCount)
+			switch random.Intn(3) {
+			case 0:
+				// 本地编辑，撤销只恢复本地的编辑
+				if random.Intn(4) == 0 {
+					buffers[i].Undo()
+					break
+				}
+				text := buffers[i].GetText()
+				length := len([]rune(text))
+				start := random.Intn(length + 1)
+				end := start + random.Intn(min(length-start, 3)+1)
+				buffers[i].Replace(textbuffer.NewRange(buffers[i].GetPositionAt(start), buffers[i].GetPositionAt(end)), randomText(random, 3))
+			case 1:
+				if len(outbox[i]) > 0 {
+					deliverToServer(i)
+				}
+			default:
+				if len(inbox[i]) > 0 {
+					deliverToClient(i)
+				}
+			}
+		}
+
+		// 投递所有剩余的消息
+		for pending := true; pending; {
+			pending = false
+			for i := range clients {
+				for len(outbox[i]) > 0 {
+					deliverToServer(i)
+					pending = true
+				}
+				for len(inbox[i]) > 0 {
+					deliverToClient(i)
+					pending = true
+				}
+			}
+		}
+
+		expected := server.Buffer().GetText()
+		for i, buffer := range buffers {
+			if err := clients[i].Err(); err != nil {
+				t.Fatalf("Client %d failed: %v", i, err)
+			}
+			if buffer.GetText() != expected || clients[i].Client().State() != StateSynchronized {
+				t.Fatalf("Client %d diverged: expected %q, got %q (%s)", i, expected, buffer.GetText(), clients[i].Client().State())
+			}
+			if clients[i].Client().Revision() != server.Revision() {
+				t.Errorf("Client %d is at revision %d, server at %d", i, clients[i].Client().Revision(), server.Revision())
+			}
+		}
+		if server.Revision() == 0 {
+			t.Errorf("Expected the clients to send operations")
+		}
+	}
+}
+
+func TestBufferClientUndoKeepsRemoteEdits(t *testing.T) {
+	buffer := textbuffer.NewTextBuffer()
+	var sent []*Operation
+	client := NewBufferClient(buffer, 0, func(revision int, operation *Operation) {
+		sent = append(sent, operation)
+	})
+	defer client.Close()
+
+	buffer.Insert(textbuffer.Position{Line: 0, Column: 0}, "hello")
+	if err := client.ServerAck(); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.ApplyServer(NewOperation().Retain(5).Insert(" world")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := buffer.Undo(); err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+	if buffer.GetText() != " world" {
+		t.Errorf("Expected ' world', got '%s'", buffer.GetText())
+	}
+	if buffer.CanUndo() {
+		t.Errorf("Expected the remote edit not to be undoable")
+	}
+	expected := NewOperation().Delete(5).Retain(6)
+	if len(sent) != 2 || !sent[1].Equals(expected) {
+		t.Errorf("Expected the undo to be sent as %s, got %v", expected, sent)
+	}
+}
@@ -0,0 +1,60 @@
+package ot
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/example/gotextbuffer/textbuffer"
+)
+
+// ErrInvalidRevision 表示操作基于的版本号不存在
+var ErrInvalidRevision = errors.New("invalid revision")
+
+// Server 保存文档和所有已应用的操作，把客户端基于旧版本的操作转换到最新版本
+type Server struct {
+	mutex      sync.Mutex
+	buffer     *textbuffer.TextBuffer
+	operations []*Operation
+}
+
+// NewServer 创建一个服务器，文本缓冲区的内容是版本0的文档
+func NewServer(buffer *textbuffer.TextBuffer) *Server {
+	return &Server{buffer: buffer}
+}
+
+// Revision 获取文档的当前版本号，即已应用的操作数量
+func (s *Server) Revision() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.operations)
+}
+
+// Buffer 获取服务器的文本缓冲区
+func (s *Server) Buffer() *textbuffer.TextBuffer {
+	return s.buffer
+}
+
+// ReceiveOperation 处理客户端基于revision版本的操作
+// 操作与之后的所有操作转换后应用到文档，返回转换后的操作，它需要广播给其他客户端，
+// 并向发送者确认
+func (s *Server) ReceiveOperation(revision int, operation *Operation) (*Operation, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if revision < 0 || revision > len(s.operations) {
+		return nil, fmt.Errorf("%w: %d (current revision is %d)", ErrInvalidRevision, revision, len(s.operations))
+	}
+	for _, concurrent := range s.operations[revision:] {
+		var err error
+		if operation, _, err = Transform(operation, concurrent); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := operation.ApplyToBuffer(s.buffer, textbuffer.EditOptions{Label: "Remote Edit", SkipUndo: true}); err != nil {
+		return nil, err
+	}
+	s.operations = append(s.operations, operation)
+	return operation, nil
+}
@@ -0,0 +1,167 @@
+package ot
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// cursor 依次读取操作的各个部分，可以只消耗一个部分的前一段
+type cursor struct {
+	components []Component
+	index      int
+	// 当前部分已经消耗的长度
+	consumed int
+}
+
+// current 获取当前部分未消耗的剩余部分，没有剩余部分时返回false
+func (c *cursor) current() (Component, bool) {
+	if c.index >= len(c.components) {
+		return Component{}, false
+	}
+	component := c.components[c.index]
+	switch {
+	case component.isRetain():
+		component.Retain -= c.consumed
+	case component.isInsert():
+		component.Insert = string([]rune(component.Insert)[c.consumed:])
+	default:
+		component.Delete -= c.consumed
+	}
+	return component, true
+}
+
+// consume 消耗当前部分的n个字符，当前部分被消耗完时移动到下一个部分
+func (c *cursor) consume(n int) {
+	c.consumed += n
+	if c.consumed >= componentLength(c.components[c.index]) {
+		c.index++
+		c.consumed = 0
+	}
+}
+
+// componentLength 获取一个部分的长度
+func componentLength(c Component) int {
+	switch {
+	case c.isRetain():
+		return c.Retain
+	case c.isInsert():
+		return utf8.RuneCountInString(c.Insert)
+	default:
+		return c.Delete
+	}
+}
+
+// prefix 获取一个部分的前n个字符
+func prefix(c Component, n int) Component {
+	switch {
+	case c.isRetain():
+		return Component{Retain: n}
+	case c.isInsert():
+		return Component{Insert: string([]rune(c.Insert)[:n])}
+	default:
+		return Component{Delete: n}
+	}
+}
+
+// Compose 合并两个先后应用的操作，结果等于先应用a再应用b
+// b的BaseLength必须等于a的TargetLength
+func Compose(a, b *Operation) (*Operation, error) {
+	if a.targetLength != b.baseLength {
+		return nil, fmt.Errorf("%w: cannot compose operation with target length %d and operation with base length %d",
+			ErrLengthMismatch, a.targetLength, b.baseLength)
+	}
+
+	result := NewOperation()
+	first := &cursor{components: a.components}
+	second := &cursor{components: b.components}
+	for {
+		c1, ok1 := first.current()
+		c2, ok2 := second.current()
+		if !ok1 && !ok2 {
+			return result, nil
+		}
+
+		// a的删除和b的插入不依赖另一个操作
+		if ok1 && c1.isDelete() {
+			result.add(c1)
+			first.consume(c1.Delete)
+			continue
+		}
+		if ok2 && c2.isInsert() {
+			result.add(c2)
+			second.consume(componentLength(c2))
+			continue
+		}
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("%w: operations have different lengths", ErrLengthMismatch)
+		}
+
+		// 剩下的情况中，a是保留或插入，b是保留或删除，按较短的一方推进
+		n := min(componentLength(c1), componentLength(c2))
+		switch {
+		case c1.isRetain() && c2.isRetain():
+			result.Retain(n)
+		case c1.isRetain() && c2.isDelete():
+			result.Delete(n)
+		case c1.isInsert() && c2.isRetain():
+			result.add(prefix(c1, n))
+		}
+		// a插入而b删除的文本互相抵消
+		first.consume(n)
+		second.consume(n)
+	}
+}
+
+// Transform 转换两个基于同一文档的并发操作，返回a'和b'，
+// 使先应用a再应用b'与先应用b再应用a'得到相同的文档
+// 两个操作在同一位置插入时，a的插入在前
+func Transform(a, b *Operation) (*Operation, *Operation, error) {
+	if a.baseLength != b.baseLength {
+		return nil, nil, fmt.Errorf("%w: concurrent operations have base lengths %d and %d",
+			ErrLengthMismatch, a.baseLength, b.baseLength)
+	}
+
+	aPrime, bPrime := NewOperation(), NewOperation()
+	first := &cursor{components: a.components}
+	second := &cursor{components: b.components}
+	for {
+		c1, ok1 := first.current()
+		c2, ok2 := second.current()
+		if !ok1 && !ok2 {
+			return aPrime, bPrime, nil
+		}
+
+		// 插入在另一个操作中表现为保留
+		if ok1 && c1.isInsert() {
+			length := componentLength(c1)
+			aPrime.Insert(c1.Insert)
+			bPrime.Retain(length)
+			first.consume(length)
+			continue
+		}
+		if ok2 && c2.isInsert() {
+			length := componentLength(c2)
+			aPrime.Retain(length)
+			bPrime.Insert(c2.Insert)
+			second.consume(length)
+			continue
+		}
+		if !ok1 || !ok2 {
+			return nil, nil, fmt.Errorf("%w: operations have different lengths", ErrLengthMismatch)
+		}
+
+		n := min(componentLength(c1), componentLength(c2))
+		switch {
+		case c1.isRetain() && c2.isRetain():
+			aPrime.Retain(n)
+			bPrime.Retain(n)
+		case c1.isDelete() && c2.isRetain():
+			aPrime.Delete(n)
+		case c1.isRetain() && c2.isDelete():
+			bPrime.Delete(n)
+		}
+		// 两个操作都删除的文本不需要再删除
+		first.consume(n)
+		second.consume(n)
+	}
+}
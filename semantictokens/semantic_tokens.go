@@ -0,0 +1,530 @@
+// Package semantictokens 将文本缓冲区的分词结果编码为LSP的语义词法单元（semantic tokens）
+//
+// 每个词法单元编码为5个整数：与上一个词法单元的行号差、起始字符差（同一行时相对于上一个词法单元）、
+// 长度、类型在图例中的索引和修饰符的位集合。编码结果带有结果标识，
+// 之后可以计算相对于某个结果的增量编辑，只发送变化的部分
+//
+// Provider按行缓存每个文本缓冲区的编码结果，通过文本缓冲区的内容变化事件和分词结果变化事件
+// 跟踪需要重新编码的行，每次请求只重新编码这些行
+package semantictokens
+
+import (
+	"math"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/example/gotextbuffer/textbuffer"
+)
+
+// PositionEncoding 表示字符位置和长度的单位
+type PositionEncoding int
+
+const (
+	// EncodingUTF16 以UTF-16代码单元为单位，这是LSP的默认编码
+	EncodingUTF16 PositionEncoding = iota
+	// EncodingUTF32 以Unicode码点为单位
+	EncodingUTF32
+	// EncodingUTF8 以UTF-8字节为单位
+	EncodingUTF8
+)
+
+// maxResults 是保留的编码结果数量，用于计算增量编辑
+const maxResults = 16
+
+// maxSyncAttempts 是与文本缓冲区同步缓存的最多尝试次数，
+// 其他goroutine持续修改文本时，超出次数后不使用缓存直接编码所有行
+const maxSyncAttempts = 8
+
+// Legend 是语义词法单元的图例，编码时使用类型和修饰符在图例中的索引
+type Legend struct {
+	// TokenTypes 词法单元类型
+	TokenTypes []string `json:"tokenTypes"`
+	// TokenModifiers 词法单元修饰符，第i个修饰符对应位集合的第i位
+	TokenModifiers []string `json:"tokenModifiers"`
+}
+
+// DefaultLegend 获取使用LSP标准类型和修饰符的图例
+func DefaultLegend() Legend {
+	return Legend{
+		TokenTypes: []string{
+			"namespace", "type", "class", "enum", "interface", "struct", "typeParameter",
+			"parameter", "variable", "property", "enumMember", "event", "function", "method",
+			"macro", "keyword", "modifier", "comment", "string", "number", "regexp", "operator",
+			"decorator",
+		},
+		TokenModifiers: []string{
+			"declaration", "definition", "readonly", "static", "deprecated", "abstract",
+			"async", "modification", "documentation", "defaultLibrary",
+		},
+	}
+}
+
+// SemanticTokens 是一次完整的编码结果
+type SemanticTokens struct {
+	// ResultID 结果标识，用于之后计算增量编辑
+	ResultID string `json:"resultId,omitempty"`
+	// Data 编码后的词法单元，每5个整数表示一个词法单元
+	Data []uint32 `json:"data"`
+}
+
+// SemanticTokensEdit 是对之前结果的Data的一次编辑
+type SemanticTokensEdit struct {
+	// Start 编辑在Data中的起始索引
+	Start uint32 `json:"start"`
+	// DeleteCount 删除的整数个数
+	DeleteCount uint32 `json:"deleteCount"`
+	// Data 插入的整数
+	Data []uint32 `json:"data,omitempty"`
+}
+
+// SemanticTokensDelta 是相对于之前结果的增量编辑
+type SemanticTokensDelta struct {
+	// ResultID 新结果的标识
+	ResultID string `json:"resultId,omitempty"`
+	// Edits 把之前结果的Data变为新结果的Data的编辑
+	Edits []SemanticTokensEdit `json:"edits"`
+}
+
+// Provider 编码文本缓冲区的分词结果，并保留最近的结果用于计算增量编辑
+// Provider可以在多个goroutine中使用；不再使用一个文本缓冲区时应调用Detach
+type Provider struct {
+	legend    Legend
+	encoding  PositionEncoding
+	types     map[string]int
+	mutex     sync.Mutex
+	nextID    uint64
+	results   map[string][]uint32
+	order     []string
+	documents map[*textbuffer.TextBuffer]*document
+}
+
+// document 是一个文本缓冲区按行缓存的编码结果
+// 每一行的每个词法单元编码为4个整数：起始字符、长度、类型索引和修饰符，
+// 与上一个词法单元的差在拼接所有行时计算，这样一行的变化不影响其他行的缓存
+type document struct {
+	// lines 每一行的编码结果，长度与文本的行数一致
+	lines [][]uint32
+	// version 缓存对应的文本版本号
+	version int
+	// latest 收到的事件中最新的版本号
+	latest int
+	// [dirtyStart, dirtyEnd)范围内的行需要重新编码
+	dirtyStart int
+	dirtyEnd   int
+	// resync 缓存无法增量更新，需要重新编码所有行
+	resync bool
+	// removeListeners 移除注册在文本缓冲区上的监听器
+	removeListeners func()
+}
+
+// NewProvider 创建一个使用指定图例和位置编码的Provider
+func NewProvider(legend Legend, encoding PositionEncoding) *Provider {
+	types := make(map[string]int, len(legend.TokenTypes))
+	for i, tokenType := range legend.TokenTypes {
+		types[tokenType] = i
+	}
+	return &Provider{
+		legend:    legend,
+		encoding:  encoding,
+		types:     types,
+		results:   make(map[string][]uint32),
+		documents: make(map[*textbuffer.TextBuffer]*document),
+	}
+}
+
+// Legend 获取Provider使用的图例
+func (p *Provider) Legend() Legend {
+	return p.legend
+}
+
+// Classify 获取分词结果中的类型对应的图例类型索引和修饰符位集合
+// 类型用"."分隔，最长的在图例中存在的前缀作为类型，其余部分中在图例中存在的作为修饰符；
+// 没有前缀在图例中时返回false，这样的词法单元不会被编码
+func (p *Provider) Classify(tokenType string) (int, uint32, bool) {
+	segments := strings.Split(tokenType, ".")
+	for length := len(segments); length > 0; length-- {
+		typeIndex, ok := p.types[strings.Join(segments[:length], ".")]
+		if !ok {
+			continue
+		}
+		var modifiers uint32
+		for _, segment := range segments[length:] {
+			for i, modifier := range p.legend.TokenModifiers {
+				if modifier == segment && i < 32 {
+					modifiers |= 1 << i
+				}
+			}
+		}
+		return typeIndex, modifiers, true
+	}
+	return 0, 0, false
+}
+
+// Encode 将分词结果编码为语义词法单元的数据
+func (p *Provider) Encode(lines []textbuffer.TokenizedLine) []uint32 {
+	encoded := make([][]uint32, len(lines))
+	for i, line := range lines {
+		encoded[i] = p.encodeLine(line)
+	}
+	return joinLines(encoded)
+}
+
+// encodeLine 编码一行的词法单元，每个词法单元为起始字符、长度、类型索引和修饰符
+func (p *Provider) encodeLine(line textbuffer.TokenizedLine) []uint32 {
+	var data []uint32
+	runes := []rune(line.Text)
+	for i, token := range line.Tokens {
+		typeIndex, modifiers, ok := p.Classify(token.Type)
+		if !ok {
+			continue
+		}
+
+		start := min(token.StartColumn, len(runes))
+		end := len(runes)
+		if i+1 < len(line.Tokens) {
+			end = min(line.Tokens[i+1].StartColumn, len(runes))
+		}
+		if end <= start {
+			continue
+		}
+
+		data = append(data,
+			uint32(p.length(runes[:start])),
+			uint32(p.length(runes[start:end])),
+			uint32(typeIndex),
+			modifiers,
+		)
+	}
+	return data
+}
+
+// joinLines 把每一行的编码结果拼接为语义词法单元的数据，计算与上一个词法单元的差
+func joinLines(lines [][]uint32) []uint32 {
+	count := 0
+	for _, line := range lines {
+		count += len(line) / 4
+	}
+
+	data := make([]uint32, 0, count*5)
+	previousLine := 0
+	var previousStart uint32
+	for lineIndex, line := range lines {
+		for i := 0; i+4 <= len(line); i += 4 {
+			character := line[i]
+			deltaStart := character
+			if lineIndex == previousLine {
+				deltaStart = character - previousStart
+			}
+			data = append(data, uint32(lineIndex-previousLine), deltaStart, line[i+1], line[i+2], line[i+3])
+			previousLine = lineIndex
+			previousStart = character
+		}
+	}
+	return data
+}
+
+// length 按位置编码计算字符的长度
+func (p *Provider) length(runes []rune) int {
+	switch p.encoding {
+	case EncodingUTF32:
+		return len(runes)
+	case EncodingUTF8:
+		length := 0
+		for _, r := range runes {
+			length += utf8.RuneLen(r)
+		}
+		return length
+	}
+
+	length := 0
+	for _, r := range runes {
+		if r >= 0x10000 {
+			length += 2
+		} else {
+			length++
+		}
+	}
+	return length
+}
+
+// Full 编码文本缓冲区当前的分词结果，并记录结果用于之后计算增量编辑
+func (p *Provider) Full(buffer *textbuffer.TextBuffer) *SemanticTokens {
+	data := p.encodeBuffer(buffer)
+	return &SemanticTokens{ResultID: p.store(data), Data: data}
+}
+
+// Delta 计算文本缓冲区当前的分词结果相对于previousResultID的增量编辑
+// 之前的结果已经不存在时返回完整的结果，两个返回值中只有一个不为nil
+func (p *Provider) Delta(buffer *textbuffer.TextBuffer, previousResultID string) (*SemanticTokensDelta, *SemanticTokens) {
+	p.mutex.Lock()
+	previous, ok := p.results[previousResultID]
+	p.mutex.Unlock()
+	if !ok {
+		return nil, p.Full(buffer)
+	}
+
+	data := p.encodeBuffer(buffer)
+	return &SemanticTokensDelta{
+		ResultID: p.store(data),
+		Edits:    ComputeEdits(previous, data),
+	}, nil
+}
+
+// Detach 移除Provider注册在文本缓冲区上的监听器，并丢弃这个文本缓冲区的缓存
+func (p *Provider) Detach(buffer *textbuffer.TextBuffer) {
+	p.mutex.Lock()
+	doc := p.documents[buffer]
+	delete(p.documents, buffer)
+	p.mutex.Unlock()
+
+	if doc != nil {
+		doc.removeListeners()
+	}
+}
+
+// attach 获取文本缓冲区的缓存，第一次使用时注册监听器
+func (p *Provider) attach(buffer *textbuffer.TextBuffer) *document {
+	p.mutex.Lock()
+	doc := p.documents[buffer]
+	p.mutex.Unlock()
+	if doc != nil {
+		return doc
+	}
+
+	// 不持有锁时注册监听器，注册之后的变化都会通过事件标记
+	doc = &document{resync: true}
+	removeContent := buffer.OnContentChanged(func(event textbuffer.ContentChangeEvent) {
+		p.mutex.Lock()
+		defer p.mutex.Unlock()
+		doc.contentChanged(event)
+	})
+	removeTokens := buffer.OnTokensChanged(func(event textbuffer.TokensChangedEvent) {
+		p.mutex.Lock()
+		defer p.mutex.Unlock()
+		doc.tokensChanged(event)
+	})
+	doc.removeListeners = func() {
+		removeContent()
+		removeTokens()
+	}
+
+	p.mutex.Lock()
+	if existing := p.documents[buffer]; existing != nil {
+		// 其他goroutine已经注册
+		p.mutex.Unlock()
+		doc.removeListeners()
+		return existing
+	}
+	p.documents[buffer] = doc
+	p.mutex.Unlock()
+	return doc
+}
+
+// encodeBuffer 编码文本缓冲区当前的分词结果，只重新编码缓存中需要更新的行
+func (p *Provider) encodeBuffer(buffer *textbuffer.TextBuffer) []uint32 {
+	doc := p.attach(buffer)
+
+	// 完成所有行的分词，分词结果变化的行通过事件标记为需要重新编码
+	if lineCount := buffer.GetLineCount(); lineCount > 0 {
+		buffer.GetLineTokens(lineCount - 1)
+	}
+
+	for attempt := 0; attempt < maxSyncAttempts; attempt++ {
+		p.mutex.Lock()
+		resync, start, end := doc.resync, doc.dirtyStart, doc.dirtyEnd
+		if !resync && start >= end {
+			data := joinLines(doc.lines)
+			p.mutex.Unlock()
+			return data
+		}
+		p.mutex.Unlock()
+
+		if resync {
+			start, end = 0, math.MaxInt
+		}
+		lines, version := buffer.GetTokenizedLinesInRange(start, end)
+		encoded := make([][]uint32, len(lines))
+		for i, line := range lines {
+			encoded[i] = p.encodeLine(line)
+		}
+
+		p.mutex.Lock()
+		doc.install(resync, start, end, encoded, version)
+		p.mutex.Unlock()
+
+		// 其他goroutine的事件还没有送达时让出处理器
+		runtime.Gosched()
+	}
+
+	return p.Encode(buffer.GetTokenizedLines())
+}
+
+// install 安装重新编码的行，期间收到的事件使这些行不再对应缓存的版本时放弃
+// 调用方必须持有Provider的锁
+func (doc *document) install(resync bool, start, end int, encoded [][]uint32, version int) {
+	if resync {
+		if !doc.resync || doc.latest > version {
+			return
+		}
+		doc.lines = encoded
+		doc.version = version
+		doc.latest = version
+		doc.resync = false
+		doc.dirtyStart, doc.dirtyEnd = 0, 0
+		return
+	}
+
+	end = min(end, len(doc.lines))
+	if doc.resync || doc.version != version || doc.dirtyStart != start || min(doc.dirtyEnd, len(doc.lines)) != end || len(encoded) != end-start {
+		return
+	}
+	copy(doc.lines[start:end], encoded)
+	doc.dirtyStart, doc.dirtyEnd = 0, 0
+}
+
+// contentChanged 根据编辑移动缓存的行，并把编辑的行标记为需要重新编码
+// 调用方必须持有Provider的锁
+func (doc *document) contentChanged(event textbuffer.ContentChangeEvent) {
+	doc.latest = max(doc.latest, event.VersionID)
+	if doc.resync {
+		return
+	}
+	if event.VersionID-len(event.Edits) != doc.version {
+		// 事件与缓存的版本不连续
+		doc.resync = true
+		return
+	}
+
+	for _, change := range event.LineChanges {
+		// 文本以换行符结尾时最后的空行不算作一行，编辑的范围可能超出缓存的行数
+		start := min(change.StartLine, len(doc.lines))
+		end := min(start+change.RemovedLines+1, len(doc.lines))
+		replacement := make([][]uint32, change.AddedLines+1)
+		doc.lines = append(doc.lines[:start:start], append(replacement, doc.lines[end:]...)...)
+
+		// 移动之前标记的范围，再与编辑的行合并
+		delta := change.AddedLines - change.RemovedLines
+		if doc.dirtyStart < doc.dirtyEnd {
+			if doc.dirtyStart >= end {
+				doc.dirtyStart += delta
+			} else if doc.dirtyStart > start {
+				doc.dirtyStart = start
+			}
+			if doc.dirtyEnd >= end {
+				doc.dirtyEnd += delta
+			} else if doc.dirtyEnd > start {
+				doc.dirtyEnd = start + change.AddedLines + 1
+			}
+		}
+		doc.markDirty(start, start+change.AddedLines+1)
+	}
+
+	if len(doc.lines) > event.LineCount {
+		doc.lines = doc.lines[:event.LineCount]
+	}
+	for len(doc.lines) < event.LineCount {
+		doc.markDirty(len(doc.lines), len(doc.lines)+1)
+		doc.lines = append(doc.lines, nil)
+	}
+	doc.dirtyEnd = min(doc.dirtyEnd, len(doc.lines))
+	doc.version = event.VersionID
+}
+
+// tokensChanged 把分词结果变化的行标记为需要重新编码
+// 调用方必须持有Provider的锁
+func (doc *document) tokensChanged(event textbuffer.TokensChangedEvent) {
+	doc.latest = max(doc.latest, event.VersionID)
+	if doc.resync {
+		return
+	}
+	if event.VersionID != doc.version {
+		doc.resync = true
+		return
+	}
+	doc.markDirty(event.StartLine, min(event.EndLine, len(doc.lines)))
+}
+
+// markDirty 把[start, end)范围内的行标记为需要重新编码
+func (doc *document) markDirty(start, end int) {
+	if start >= end {
+		return
+	}
+	if doc.dirtyStart >= doc.dirtyEnd {
+		doc.dirtyStart, doc.dirtyEnd = start, end
+		return
+	}
+	doc.dirtyStart = min(doc.dirtyStart, start)
+	doc.dirtyEnd = max(doc.dirtyEnd, end)
+}
+
+// store 记录一个结果并返回它的标识，只保留最近的结果
+func (p *Provider) store(data []uint32) string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.nextID++
+	id := strconv.FormatUint(p.nextID, 10)
+	p.results[id] = data
+	p.order = append(p.order, id)
+	if len(p.order) > maxResults {
+		delete(p.results, p.order[0])
+		p.order = p.order[1:]
+	}
+	return id
+}
+
+// Release 释放一个结果，客户端不再需要基于它的增量编辑时调用
+func (p *Provider) Release(resultID string) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	delete(p.results, resultID)
+	for i, id := range p.order {
+		if id == resultID {
+			p.order = append(p.order[:i:i], p.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// ComputeEdits 计算把oldData变为newData的编辑，去掉公共的前缀和后缀之后最多产生一个编辑
+func ComputeEdits(oldData, newData []uint32) []SemanticTokensEdit {
+	prefix := 0
+	for prefix < len(oldData) && prefix < len(newData) && oldData[prefix] == newData[prefix] {
+		prefix++
+	}
+	if prefix == len(oldData) && prefix == len(newData) {
+		return []SemanticTokensEdit{}
+	}
+
+	suffix := 0
+	for suffix < len(oldData)-prefix && suffix < len(newData)-prefix &&
+		oldData[len(oldData)-1-suffix] == newData[len(newData)-1-suffix] {
+		suffix++
+	}
+
+	edit := SemanticTokensEdit{
+		Start:       uint32(prefix),
+		DeleteCount: uint32(len(oldData) - prefix - suffix),
+	}
+	if inserted := newData[prefix : len(newData)-suffix]; len(inserted) > 0 {
+		edit.Data = append([]uint32(nil), inserted...)
+	}
+	return []SemanticTokensEdit{edit}
+}
+
+// ApplyEdits 将增量编辑应用到之前的数据上，得到新的数据
+func ApplyEdits(data []uint32, edits []SemanticTokensEdit) []uint32 {
+	result := append([]uint32(nil), data...)
+	// 编辑的位置都基于之前的数据，从后往前应用
+	for i := len(edits) - 1; i >= 0; i-- {
+		edit := edits[i]
+		start := min(int(edit.Start), len(result))
+		end := min(start+int(edit.DeleteCount), len(result))
+		result = append(result[:start], append(append([]uint32(nil), edit.Data...), result[end:]...)...)
+	}
+	return result
+}
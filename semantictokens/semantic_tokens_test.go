@@ -0,0 +1,191 @@
+package semantictokens
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/example/gotextbuffer/monarch"
+	"github.com/example/gotextbuffer/textbuffer"
+)
+
+func equalData(a, b []uint32) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestClassify(t *testing.T) {
+	provider := NewProvider(Legend{
+		TokenTypes:     []string{"keyword", "comment", "string.escape"},
+		TokenModifiers: []string{"documentation", "readonly"},
+	}, EncodingUTF16)
+
+	tests := []struct {
+		tokenType string
+		typeIndex int
+		modifiers uint32
+		ok        bool
+	}{
+		{"keyword.go", 0, 0, true},
+		{"comment.documentation", 1, 1, true},
+		{"string.escape.readonly", 2, 2, true},
+		{"string", 0, 0, false},
+		{"white", 0, 0, false},
+	}
+	for _, test := range tests {
+		typeIndex, modifiers, ok := provider.Classify(test.tokenType)
+		if typeIndex != test.typeIndex || modifiers != test.modifiers || ok != test.ok {
+			t.Errorf("Classify(%q) = %d, %d, %v", test.tokenType, typeIndex, modifiers, ok)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	provider := NewProvider(DefaultLegend(), EncodingUTF16)
+	lines := []textbuffer.TokenizedLine{
+		{Text: "func f", Tokens: []textbuffer.Token{{StartColumn: 0, Type: "keyword"}, {StartColumn: 4, Type: "white"}, {StartColumn: 5, Type: "function"}}},
+		{Text: ""},
+		{Text: "\"😀\" // x", Tokens: []textbuffer.Token{{StartColumn: 0, Type: "string"}, {StartColumn: 3, Type: "white"}, {StartColumn: 4, Type: "comment"}}},
+	}
+
+	expected := []uint32{
+		0, 0, 4, 15, 0,
+		0, 5, 1, 12, 0,
+		2, 0, 4, 18, 0, // 😀在UTF-16中占两个代码单元
+		0, 5, 4, 17, 0,
+	}
+	if data := provider.Encode(lines); !equalData(data, expected) {
+		t.Errorf("Expected %v, got %v", expected, data)
+	}
+
+	provider = NewProvider(DefaultLegend(), EncodingUTF32)
+	if data := provider.Encode(lines[2:]); !equalData(data, []uint32{0, 0, 3, 18, 0, 0, 4, 4, 17, 0}) {
+		t.Errorf("Unexpected UTF-32 encoding %v", data)
+	}
+}
+
+func TestFullAndDelta(t *testing.T) {
+	lexer, err := monarch.Builtin("go")
+	if err != nil {
+		t.Fatalf("Builtin failed: %v", err)
+	}
+	buffer := textbuffer.NewTextBufferWithText("package main\n\nfunc main() {\n\treturn\n}\n")
+	buffer.SetTokenizer(lexer)
+	provider := NewProvider(DefaultLegend(), EncodingUTF16)
+
+	full := provider.Full(buffer)
+	if len(full.Data) != 15 || full.ResultID == "" {
+		t.Fatalf("Unexpected full result %+v", full)
+	}
+
+	// 没有变化时没有编辑
+	delta, fallback := provider.Delta(buffer, full.ResultID)
+	if fallback != nil || len(delta.Edits) != 0 {
+		t.Errorf("Expected empty delta, got %+v %+v", delta, fallback)
+	}
+
+	// 修改一行只产生一个编辑
+	_ = buffer.Insert(textbuffer.Position{Line: 3, Column: 7}, " // done")
+	delta, _ = provider.Delta(buffer, full.ResultID)
+	if len(delta.Edits) != 1 || delta.Edits[0].Start != 15 || delta.Edits[0].DeleteCount != 0 || len(delta.Edits[0].Data) != 5 {
+		t.Errorf("Unexpected delta %+v", delta)
+	}
+	current := provider.Full(buffer)
+	if !equalData(ApplyEdits(full.Data, delta.Edits), current.Data) {
+		t.Errorf("Expected applying the delta to produce the current data")
+	}
+
+	// 未知的结果返回完整的结果
+	provider.Release(full.ResultID)
+	if delta, fallback := provider.Delta(buffer, full.ResultID); delta != nil || fallback == nil {
+		t.Errorf("Expected full result for released result ID")
+	}
+}
+
+func TestDeltaReencodesChangedLines(t *testing.T) {
+	lexer, err := monarch.Builtin("go")
+	if err != nil {
+		t.Fatalf("Builtin failed: %v", err)
+	}
+	buffer := textbuffer.NewTextBufferWithText("package main\n\nfunc main() {\n\treturn\n}\n// a\nvar x = 1\n")
+	buffer.SetTokenizer(lexer)
+	provider := NewProvider(DefaultLegend(), EncodingUTF16)
+	previous := provider.Full(buffer)
+
+	// 只有编辑的行需要重新编码
+	_ = buffer.Insert(textbuffer.Position{Line: 3, Column: 7}, " 1")
+	buffer.GetLineTokens(buffer.GetLineCount() - 1)
+	doc := provider.documents[buffer]
+	if doc.dirtyStart != 3 || doc.dirtyEnd != 4 {
+		t.Errorf("Expected only line 3 to be dirty, got [%d, %d)", doc.dirtyStart, doc.dirtyEnd)
+	}
+
+	random := rand.New(rand.NewSource(7))
+	fragments := []string{"/*", "*/", "\n", "\"s", "x", "// c\n", "func", " "}
+	for i := 0; i < 300; i++ {
+		text := buffer.GetText()
+		offset := random.Intn(len([]rune(text)) + 1)
+		position := buffer.GetPositionAt(offset)
+		if random.Intn(3) == 0 && offset < len([]rune(text)) {
+			end := buffer.GetPositionAt(min(offset+1+random.Intn(4), len([]rune(text))))
+			_ = buffer.Delete(textbuffer.NewRange(position, end))
+		} else {
+			_ = buffer.Insert(position, fragments[random.Intn(len(fragments))])
+		}
+		if random.Intn(10) == 0 {
+			buffer.Undo()
+		}
+		if doc.resync {
+			t.Fatalf("Step %d: expected the cache to be updated incrementally", i)
+		}
+
+		delta, full := provider.Delta(buffer, previous.ResultID)
+		expected := provider.Encode(buffer.GetTokenizedLines())
+		if full != nil {
+			t.Fatalf("Unexpected full result at step %d", i)
+		}
+		if data := ApplyEdits(previous.Data, delta.Edits); !equalData(data, expected) {
+			t.Fatalf("Step %d: incremental data differs from a full encoding for %q", i, buffer.GetText())
+		}
+		previous = &SemanticTokens{ResultID: delta.ResultID, Data: ApplyEdits(previous.Data, delta.Edits)}
+	}
+
+	// 分离之后不再跟踪文本缓冲区
+	provider.Detach(buffer)
+	_ = buffer.Insert(textbuffer.Position{Line: 0, Column: 0}, "x")
+	if _, ok := provider.documents[buffer]; ok {
+		t.Errorf("Expected the document to be removed after Detach")
+	}
+	if !equalData(provider.Full(buffer).Data, provider.Encode(buffer.GetTokenizedLines())) {
+		t.Errorf("Expected a full encoding after attaching again")
+	}
+}
+
+func TestComputeEdits(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	randomData := func() []uint32 {
+		data := make([]uint32, random.Intn(20))
+		for i := range data {
+			data[i] = uint32(random.Intn(3))
+		}
+		return data
+	}
+
+	for iteration := 0; iteration < 500; iteration++ {
+		oldData := randomData()
+		newData := randomData()
+		edits := ComputeEdits(oldData, newData)
+		if len(edits) > 1 {
+			t.Fatalf("Expected at most one edit, got %d", len(edits))
+		}
+		if result := ApplyEdits(oldData, edits); !equalData(result, newData) {
+			t.Fatalf("Expected %v, got %v", newData, result)
+		}
+	}
+}
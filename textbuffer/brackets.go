@@ -0,0 +1,316 @@
+package textbuffer
+
+import (
+	"sort"
+)
+
+// BracketPair 表示一对匹配的括号
+type BracketPair struct {
+	// Open 开括号的范围
+	Open Range
+	// Close 闭括号的范围
+	Close Range
+	// Depth 括号的嵌套深度，最外层为0
+	Depth int
+}
+
+// bracketToken 表示一行中的一个括号
+type bracketToken struct {
+	// 括号在行中的列号
+	column int
+	// 括号的长度
+	length int
+	// 括号对在语言配置中的索引
+	pairIndex int
+	// 是否是开括号
+	open bool
+}
+
+// bracketIndex 缓存每一行中的括号，编辑时只重新扫描受影响的行
+// 同时缓存每一行开始时尚未闭合的开括号，查询时从查询范围的第一行开始扫描，而不是从文档开头
+type bracketIndex struct {
+	lines lineDataCache[[]bracketToken]
+	// 每一行开始时尚未闭合的开括号，只有前validStates行的状态是有效的
+	states      lineDataCache[[]bracketLocation]
+	validStates int
+}
+
+// linesChanged 使受编辑影响的行失效，之后各行的开始状态都依赖于这些行，因此一起失效
+func (bi *bracketIndex) linesChanged(startLine, removedLines, addedLines int) {
+	bi.lines.linesChanged(startLine, removedLines, addedLines)
+	bi.states.linesChanged(startLine, removedLines, addedLines)
+	bi.validStates = min(bi.validStates, startLine)
+}
+
+// invalidate 使一行的括号失效，之后各行的开始状态也随之失效
+func (bi *bracketIndex) invalidate(lineIndex int) {
+	bi.lines.invalidate(lineIndex)
+	bi.validStates = min(bi.validStates, lineIndex+1)
+}
+
+// invalidateAll 使所有行失效
+func (bi *bracketIndex) invalidateAll() {
+	bi.lines.invalidateAll()
+	bi.states.invalidateAll()
+	bi.validStates = 0
+}
+
+// bracketLocation 表示括号在文档中的位置
+type bracketLocation struct {
+	line  int
+	token bracketToken
+}
+
+// rangeOf 获取括号的范围
+func (bl bracketLocation) rangeOf() Range {
+	return NewRange(
+		Position{Line: bl.line, Column: bl.token.column},
+		Position{Line: bl.line, Column: bl.token.column + bl.token.length},
+	)
+}
+
+// scanBrackets 扫描一行文本中的括号，较长的括号优先匹配
+func scanBrackets(line []rune, pairs []CharacterPair) []bracketToken {
+	var tokens []bracketToken
+
+	for column := 0; column < len(line); {
+		best := bracketToken{length: 0}
+		for pairIndex, pair := range pairs {
+			if pair.Open == pair.Close {
+				continue
+			}
+			if length := matchAt(line, column, pair.Open); length > best.length {
+				best = bracketToken{column: column, length: length, pairIndex: pairIndex, open: true}
+			}
+			if length := matchAt(line, column, pair.Close); length > best.length {
+				best = bracketToken{column: column, length: length, pairIndex: pairIndex, open: false}
+			}
+		}
+
+		if best.length == 0 {
+			column++
+			continue
+		}
+		tokens = append(tokens, best)
+		column += best.length
+	}
+
+	return tokens
+}
+
+// matchAt 判断文本在指定列是否以给定的字符串开头，返回匹配的长度
+func matchAt(line []rune, column int, text string) int {
+	runes := []rune(text)
+	if len(runes) == 0 || column+len(runes) > len(line) {
+		return 0
+	}
+	for i, ch := range runes {
+		if line[column+i] != ch {
+			return 0
+		}
+	}
+	return len(runes)
+}
+
+// getBracketIndex 获取括号索引，第一次使用时创建
+// 调用方必须持有写锁
+func (tb *TextBuffer) getBracketIndex() *bracketIndex {
+	if tb.brackets == nil {
+		tb.brackets = &bracketIndex{}
+		tb.addLineObserver(tb.brackets)
+	}
+	return tb.brackets
+}
+
+// lineBrackets 获取一行中的括号，优先使用缓存
+// 调用方必须持有写锁
+func (tb *TextBuffer) lineBrackets(lineIndex int) []bracketToken {
+	tb.getBracketIndex()
+
+	// 先更新分词结果，分词结果变化的行会使括号的缓存失效
+	lineTokens := tb.lineTokens(lineIndex)
+
+	tb.brackets.lines.resize(tb.gapBuffer.GetLineCount())
+	if tokens, ok := tb.brackets.lines.get(lineIndex); ok {
+		return tokens
+	}
+
+	tokens := scanBrackets([]rune(tb.lineText(lineIndex)), tb.getLanguageConfiguration().Brackets)
+	if lineTokens != nil {
+		tokens = skipNonCodeBrackets(tokens, lineTokens)
+	}
+	tb.brackets.lines.set(lineIndex, tokens)
+	return tokens
+}
+
+// skipNonCodeBrackets 去掉位于注释、字符串和正则表达式中的括号
+func skipNonCodeBrackets(tokens []bracketToken, lineTokens []Token) []bracketToken {
+	result := tokens[:0]
+	for _, token := range tokens {
+		if lineToken, ok := tokenAt(lineTokens, token.column); ok && StandardTokenTypeOf(lineToken.Type) != StandardTokenOther {
+			continue
+		}
+		result = append(result, token)
+	}
+	return result
+}
+
+// MatchBracket 查找与指定位置的括号相匹配的括号，返回匹配括号的起始位置
+// 位置紧挨在括号之后或位于括号之前都可以，同时存在时优先使用位置之前的括号
+func (tb *TextBuffer) MatchBracket(position Position) (Position, bool) {
+	tb.mutex.Lock()
+	defer tb.unlock()
+
+	if position.Line < 0 || position.Line >= tb.gapBuffer.GetLineCount() {
+		return Position{}, false
+	}
+
+	tokens := tb.lineBrackets(position.Line)
+	index := -1
+	for i, token := range tokens {
+		if token.column+token.length == position.Column {
+			index = i
+			break
+		}
+		if token.column <= position.Column && position.Column < token.column+token.length && index < 0 {
+			index = i
+		}
+	}
+	if index < 0 {
+		return Position{}, false
+	}
+
+	token := tokens[index]
+	depth := 1
+	if token.open {
+		// 向后查找匹配的闭括号
+		for line := position.Line; line < tb.gapBuffer.GetLineCount(); line++ {
+			lineTokens := tb.lineBrackets(line)
+			start := 0
+			if line == position.Line {
+				start = index + 1
+			}
+			for _, candidate := range lineTokens[start:] {
+				if candidate.pairIndex != token.pairIndex {
+					continue
+				}
+				if candidate.open {
+					depth++
+				} else if depth--; depth == 0 {
+					return Position{Line: line, Column: candidate.column}, true
+				}
+			}
+		}
+	} else {
+		// 向前查找匹配的开括号
+		for line := position.Line; line >= 0; line-- {
+			lineTokens := tb.lineBrackets(line)
+			end := len(lineTokens)
+			if line == position.Line {
+				end = index
+			}
+			for i := end - 1; i >= 0; i-- {
+				candidate := lineTokens[i]
+				if candidate.pairIndex != token.pairIndex {
+					continue
+				}
+				if !candidate.open {
+					depth++
+				} else if depth--; depth == 0 {
+					return Position{Line: line, Column: candidate.column}, true
+				}
+			}
+		}
+	}
+
+	return Position{}, false
+}
+
+// bracketStateAt 获取指定行开始时尚未闭合的开括号，从最近的有效状态开始扫描并缓存经过的各行的状态
+// 调用方必须持有写锁
+func (tb *TextBuffer) bracketStateAt(lineIndex int) []bracketLocation {
+	bi := tb.getBracketIndex()
+	bi.states.resize(tb.gapBuffer.GetLineCount())
+	if bi.validStates == 0 {
+		bi.states.set(0, nil)
+		bi.validStates = 1
+	}
+
+	for bi.validStates <= lineIndex {
+		line := bi.validStates - 1
+		state, _ := bi.states.get(line)
+		stack := append([]bracketLocation(nil), state...)
+		for _, token := range tb.lineBrackets(line) {
+			stack, _ = pushBracket(stack, bracketLocation{line: line, token: token})
+		}
+		// 扫描这一行时分词结果的变化可能使之后的状态失效
+		if bi.validStates != line+1 {
+			continue
+		}
+		bi.states.set(line+1, stack)
+		bi.validStates = line + 2
+	}
+
+	state, _ := bi.states.get(lineIndex)
+	return append([]bracketLocation(nil), state...)
+}
+
+// pushBracket 处理一个括号：开括号入栈；闭括号与最近的同类开括号配对，中间未闭合的开括号被丢弃
+// 返回新的栈和配对的开括号在栈中的深度，没有配对时深度为-1
+func pushBracket(stack []bracketLocation, location bracketLocation) ([]bracketLocation, int) {
+	if location.token.open {
+		return append(stack, location), -1
+	}
+	for depth := len(stack) - 1; depth >= 0; depth-- {
+		if stack[depth].token.pairIndex == location.token.pairIndex {
+			return stack[:depth], depth
+		}
+	}
+	return stack, -1
+}
+
+// maxBracketPairSearchLines 是在范围之后查找闭括号的最大行数
+const maxBracketPairSearchLines = 1000
+
+// GetBracketPairsInRange 获取与指定范围相交的所有括号对及其嵌套深度，按开括号的位置排序
+// 不匹配的括号会被忽略；在范围之后最多查找maxBracketPairSearchLines行，闭括号更远的括号对也被忽略
+func (tb *TextBuffer) GetBracketPairsInRange(r Range) []BracketPair {
+	tb.mutex.Lock()
+	defer tb.unlock()
+
+	var pairs []BracketPair
+	lineCount := tb.gapBuffer.GetLineCount()
+	startLine := max(0, min(r.Start.Line, lineCount-1))
+	endLine := min(lineCount, r.End.Line+1+maxBracketPairSearchLines)
+	stack := tb.bracketStateAt(startLine)
+
+	for line := startLine; line < endLine; line++ {
+		// 范围之后只需要继续查找仍未闭合的括号
+		if line > r.End.Line && len(stack) == 0 {
+			break
+		}
+
+		for _, token := range tb.lineBrackets(line) {
+			location := bracketLocation{line: line, token: token}
+			var depth int
+			open := stack
+			if stack, depth = pushBracket(stack, location); depth < 0 {
+				continue
+			}
+			pair := BracketPair{
+				Open:  open[depth].rangeOf(),
+				Close: location.rangeOf(),
+				Depth: depth,
+			}
+			if pair.Open.Start.IsBeforeOrEqual(r.End) && pair.Close.End.IsAfterOrEqual(r.Start) {
+				pairs = append(pairs, pair)
+			}
+		}
+	}
+
+	sort.Slice(pairs, func(i, j int) bool {
+		return pairs[i].Open.Start.IsBefore(pairs[j].Open.Start)
+	})
+
+	return pairs
+}
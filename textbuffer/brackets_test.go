@@ -0,0 +1,133 @@
+package textbuffer
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestMatchBracket(t *testing.T) {
+	buffer := NewTextBufferWithText("func f(a []int) {\n\tif (a[0]) {\n\t}\n}")
+
+	tests := []struct {
+		position Position
+		expected Position
+	}{
+		{Position{Line: 0, Column: 16}, Position{Line: 3, Column: 0}},
+		{Position{Line: 0, Column: 17}, Position{Line: 3, Column: 0}},
+		{Position{Line: 3, Column: 1}, Position{Line: 0, Column: 16}},
+		{Position{Line: 0, Column: 6}, Position{Line: 0, Column: 14}},
+		{Position{Line: 1, Column: 6}, Position{Line: 1, Column: 8}},
+		{Position{Line: 2, Column: 1}, Position{Line: 1, Column: 11}},
+	}
+	for _, test := range tests {
+		match, ok := buffer.MatchBracket(test.position)
+		if !ok || !match.Equals(test.expected) {
+			t.Errorf("MatchBracket(%v): expected %v, got %v (%v)", test.position, test.expected, match, ok)
+		}
+	}
+
+	if _, ok := buffer.MatchBracket(Position{Line: 0, Column: 2}); ok {
+		t.Errorf("Expected no bracket at (0, 2)")
+	}
+
+	// 编辑之后只有受影响的行被重新扫描
+	_ = buffer.Insert(Position{Line: 2, Column: 0}, "\t\tg(\n\t\t)\n")
+	match, ok := buffer.MatchBracket(Position{Line: 2, Column: 4})
+	if !ok || !match.Equals(Position{Line: 3, Column: 2}) {
+		t.Errorf("Expected match at (3, 2), got %v (%v)", match, ok)
+	}
+	match, ok = buffer.MatchBracket(Position{Line: 4, Column: 2})
+	if !ok || !match.Equals(Position{Line: 1, Column: 11}) {
+		t.Errorf("Expected match at (1, 11), got %v (%v)", match, ok)
+	}
+}
+
+func TestGetBracketPairsInRange(t *testing.T) {
+	buffer := NewTextBufferWithText("a { b ( c ] d ) }\n[ x ]")
+
+	pairs := buffer.GetBracketPairsInRange(NewRange(Position{Line: 0, Column: 0}, Position{Line: 1, Column: 5}))
+	if len(pairs) != 3 {
+		t.Fatalf("Expected 3 pairs, got %v", pairs)
+	}
+	expected := []struct {
+		open  Position
+		close Position
+		depth int
+	}{
+		{Position{Line: 0, Column: 2}, Position{Line: 0, Column: 16}, 0},
+		{Position{Line: 0, Column: 6}, Position{Line: 0, Column: 14}, 1},
+		{Position{Line: 1, Column: 0}, Position{Line: 1, Column: 4}, 0},
+	}
+	for i, e := range expected {
+		if !pairs[i].Open.Start.Equals(e.open) || !pairs[i].Close.Start.Equals(e.close) || pairs[i].Depth != e.depth {
+			t.Errorf("Pair %d: expected %v-%v depth %d, got %+v", i, e.open, e.close, e.depth, pairs[i])
+		}
+	}
+
+	// 只返回与范围相交的括号对，包括包含该范围的括号对
+	pairs = buffer.GetBracketPairsInRange(NewRange(Position{Line: 0, Column: 8}, Position{Line: 0, Column: 9}))
+	if len(pairs) != 2 {
+		t.Errorf("Expected 2 enclosing pairs, got %v", pairs)
+	}
+
+	// 测试自定义的括号
+	buffer.SetLanguageConfiguration(&LanguageConfiguration{
+		Brackets: []CharacterPair{{Open: "begin", Close: "end"}},
+	})
+	buffer.SetText("begin\n  begin x end\nend")
+	pairs = buffer.GetBracketPairsInRange(NewRange(Position{Line: 0, Column: 0}, Position{Line: 2, Column: 3}))
+	if len(pairs) != 2 || pairs[1].Depth != 1 || pairs[0].Close != NewRange(Position{Line: 2, Column: 0}, Position{Line: 2, Column: 3}) {
+		t.Errorf("Unexpected pairs %+v", pairs)
+	}
+}
+
+func TestGetBracketPairsInRangeUsesCachedState(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	pieces := []string{"(", ")", "[", "]", "{", "}", "x", "\n", "\n"}
+	var builder strings.Builder
+	for i := 0; i < 400; i++ {
+		builder.WriteString(pieces[random.Intn(len(pieces))])
+	}
+	buffer := NewTextBufferWithText(builder.String())
+
+	// 全文的括号对，过滤后与只查询一部分的结果相同
+	check := func() {
+		lineCount := buffer.GetLineCount()
+		all := buffer.GetBracketPairsInRange(NewRange(Position{}, Position{Line: lineCount, Column: 0}))
+		for i := 0; i < 20; i++ {
+			start := random.Intn(lineCount)
+			r := NewRange(Position{Line: start, Column: 0}, Position{Line: start + random.Intn(5), Column: 0})
+			var expected []BracketPair
+			for _, pair := range all {
+				if pair.Open.Start.IsBeforeOrEqual(r.End) && pair.Close.End.IsAfterOrEqual(r.Start) {
+					expected = append(expected, pair)
+				}
+			}
+			actual := buffer.GetBracketPairsInRange(r)
+			if !reflect.DeepEqual(actual, expected) {
+				t.Fatalf("Range %+v: expected %+v, got %+v", r, expected, actual)
+			}
+		}
+	}
+
+	check()
+	for i := 0; i < 20; i++ {
+		offset := random.Intn(buffer.GetLength() + 1)
+		buffer.Insert(buffer.GetPositionAt(offset), pieces[random.Intn(len(pieces))])
+		check()
+	}
+}
+
+func TestGetBracketPairsInRangeStopsAfterRange(t *testing.T) {
+	// 文件开头未闭合的括号不会使查询扫描到文件末尾
+	buffer := NewTextBufferWithText("{\n" + strings.Repeat("(x)\n", 3000))
+	pairs := buffer.GetBracketPairsInRange(NewRange(Position{Line: 10, Column: 0}, Position{Line: 11, Column: 3}))
+	if len(pairs) != 2 || pairs[0].Open.Start.Line != 10 || pairs[1].Open.Start.Line != 11 || pairs[0].Depth != 1 {
+		t.Errorf("Unexpected pairs %+v", pairs)
+	}
+	if _, ok := buffer.brackets.lines.get(11 + maxBracketPairSearchLines + 1); ok {
+		t.Errorf("Expected the lines after the search limit not to be scanned")
+	}
+}
@@ -0,0 +1,82 @@
+package textbuffer
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrCheckpointNotFound 表示找不到指定名称的检查点
+var ErrCheckpointNotFound = errors.New("checkpoint not found")
+
+// checkpoint 记录创建检查点时的文本内容和选区
+// 检查点保存的是内容快照而不是撤销栈中的位置，因此撤销栈移除旧操作后检查点仍然有效
+type checkpoint struct {
+	// 创建检查点时的文本
+	text string
+	// 创建检查点时的选区
+	selections []Selection
+}
+
+// CreateCheckpoint 在当前编辑历史位置创建一个命名检查点，记录当前的文本和选区
+// 如果同名的检查点已经存在，它会被覆盖
+func (tb *TextBuffer) CreateCheckpoint(name string) {
+	tb.mutex.Lock()
+	defer tb.unlock()
+
+	if tb.checkpoints == nil {
+		tb.checkpoints = make(map[string]*checkpoint)
+	}
+	tb.checkpoints[name] = &checkpoint{
+		text:       tb.gapBuffer.GetText(),
+		selections: tb.getSelections(),
+	}
+}
+
+// RestoreCheckpoint 将文本和选区恢复到指定检查点时的状态
+// 恢复作为一个可撤销的操作应用，只修改与检查点不同的部分
+func (tb *TextBuffer) RestoreCheckpoint(name string) error {
+	tb.mutex.Lock()
+	defer tb.unlock()
+
+	cp, ok := tb.checkpoints[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrCheckpointNotFound, name)
+	}
+
+	edit := minimalEdit(tb.gapBuffer.GetText(), cp.text)
+	if edit.OldText == "" && edit.NewText == "" {
+		// 文本没有变化，只恢复选区
+		tb.setSelections(cp.selections)
+		return nil
+	}
+
+	tb.pushOperation(&TextOperation{
+		Type:            operationTypeOf([]Edit{edit}),
+		Label:           "Restore Checkpoint " + name,
+		Edits:           []Edit{edit},
+		AfterSelections: cloneSelections(cp.selections),
+	})
+
+	return nil
+}
+
+// DeleteCheckpoint 删除指定名称的检查点
+func (tb *TextBuffer) DeleteCheckpoint(name string) {
+	tb.mutex.Lock()
+	defer tb.unlock()
+	delete(tb.checkpoints, name)
+}
+
+// Checkpoints 获取所有检查点的名称，按名称排序
+func (tb *TextBuffer) Checkpoints() []string {
+	tb.mutex.RLock()
+	defer tb.mutex.RUnlock()
+
+	names := make([]string, 0, len(tb.checkpoints))
+	for name := range tb.checkpoints {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,61 @@
+package textbuffer
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTextBufferCheckpoint(t *testing.T) {
+	buffer := NewTextBufferWithText("func main() {\n}")
+	cursor := NewCursor(Position{Line: 0, Column: 13})
+	buffer.SetSelections([]Selection{cursor})
+	buffer.CreateCheckpoint("before-refactor")
+
+	// 执行多步编辑，数量超过撤销栈的最大大小
+	for i := 0; i < 150; i++ {
+		_ = buffer.Insert(Position{Line: 1, Column: 0}, "\tx++\n")
+	}
+	_ = buffer.Replace(NewRange(Position{Line: 0, Column: 5}, Position{Line: 0, Column: 9}), "run")
+
+	// 跟踪的选区随编辑调整
+	selections := buffer.GetSelections()
+	if len(selections) != 1 || !selections[0].Active.Equals(Position{Line: 0, Column: 12}) {
+		t.Errorf("Expected cursor at (0, 12), got %v", selections)
+	}
+
+	if err := buffer.RestoreCheckpoint("before-refactor"); err != nil {
+		t.Fatalf("RestoreCheckpoint failed: %v", err)
+	}
+	if buffer.GetText() != "func main() {\n}" {
+		t.Errorf("Expected checkpoint text, got '%s'", buffer.GetText())
+	}
+	selections = buffer.GetSelections()
+	if len(selections) != 1 || !selections[0].Equals(cursor) {
+		t.Errorf("Expected selections %v, got %v", cursor, selections)
+	}
+
+	// 恢复检查点是一个可撤销的操作
+	if buffer.UndoLabel() != "Restore Checkpoint before-refactor" {
+		t.Errorf("Unexpected undo label '%s'", buffer.UndoLabel())
+	}
+	result, err := buffer.Undo()
+	if err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+	if buffer.GetLineCount() != 152 {
+		t.Errorf("Expected 152 lines after undo, got %d", buffer.GetLineCount())
+	}
+	if len(result.Selections) != 1 || !result.Selections[0].Active.Equals(Position{Line: 0, Column: 12}) {
+		t.Errorf("Expected cursor at (0, 12) after undo, got %v", result.Selections)
+	}
+
+	// 测试不存在的检查点
+	if err := buffer.RestoreCheckpoint("missing"); !errors.Is(err, ErrCheckpointNotFound) {
+		t.Errorf("Expected ErrCheckpointNotFound, got %v", err)
+	}
+
+	buffer.DeleteCheckpoint("before-refactor")
+	if len(buffer.Checkpoints()) != 0 {
+		t.Errorf("Expected no checkpoints, got %v", buffer.Checkpoints())
+	}
+}
@@ -0,0 +1,175 @@
+package textbuffer
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+// ErrNoCommentRule 表示语言配置中没有所需的注释符号
+var ErrNoCommentRule = errors.New("no comment rule")
+
+// commentRule 获取切换注释使用的注释符号，config为nil时使用文本缓冲区的语言配置
+// 调用方必须持有锁
+func (tb *TextBuffer) commentRule(config *LanguageConfiguration) *CommentRule {
+	if config == nil {
+		config = tb.getLanguageConfiguration()
+	}
+	return config.Comments
+}
+
+// ToggleLineComment 切换一个范围内每一行的行注释，作为一个操作应用
+// 注释符号插入在范围内最小缩进的位置，空白行保持不变；
+// 所有非空白行都已经被注释时删除注释。语言没有行注释时改为切换块注释
+// config为nil时使用文本缓冲区的语言配置
+func (tb *TextBuffer) ToggleLineComment(r Range, config *LanguageConfiguration) error {
+	tb.mutex.Lock()
+	defer tb.unlock()
+
+	rule := tb.commentRule(config)
+	if rule == nil || rule.LineComment == "" {
+		if rule != nil && rule.BlockComment != nil {
+			return tb.toggleBlockComment(r, *rule.BlockComment)
+		}
+		return ErrNoCommentRule
+	}
+	token := rule.LineComment
+
+	// 范围结束于一行的开头时不包括这一行
+	endLine := r.End.Line
+	if endLine > r.Start.Line && r.End.Column == 0 {
+		endLine--
+	}
+	startLine, endLine, err := tb.clampLineRange(r.Start.Line, endLine)
+	if err != nil {
+		return err
+	}
+
+	// 找到非空白行的最小缩进，并判断是否所有非空白行都已经被注释
+	minIndent := -1
+	allCommented := true
+	for lineIndex := startLine; lineIndex <= endLine; lineIndex++ {
+		text := tb.lineText(lineIndex)
+		indent := leadingWhitespace(text)
+		if indent == text {
+			continue
+		}
+		if length := utf8.RuneCountInString(indent); minIndent < 0 || length < minIndent {
+			minIndent = length
+		}
+		if !strings.HasPrefix(text[len(indent):], token) {
+			allCommented = false
+		}
+	}
+	if minIndent < 0 {
+		return nil
+	}
+
+	// 从后往前生成编辑，这样每个编辑的偏移量都不受之前编辑的影响
+	var edits []Edit
+	for lineIndex := endLine; lineIndex >= startLine; lineIndex-- {
+		text := tb.lineText(lineIndex)
+		indent := leadingWhitespace(text)
+		if indent == text {
+			continue
+		}
+		start, _ := tb.gapBuffer.lineStartAndLength(lineIndex)
+
+		if allCommented {
+			// 删除注释符号和它后面的一个空格
+			oldText := token
+			if strings.HasPrefix(text[len(indent)+len(token):], " ") {
+				oldText += " "
+			}
+			edits = append(edits, Edit{Offset: start + utf8.RuneCountInString(indent), OldText: oldText})
+		} else {
+			edits = append(edits, Edit{Offset: start + minIndent, NewText: token + " "})
+		}
+	}
+
+	tb.pushOperation(&TextOperation{
+		Type:  operationTypeOf(edits),
+		Label: "Toggle Line Comment",
+		Edits: edits,
+	})
+
+	return nil
+}
+
+// ToggleBlockComment 切换一个范围的块注释，作为一个操作应用
+// 范围内去掉首尾空白的文本已经被块注释包围，或者范围紧邻块注释符号时删除注释，
+// 否则在范围的两端插入块注释符号。config为nil时使用文本缓冲区的语言配置
+func (tb *TextBuffer) ToggleBlockComment(r Range, config *LanguageConfiguration) error {
+	tb.mutex.Lock()
+	defer tb.unlock()
+
+	rule := tb.commentRule(config)
+	if rule == nil || rule.BlockComment == nil {
+		return ErrNoCommentRule
+	}
+	return tb.toggleBlockComment(r, *rule.BlockComment)
+}
+
+// toggleBlockComment 切换一个范围的块注释
+// 调用方必须持有写锁
+func (tb *TextBuffer) toggleBlockComment(r Range, pair CharacterPair) error {
+	start := tb.gapBuffer.GetOffsetAt(r.Start)
+	end := tb.gapBuffer.GetOffsetAt(r.End)
+	if start > end {
+		return errors.New("invalid range")
+	}
+
+	text := tb.gapBuffer.getTextInOffsets(start, end)
+	openLength := utf8.RuneCountInString(pair.Open)
+	closeLength := utf8.RuneCountInString(pair.Close)
+
+	var edits []Edit
+	trimmed := strings.TrimSpace(text)
+	switch {
+	case len(trimmed) >= len(pair.Open)+len(pair.Close) && strings.HasPrefix(trimmed, pair.Open) && strings.HasSuffix(trimmed, pair.Close):
+		// 范围内的文本已经被注释
+		openOffset := start + utf8.RuneCountInString(text[:strings.Index(text, trimmed)])
+		closeOffset := openOffset + utf8.RuneCountInString(trimmed) - closeLength
+		edits = tb.removeBlockComment(openOffset, closeOffset, pair)
+	case start >= openLength && end+closeLength <= tb.gapBuffer.GetLength() &&
+		tb.gapBuffer.getTextInOffsets(start-openLength, start) == pair.Open &&
+		tb.gapBuffer.getTextInOffsets(end, end+closeLength) == pair.Close:
+		// 范围紧邻注释符号
+		edits = tb.removeBlockComment(start-openLength, end, pair)
+	default:
+		edits = []Edit{
+			{Offset: end, NewText: " " + pair.Close},
+			{Offset: start, NewText: pair.Open + " "},
+		}
+	}
+
+	tb.pushOperation(&TextOperation{
+		Type:  operationTypeOf(edits),
+		Label: "Toggle Block Comment",
+		Edits: edits,
+	})
+
+	return nil
+}
+
+// removeBlockComment 生成删除块注释符号的编辑，注释符号内侧的一个空格也会被删除
+// openOffset是开始符号的偏移量，closeOffset是结束符号的偏移量
+// 调用方必须持有锁
+func (tb *TextBuffer) removeBlockComment(openOffset, closeOffset int, pair CharacterPair) []Edit {
+	openLength := utf8.RuneCountInString(pair.Open)
+
+	closeText := pair.Close
+	if closeOffset > openOffset+openLength && tb.gapBuffer.runeAt(closeOffset-1) == ' ' {
+		closeOffset--
+		closeText = " " + closeText
+	}
+	openText := pair.Open
+	if openOffset+openLength < closeOffset && tb.gapBuffer.runeAt(openOffset+openLength) == ' ' {
+		openText += " "
+	}
+
+	return []Edit{
+		{Offset: closeOffset, OldText: closeText},
+		{Offset: openOffset, OldText: openText},
+	}
+}
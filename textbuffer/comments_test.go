@@ -0,0 +1,95 @@
+package textbuffer
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestToggleLineComment(t *testing.T) {
+	config := &LanguageConfiguration{Comments: &CommentRule{LineComment: "//"}}
+	buffer := NewTextBufferWithText("func f() {\n    a()\n\n  b()\n}")
+	r := NewRange(Position{Line: 1, Column: 0}, Position{Line: 4, Column: 0})
+
+	// 注释符号对齐到最小缩进，空白行保持不变，结束于行首的行不包括在内
+	if err := buffer.ToggleLineComment(r, config); err != nil {
+		t.Fatalf("ToggleLineComment failed: %v", err)
+	}
+	expected := "func f() {\n  //   a()\n\n  // b()\n}"
+	if buffer.GetText() != expected {
+		t.Errorf("Expected %q, got %q", expected, buffer.GetText())
+	}
+
+	// 所有行都已经被注释时删除注释
+	if err := buffer.ToggleLineComment(r, config); err != nil {
+		t.Fatalf("ToggleLineComment failed: %v", err)
+	}
+	if buffer.GetText() != "func f() {\n    a()\n\n  b()\n}" {
+		t.Errorf("Expected comments to be removed, got %q", buffer.GetText())
+	}
+
+	// 部分行被注释时添加注释
+	buffer = NewTextBufferWithText("# a\nb")
+	buffer.SetLanguageConfiguration(&LanguageConfiguration{Comments: &CommentRule{LineComment: "#"}})
+	if err := buffer.ToggleLineComment(NewRange(Position{Line: 0, Column: 0}, Position{Line: 1, Column: 1}), nil); err != nil {
+		t.Fatalf("ToggleLineComment failed: %v", err)
+	}
+	if buffer.GetText() != "# # a\n# b" {
+		t.Errorf("Expected %q, got %q", "# # a\n# b", buffer.GetText())
+	}
+	if buffer.UndoLabel() != "Toggle Line Comment" {
+		t.Errorf("Expected a single undo step, got label '%s'", buffer.UndoLabel())
+	}
+
+	// 没有行注释时使用块注释
+	buffer = NewTextBufferWithText("text")
+	html := &LanguageConfiguration{Comments: &CommentRule{BlockComment: &CharacterPair{Open: "<!--", Close: "-->"}}}
+	if err := buffer.ToggleLineComment(NewRange(Position{Line: 0, Column: 0}, Position{Line: 0, Column: 4}), html); err != nil {
+		t.Fatalf("ToggleLineComment failed: %v", err)
+	}
+	if buffer.GetText() != "<!-- text -->" {
+		t.Errorf("Expected block comment fallback, got %q", buffer.GetText())
+	}
+
+	if err := buffer.ToggleLineComment(NewRange(Position{}, Position{}), &LanguageConfiguration{}); !errors.Is(err, ErrNoCommentRule) {
+		t.Errorf("Expected ErrNoCommentRule, got %v", err)
+	}
+}
+
+func TestToggleBlockComment(t *testing.T) {
+	config := &LanguageConfiguration{Comments: &CommentRule{BlockComment: &CharacterPair{Open: "/*", Close: "*/"}}}
+	buffer := NewTextBufferWithText("a := b + c")
+	r := NewRange(Position{Line: 0, Column: 5}, Position{Line: 0, Column: 10})
+
+	if err := buffer.ToggleBlockComment(r, config); err != nil {
+		t.Fatalf("ToggleBlockComment failed: %v", err)
+	}
+	if buffer.GetText() != "a := /* b + c */" {
+		t.Errorf("Expected %q, got %q", "a := /* b + c */", buffer.GetText())
+	}
+
+	// 范围包括注释符号时删除注释
+	if err := buffer.ToggleBlockComment(NewRange(Position{Line: 0, Column: 4}, Position{Line: 0, Column: 16}), config); err != nil {
+		t.Fatalf("ToggleBlockComment failed: %v", err)
+	}
+	if buffer.GetText() != "a := b + c" {
+		t.Errorf("Expected comment to be removed, got %q", buffer.GetText())
+	}
+
+	// 范围紧邻注释符号时删除注释
+	buffer = NewTextBufferWithText("x /*y*/")
+	if err := buffer.ToggleBlockComment(NewRange(Position{Line: 0, Column: 4}, Position{Line: 0, Column: 5}), config); err != nil {
+		t.Fatalf("ToggleBlockComment failed: %v", err)
+	}
+	if buffer.GetText() != "x y" {
+		t.Errorf("Expected %q, got %q", "x y", buffer.GetText())
+	}
+
+	// 每次切换是一个撤销步骤
+	if _, err := buffer.Undo(); err != nil || buffer.GetText() != "x /*y*/" {
+		t.Errorf("Expected undo to restore the comment, got %q (%v)", buffer.GetText(), err)
+	}
+
+	if err := buffer.ToggleBlockComment(r, &LanguageConfiguration{}); !errors.Is(err, ErrNoCommentRule) {
+		t.Errorf("Expected ErrNoCommentRule, got %v", err)
+	}
+}
@@ -0,0 +1,222 @@
+package textbuffer
+
+import (
+	"errors"
+	"sort"
+	"unicode/utf8"
+)
+
+// EditOperation 表示对文档的一次编辑请求
+// Range 基于编辑前的文档，同一批编辑的范围不能相互重叠
+type EditOperation struct {
+	// Range 被替换的范围
+	Range Range
+	// Text 替换后的文本，为空表示删除
+	Text string
+}
+
+// Edit 表示一个可逆的文本编辑，用精确的偏移量记录被替换和插入的文本
+// 一个TextOperation中的多个Edit按顺序应用，每个Edit的偏移量基于前一个Edit应用后的文本
+type Edit struct {
+	// Offset 编辑的起始偏移量（以rune为单位）
+	Offset int
+	// OldText 被替换的文本
+	OldText string
+	// NewText 插入的文本
+	NewText string
+}
+
+// Inverse 获取编辑的逆编辑
+func (e Edit) Inverse() Edit {
+	return Edit{
+		Offset:  e.Offset,
+		OldText: e.NewText,
+		NewText: e.OldText,
+	}
+}
+
+// OldEnd 获取被替换文本的结束偏移量
+func (e Edit) OldEnd() int {
+	return e.Offset + utf8.RuneCountInString(e.OldText)
+}
+
+// NewEnd 获取插入文本的结束偏移量
+func (e Edit) NewEnd() int {
+	return e.Offset + utf8.RuneCountInString(e.NewText)
+}
+
+// invertEdits 获取一组编辑的逆编辑，按相反的顺序排列
+func invertEdits(edits []Edit) []Edit {
+	result := make([]Edit, len(edits))
+	for i, edit := range edits {
+		result[len(edits)-1-i] = edit.Inverse()
+	}
+	return result
+}
+
+// offsetRange 表示一个偏移量区间[start, end)
+type offsetRange struct {
+	start int
+	end   int
+}
+
+// changedOffsetRanges 计算一组按顺序应用的编辑在最终文本中影响的区间
+func changedOffsetRanges(edits []Edit) []offsetRange {
+	var ranges []offsetRange
+
+	for _, edit := range edits {
+		oldEnd := edit.OldEnd()
+		delta := utf8.RuneCountInString(edit.NewText) - (oldEnd - edit.Offset)
+		current := offsetRange{start: edit.Offset, end: edit.NewEnd()}
+
+		kept := ranges[:0]
+		for _, r := range ranges {
+			switch {
+			case r.end < edit.Offset || (r.end == edit.Offset && r.start < r.end):
+				// 区间在编辑之前，不受影响
+				kept = append(kept, r)
+			case r.start > oldEnd || (r.start == oldEnd && edit.Offset < oldEnd):
+				// 区间在编辑之后，整体平移
+				kept = append(kept, offsetRange{start: r.start + delta, end: r.end + delta})
+			default:
+				// 区间与编辑重叠，合并到当前编辑的区间中
+				if r.start < current.start {
+					current.start = r.start
+				}
+				if r.end > oldEnd && r.end+delta > current.end {
+					current.end = r.end + delta
+				}
+			}
+		}
+		ranges = append(kept, current)
+	}
+
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].start < ranges[j].start
+	})
+
+	return ranges
+}
+
+// resolveEdits 将基于编辑前文档的编辑请求转换为可以按顺序应用的Edit列表
+// 编辑按起始偏移量从后往前应用，这样每个编辑的偏移量都不受其他编辑影响
+func (gb *GapBuffer) resolveEdits(operations []EditOperation) ([]Edit, error) {
+	type resolved struct {
+		index int
+		start int
+		end   int
+		text  string
+	}
+
+	items := make([]resolved, 0, len(operations))
+	for i, operation := range operations {
+		start := gb.GetOffsetAt(operation.Range.Start)
+		end := gb.GetOffsetAt(operation.Range.End)
+		if start > end {
+			return nil, errors.New("invalid range")
+		}
+		items = append(items, resolved{index: i, start: start, end: end, text: operation.Text})
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		if items[i].start != items[j].start {
+			return items[i].start < items[j].start
+		}
+		return items[i].index < items[j].index
+	})
+
+	for i := 1; i < len(items); i++ {
+		if items[i].start < items[i-1].end {
+			return nil, errors.New("overlapping ranges")
+		}
+	}
+
+	edits := make([]Edit, 0, len(items))
+	for i := len(items) - 1; i >= 0; i-- {
+		item := items[i]
+		edit := Edit{
+			Offset:  item.start,
+			OldText: gb.getTextInOffsets(item.start, item.end),
+			NewText: item.text,
+		}
+		if edit.OldText == edit.NewText {
+			continue
+		}
+		edits = append(edits, edit)
+	}
+
+	return edits, nil
+}
+
+// applyEdit 在缓冲区上应用一个编辑
+func (gb *GapBuffer) applyEdit(edit Edit) {
+	gb.Delete(edit.Offset, edit.OldEnd())
+	gb.Insert(edit.Offset, edit.NewText)
+}
+
+// transformEdit 将编辑a变换到编辑b之后的文本上，a和b必须基于同一个文本
+// 当a和b是同一位置的插入时，insertAfter决定a的文本是否放在b的文本之后
+// 如果两个编辑的范围重叠，返回false
+func transformEdit(a, b Edit, insertAfter bool) (Edit, bool) {
+	aEnd := a.OldEnd()
+	bEnd := b.OldEnd()
+
+	if a.Offset < bEnd && b.Offset < aEnd {
+		return a, false
+	}
+
+	bBefore := bEnd <= a.Offset
+	aBefore := aEnd <= b.Offset
+	if bBefore && aBefore {
+		// 两个编辑都是同一位置的插入
+		bBefore = insertAfter
+	}
+
+	if bBefore {
+		a.Offset += utf8.RuneCountInString(b.NewText) - (bEnd - b.Offset)
+	}
+	return a, true
+}
+
+// transformEdits 将一组按顺序应用的编辑变换到编辑b之后的文本上
+// edits的第一个编辑必须与b基于同一个文本；同时返回变换到edits之后的文本上的b
+func transformEdits(edits []Edit, b Edit) ([]Edit, Edit, bool) {
+	result := make([]Edit, len(edits))
+	for i, edit := range edits {
+		transformed, ok := transformEdit(edit, b, true)
+		if !ok {
+			return nil, b, false
+		}
+		result[i] = transformed
+
+		// 将b变换到当前编辑之后的文本上，用于变换后续的编辑
+		b, ok = transformEdit(b, edit, false)
+		if !ok {
+			return nil, b, false
+		}
+	}
+	return result, b, true
+}
+
+// minimalEdit 计算将oldText变为newText的最小单个编辑（去掉公共前缀和公共后缀）
+func minimalEdit(oldText, newText string) Edit {
+	oldRunes := []rune(oldText)
+	newRunes := []rune(newText)
+
+	prefix := 0
+	for prefix < len(oldRunes) && prefix < len(newRunes) && oldRunes[prefix] == newRunes[prefix] {
+		prefix++
+	}
+
+	suffix := 0
+	for suffix < len(oldRunes)-prefix && suffix < len(newRunes)-prefix &&
+		oldRunes[len(oldRunes)-1-suffix] == newRunes[len(newRunes)-1-suffix] {
+		suffix++
+	}
+
+	return Edit{
+		Offset:  prefix,
+		OldText: string(oldRunes[prefix : len(oldRunes)-suffix]),
+		NewText: string(newRunes[prefix : len(newRunes)-suffix]),
+	}
+}
@@ -0,0 +1,289 @@
+package textbuffer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// ErrUnencodableCharacter 表示文本中的字符无法用文件的编码表示
+var ErrUnencodableCharacter = errors.New("unencodable character")
+
+// Encoding 表示文件的字符编码
+type Encoding int
+
+const (
+	// EncodingUTF8 UTF-8编码
+	EncodingUTF8 Encoding = iota
+	// EncodingUTF16LE 小端序的UTF-16编码
+	EncodingUTF16LE
+	// EncodingUTF16BE 大端序的UTF-16编码
+	EncodingUTF16BE
+	// EncodingLatin1 ISO-8859-1编码，每个字节对应一个字符
+	EncodingLatin1
+)
+
+// String 获取编码的名称
+func (e Encoding) String() string {
+	switch e {
+	case EncodingUTF16LE:
+		return "utf-16le"
+	case EncodingUTF16BE:
+		return "utf-16be"
+	case EncodingLatin1:
+		return "latin1"
+	default:
+		return "utf-8"
+	}
+}
+
+// FileEncoding 描述文件的编码以及是否以字节顺序标记（BOM）开头
+type FileEncoding struct {
+	// Encoding 字符编码
+	Encoding Encoding
+	// BOM 文件是否以字节顺序标记开头
+	BOM bool
+}
+
+// String 获取文件编码的名称，带BOM的编码以" bom"结尾
+func (fe FileEncoding) String() string {
+	if fe.BOM {
+		return fe.Encoding.String() + " bom"
+	}
+	return fe.Encoding.String()
+}
+
+// 各种编码的字节顺序标记
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+)
+
+// bom 获取编码的字节顺序标记，Latin-1没有字节顺序标记
+func (e Encoding) bom() []byte {
+	switch e {
+	case EncodingUTF8:
+		return bomUTF8
+	case EncodingUTF16LE:
+		return bomUTF16LE
+	case EncodingUTF16BE:
+		return bomUTF16BE
+	default:
+		return nil
+	}
+}
+
+// 无效的字节被解码为U+10FF00到U+10FFFF之间的私用区字符，编码时还原为原来的字节，
+// 这样无效的字节序列在加载和保存之后保持不变。
+// 文件中本来就有的这个范围内的字符也按字节转义，保证还原时不会混淆
+const (
+	escapedByteBase rune = 0x10FF00
+	escapedByteLast rune = escapedByteBase + 0xFF
+)
+
+// isEscapedByte 判断字符是否是转义的字节
+func isEscapedByte(r rune) bool {
+	return r >= escapedByteBase && r <= escapedByteLast
+}
+
+// appendEscapedBytes 把每个字节转义为一个字符
+func appendEscapedBytes(runes []rune, data []byte) []rune {
+	for _, b := range data {
+		runes = append(runes, escapedByteBase+rune(b))
+	}
+	return runes
+}
+
+// DetectEncoding 检测文件内容的编码
+// 优先根据字节顺序标记判断，然后根据零字节的分布判断没有BOM的UTF-16，
+// 再判断是否是UTF-8，都不是时使用Latin-1
+func DetectEncoding(data []byte) FileEncoding {
+	switch {
+	case bytes.HasPrefix(data, bomUTF8):
+		return FileEncoding{Encoding: EncodingUTF8, BOM: true}
+	case bytes.HasPrefix(data, bomUTF16LE):
+		return FileEncoding{Encoding: EncodingUTF16LE, BOM: true}
+	case bytes.HasPrefix(data, bomUTF16BE):
+		return FileEncoding{Encoding: EncodingUTF16BE, BOM: true}
+	}
+
+	if encoding, ok := detectUTF16(data); ok {
+		return FileEncoding{Encoding: encoding}
+	}
+	if looksLikeUTF8(data) {
+		return FileEncoding{Encoding: EncodingUTF8}
+	}
+	return FileEncoding{Encoding: EncodingLatin1}
+}
+
+// detectUTF16 根据零字节的分布判断没有BOM的UTF-16
+// 以ASCII字符为主的UTF-16文本中，每个字符的高位字节几乎都是零
+func detectUTF16(data []byte) (Encoding, bool) {
+	if len(data) < 2 || len(data)%2 != 0 {
+		return 0, false
+	}
+
+	evenZeros, oddZeros := 0, 0
+	for i := 0; i < len(data); i += 2 {
+		if data[i] == 0 {
+			evenZeros++
+		}
+		if data[i+1] == 0 {
+			oddZeros++
+		}
+	}
+
+	// 至少一半的字符的高位字节是零，而低位字节几乎都不是零
+	units := len(data) / 2
+	switch {
+	case oddZeros*2 >= units && evenZeros*10 < units:
+		return EncodingUTF16LE, true
+	case evenZeros*2 >= units && oddZeros*10 < units:
+		return EncodingUTF16BE, true
+	default:
+		return 0, false
+	}
+}
+
+// looksLikeUTF8 判断内容是否是UTF-8
+// 包含少量无效字节的UTF-8仍然被认为是UTF-8，无效字节会被保留；
+// 没有任何有效的多字节字符时认为是Latin-1
+func looksLikeUTF8(data []byte) bool {
+	if utf8.Valid(data) {
+		return true
+	}
+
+	multiByte, invalid := 0, 0
+	for i := 0; i < len(data); {
+		r, size := utf8.DecodeRune(data[i:])
+		switch {
+		case r == utf8.RuneError && size == 1:
+			invalid++
+		case size > 1:
+			multiByte++
+		}
+		i += size
+	}
+	return multiByte > 0 && multiByte >= invalid
+}
+
+// DecodeText 把文件内容按编码解码为文本，开头的字节顺序标记不属于文本
+// 无效的字节序列被转义为私用区字符，EncodeText会把它们还原
+func DecodeText(data []byte, encoding FileEncoding) string {
+	if encoding.BOM {
+		data = bytes.TrimPrefix(data, encoding.Encoding.bom())
+	}
+
+	switch encoding.Encoding {
+	case EncodingUTF16LE, EncodingUTF16BE:
+		return string(decodeUTF16(data, encoding.Encoding == EncodingUTF16BE))
+	case EncodingLatin1:
+		runes := make([]rune, len(data))
+		for i, b := range data {
+			runes[i] = rune(b)
+		}
+		return string(runes)
+	default:
+		return string(decodeUTF8(data))
+	}
+}
+
+// decodeUTF8 解码UTF-8，转义无效的字节
+func decodeUTF8(data []byte) []rune {
+	runes := make([]rune, 0, len(data))
+	for i := 0; i < len(data); {
+		r, size := utf8.DecodeRune(data[i:])
+		if (r == utf8.RuneError && size == 1) || isEscapedByte(r) {
+			runes = appendEscapedBytes(runes, data[i:i+size])
+		} else {
+			runes = append(runes, r)
+		}
+		i += size
+	}
+	return runes
+}
+
+// decodeUTF16 解码UTF-16，转义不成对的代理项和末尾多余的字节
+func decodeUTF16(data []byte, bigEndian bool) []rune {
+	unitAt := func(i int) rune {
+		if bigEndian {
+			return rune(data[i])<<8 | rune(data[i+1])
+		}
+		return rune(data[i+1])<<8 | rune(data[i])
+	}
+
+	runes := make([]rune, 0, len(data)/2)
+	i := 0
+	for ; i+1 < len(data); i += 2 {
+		unit := unitAt(i)
+		if !utf16.IsSurrogate(unit) {
+			runes = append(runes, unit)
+			continue
+		}
+
+		if unit < 0xDC00 && i+3 < len(data) {
+			if r := utf16.DecodeRune(unit, unitAt(i+2)); r != utf8.RuneError {
+				if isEscapedByte(r) {
+					runes = appendEscapedBytes(runes, data[i:i+4])
+				} else {
+					runes = append(runes, r)
+				}
+				i += 2
+				continue
+			}
+		}
+		runes = appendEscapedBytes(runes, data[i:i+2])
+	}
+	return appendEscapedBytes(runes, data[i:])
+}
+
+// EncodeText 把文本按编码编码为文件内容，需要时在开头写入字节顺序标记
+// 转义的字节被还原为原来的字节；Latin-1无法表示的字符返回ErrUnencodableCharacter
+func EncodeText(text string, encoding FileEncoding) ([]byte, error) {
+	var data []byte
+	if encoding.BOM {
+		data = append(data, encoding.Encoding.bom()...)
+	}
+
+	offset := 0
+	for _, r := range text {
+		switch {
+		case isEscapedByte(r):
+			data = append(data, byte(r-escapedByteBase))
+		case encoding.Encoding == EncodingUTF16LE || encoding.Encoding == EncodingUTF16BE:
+			for _, unit := range utf16.AppendRune(nil, r) {
+				if encoding.Encoding == EncodingUTF16BE {
+					data = append(data, byte(unit>>8), byte(unit))
+				} else {
+					data = append(data, byte(unit), byte(unit>>8))
+				}
+			}
+		case encoding.Encoding == EncodingLatin1:
+			if r > 0xFF {
+				return nil, fmt.Errorf("%w: %q at offset %d in %s", ErrUnencodableCharacter, r, offset, encoding.Encoding)
+			}
+			data = append(data, byte(r))
+		default:
+			data = utf8.AppendRune(data, r)
+		}
+		offset++
+	}
+	return data, nil
+}
+
+// GetEncoding 获取保存文件时使用的编码，默认是没有BOM的UTF-8
+func (tb *TextBuffer) GetEncoding() FileEncoding {
+	tb.mutex.RLock()
+	defer tb.mutex.RUnlock()
+	return tb.encoding
+}
+
+// SetEncoding 设置保存文件时使用的编码，不改变文本内容
+func (tb *TextBuffer) SetEncoding(encoding FileEncoding) {
+	tb.mutex.Lock()
+	defer tb.unlock()
+	tb.encoding = encoding
+}
@@ -0,0 +1,113 @@
+package textbuffer
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectEncoding(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     []byte
+		expected FileEncoding
+	}{
+		{"empty", nil, FileEncoding{Encoding: EncodingUTF8}},
+		{"ascii", []byte("hello"), FileEncoding{Encoding: EncodingUTF8}},
+		{"utf-8", []byte("héllo 日本"), FileEncoding{Encoding: EncodingUTF8}},
+		{"utf-8 bom", []byte("\xEF\xBB\xBFhi"), FileEncoding{Encoding: EncodingUTF8, BOM: true}},
+		{"utf-16le bom", []byte("\xFF\xFEh\x00i\x00"), FileEncoding{Encoding: EncodingUTF16LE, BOM: true}},
+		{"utf-16be bom", []byte("\xFE\xFF\x00h\x00i"), FileEncoding{Encoding: EncodingUTF16BE, BOM: true}},
+		{"utf-16le", []byte("h\x00e\x00l\x00l\x00o\x00"), FileEncoding{Encoding: EncodingUTF16LE}},
+		{"utf-16be", []byte("\x00h\x00e\x00l\x00l\x00o"), FileEncoding{Encoding: EncodingUTF16BE}},
+		{"latin1", []byte("caf\xE9 cr\xE8me"), FileEncoding{Encoding: EncodingLatin1}},
+		{"utf-8 with invalid bytes", []byte("日本\xFF語"), FileEncoding{Encoding: EncodingUTF8}},
+	}
+
+	for _, test := range tests {
+		if actual := DetectEncoding(test.data); actual != test.expected {
+			t.Errorf("%s: expected %s, got %s", test.name, test.expected, actual)
+		}
+	}
+}
+
+func TestDecodeText(t *testing.T) {
+	if text := DecodeText([]byte("\xFF\xFEh\x00i\x00=\xD8\x00\xDE"), FileEncoding{Encoding: EncodingUTF16LE, BOM: true}); text != "hi😀" {
+		t.Errorf("Unexpected UTF-16 text %q", text)
+	}
+	if text := DecodeText([]byte("caf\xE9"), FileEncoding{Encoding: EncodingLatin1}); text != "café" {
+		t.Errorf("Unexpected Latin-1 text %q", text)
+	}
+	if text := DecodeText([]byte("a\xFFb"), FileEncoding{Encoding: EncodingUTF8}); text != "a\U0010FFFFb" {
+		t.Errorf("Expected the invalid byte to be escaped, got %q", text)
+	}
+
+	_, err := EncodeText("日本", FileEncoding{Encoding: EncodingLatin1})
+	if !errors.Is(err, ErrUnencodableCharacter) {
+		t.Errorf("Expected ErrUnencodableCharacter, got %v", err)
+	}
+}
+
+func TestEncodingRoundTrip(t *testing.T) {
+	inputs := [][]byte{
+		[]byte("plain\r\ntext\n"),
+		[]byte("\xEF\xBB\xBFwith bom 日本\n"),
+		[]byte("invalid \xC3\x28 and \xED\xA0\x80 surrogate, truncated \xE6\x97"),
+		// 文件中本来就有的转义范围内的字符
+		[]byte("escape range \xF4\x8F\xBC\x80 日本"),
+		[]byte("\xFF\xFEh\x00\x00\xD8i\x00"),
+		[]byte("\xFE\xFF\x00h\x00i\x00"),
+		[]byte("h\x00e\x00l\x00l\x00o\x00\n\x00=\xD8\x00\xDE"),
+		[]byte("caf\xE9 \x80\x81\xFF"),
+		{0x00, 0x01, 0xFE, 0xFF},
+	}
+
+	for _, input := range inputs {
+		encoding := DetectEncoding(input)
+		data, err := EncodeText(DecodeText(input, encoding), encoding)
+		if err != nil {
+			t.Errorf("%q (%s): %v", input, encoding, err)
+			continue
+		}
+		if !bytes.Equal(data, input) {
+			t.Errorf("%s: expected %q, got %q", encoding, input, data)
+		}
+	}
+}
+
+func TestOpenAndSaveEncoding(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file.txt")
+	original := []byte("\xFF\xFEa\x00\n\x00")
+	if err := os.WriteFile(path, original, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	buffer, err := OpenFile(path)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	if buffer.GetText() != "a\n" || buffer.GetEncoding() != (FileEncoding{Encoding: EncodingUTF16LE, BOM: true}) {
+		t.Errorf("Unexpected text %q or encoding %s", buffer.GetText(), buffer.GetEncoding())
+	}
+
+	buffer.Insert(Position{Line: 1, Column: 0}, "b")
+	if err := buffer.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if !bytes.Equal(data, []byte("\xFF\xFEa\x00\n\x00b\x00")) {
+		t.Errorf("Unexpected file content %q", data)
+	}
+
+	// 改变编码后保存
+	buffer.SetEncoding(FileEncoding{Encoding: EncodingUTF8})
+	if err := buffer.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	data, _ = os.ReadFile(path)
+	if string(data) != "a\nb" {
+		t.Errorf("Unexpected file content %q", data)
+	}
+}
@@ -0,0 +1,252 @@
+package textbuffer
+
+import (
+	"strings"
+)
+
+// 事件在持有写锁时排队，在释放写锁之后才调用监听器，
+// 这样监听器可以安全地读取或修改文本缓冲区
+
+// listenerList 是一组监听器，每个监听器有唯一的标识用于移除
+type listenerList[T any] struct {
+	nextID    int
+	listeners []listenerEntry[T]
+}
+
+// listenerEntry 表示一个已注册的监听器
+type listenerEntry[T any] struct {
+	id       int
+	listener func(T)
+}
+
+// add 添加一个监听器，返回它的标识
+func (l *listenerList[T]) add(listener func(T)) int {
+	l.nextID++
+	l.listeners = append(l.listeners, listenerEntry[T]{id: l.nextID, listener: listener})
+	return l.nextID
+}
+
+// remove 移除指定标识的监听器
+func (l *listenerList[T]) remove(id int) {
+	for i, entry := range l.listeners {
+		if entry.id == id {
+			l.listeners = append(l.listeners[:i:i], l.listeners[i+1:]...)
+			return
+		}
+	}
+}
+
+// snapshot 获取当前所有监听器的副本
+func (l *listenerList[T]) snapshot() []func(T) {
+	result := make([]func(T), len(l.listeners))
+	for i, entry := range l.listeners {
+		result[i] = entry.listener
+	}
+	return result
+}
+
+// OnUndoStateChanged 注册一个监听器，在撤销/重做状态变化时调用
+// 返回的函数用于移除监听器
+func (tb *TextBuffer) OnUndoStateChanged(listener func(UndoState)) func() {
+	tb.listenerMutex.Lock()
+	defer tb.listenerMutex.Unlock()
+
+	id := tb.undoStateListeners.add(listener)
+	return func() {
+		tb.listenerMutex.Lock()
+		defer tb.listenerMutex.Unlock()
+		tb.undoStateListeners.remove(id)
+	}
+}
+
+// queueUndoStateChanged 将撤销/重做状态变化事件加入队列
+// 调用方必须持有写锁
+func (tb *TextBuffer) queueUndoStateChanged() {
+	tb.listenerMutex.Lock()
+	listeners := tb.undoStateListeners.snapshot()
+	tb.listenerMutex.Unlock()
+
+	if len(listeners) == 0 {
+		return
+	}
+
+	state := tb.undoState()
+	tb.pendingEvents = append(tb.pendingEvents, func() {
+		for _, listener := range listeners {
+			listener(state)
+		}
+	})
+}
+
+// LineChange 描述一个编辑对行的影响：从StartLine开始的RemovedLines+1行被替换为AddedLines+1行
+type LineChange struct {
+	// StartLine 编辑开始的行
+	StartLine int
+	// RemovedLines 编辑删除的换行符数量
+	RemovedLines int
+	// AddedLines 编辑插入的换行符数量
+	AddedLines int
+}
+
+// ContentChangeEvent 描述一次加锁期间对文本内容的修改
+type ContentChangeEvent struct {
+	// Edits 按应用顺序排列的编辑，每个编辑的偏移量基于应用前一个编辑之后的文本
+	Edits []Edit
+	// LineChanges 与Edits一一对应，每个编辑影响的行
+	LineChanges []LineChange
+	// VersionID 应用所有编辑之后的版本号，每个编辑使版本号加一
+	VersionID int
+	// LineCount 应用所有编辑之后的行数
+	LineCount int
+}
+
+// OnContentChanged 注册一个监听器，在文本内容变化之后调用，包括撤销和重做
+// 同一次加锁期间的编辑合并为一个事件；返回的函数用于移除监听器
+func (tb *TextBuffer) OnContentChanged(listener func(ContentChangeEvent)) func() {
+	tb.listenerMutex.Lock()
+	defer tb.listenerMutex.Unlock()
+
+	id := tb.contentChangeListeners.add(listener)
+	return func() {
+		tb.listenerMutex.Lock()
+		defer tb.listenerMutex.Unlock()
+		tb.contentChangeListeners.remove(id)
+	}
+}
+
+// queueContentChanged 将一个编辑加入内容变化事件，第一次编辑时将事件加入队列
+// 调用方必须持有写锁
+func (tb *TextBuffer) queueContentChanged(edit Edit) {
+	if tb.pendingContentChange == nil {
+		tb.listenerMutex.Lock()
+		listeners := tb.contentChangeListeners.snapshot()
+		tb.listenerMutex.Unlock()
+
+		if len(listeners) == 0 {
+			return
+		}
+
+		event := &ContentChangeEvent{}
+		tb.pendingContentChange = event
+		tb.pendingEvents = append(tb.pendingEvents, func() {
+			for _, listener := range listeners {
+				listener(*event)
+			}
+		})
+	}
+
+	// 编辑已经应用，编辑开始的位置在编辑前后的行号相同
+	tb.pendingContentChange.Edits = append(tb.pendingContentChange.Edits, edit)
+	tb.pendingContentChange.LineChanges = append(tb.pendingContentChange.LineChanges, LineChange{
+		StartLine:    tb.gapBuffer.GetPositionAt(edit.Offset).Line,
+		RemovedLines: strings.Count(edit.OldText, "\n"),
+		AddedLines:   strings.Count(edit.NewText, "\n"),
+	})
+	tb.pendingContentChange.VersionID = tb.versionID
+	tb.pendingContentChange.LineCount = tb.gapBuffer.GetLineCount()
+}
+
+// TokensChangedEvent 描述一个行范围内的分词结果可能发生了变化
+// 分词是延迟进行的，文本修改之后，行的分词结果在被读取时才重新计算并触发事件
+type TokensChangedEvent struct {
+	// StartLine 第一个变化的行
+	StartLine int
+	// EndLine 最后一个变化的行之后的行（不包含）
+	EndLine int
+	// VersionID 行号对应的文本版本号
+	VersionID int
+}
+
+// OnTokensChanged 注册一个监听器，在行的分词结果变化之后调用，包括设置分词器和安装后台分词的结果
+// 同一次加锁期间同一个版本的变化合并为一个事件；返回的函数用于移除监听器
+func (tb *TextBuffer) OnTokensChanged(listener func(TokensChangedEvent)) func() {
+	tb.listenerMutex.Lock()
+	defer tb.listenerMutex.Unlock()
+
+	id := tb.tokensChangedListeners.add(listener)
+	return func() {
+		tb.listenerMutex.Lock()
+		defer tb.listenerMutex.Unlock()
+		tb.tokensChangedListeners.remove(id)
+	}
+}
+
+// queueTokensChanged 将[startLine, endLine)的分词结果变化加入事件，必要时将新的事件加入队列
+// 调用方必须持有写锁
+func (tb *TextBuffer) queueTokensChanged(startLine, endLine int) {
+	if event := tb.pendingTokensChange; event != nil && event.VersionID == tb.versionID {
+		event.StartLine = min(event.StartLine, startLine)
+		event.EndLine = max(event.EndLine, endLine)
+		return
+	}
+
+	tb.listenerMutex.Lock()
+	listeners := tb.tokensChangedListeners.snapshot()
+	tb.listenerMutex.Unlock()
+
+	if len(listeners) == 0 {
+		return
+	}
+
+	event := &TokensChangedEvent{StartLine: startLine, EndLine: endLine, VersionID: tb.versionID}
+	tb.pendingTokensChange = event
+	tb.pendingEvents = append(tb.pendingEvents, func() {
+		for _, listener := range listeners {
+			listener(*event)
+		}
+	})
+}
+
+// SavedEvent 描述文本与文件的内容变为一致
+type SavedEvent struct {
+	// Path 文件的路径
+	Path string
+	// VersionID 与文件内容一致的文本版本号
+	VersionID int
+}
+
+// OnSaved 注册一个监听器，在文本被保存到文件，或者通过ReloadAtVersion、MarkSaved
+// 记录文本与文件一致之后调用；返回的函数用于移除监听器
+func (tb *TextBuffer) OnSaved(listener func(SavedEvent)) func() {
+	tb.listenerMutex.Lock()
+	defer tb.listenerMutex.Unlock()
+
+	id := tb.savedListeners.add(listener)
+	return func() {
+		tb.listenerMutex.Lock()
+		defer tb.listenerMutex.Unlock()
+		tb.savedListeners.remove(id)
+	}
+}
+
+// queueSaved 将文本与文件一致的事件加入队列
+// 调用方必须持有写锁
+func (tb *TextBuffer) queueSaved(path string, versionID int) {
+	tb.listenerMutex.Lock()
+	listeners := tb.savedListeners.snapshot()
+	tb.listenerMutex.Unlock()
+
+	if len(listeners) == 0 {
+		return
+	}
+
+	event := SavedEvent{Path: path, VersionID: versionID}
+	tb.pendingEvents = append(tb.pendingEvents, func() {
+		for _, listener := range listeners {
+			listener(event)
+		}
+	})
+}
+
+// unlock 释放写锁，然后依次触发排队的事件
+func (tb *TextBuffer) unlock() {
+	events := tb.pendingEvents
+	tb.pendingEvents = nil
+	tb.pendingContentChange = nil
+	tb.pendingTokensChange = nil
+	tb.mutex.Unlock()
+
+	for _, event := range events {
+		event()
+	}
+}
@@ -0,0 +1,286 @@
+package textbuffer
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// ErrNoFilePath 表示文本缓冲区没有关联的文件
+var ErrNoFilePath = errors.New("no file path")
+
+// ErrVersionChanged 表示文本在获取版本号之后被修改
+var ErrVersionChanged = errors.New("text changed since the expected version")
+
+// defaultFileMode 是新建文件的权限
+const defaultFileMode fs.FileMode = 0o644
+
+// FileInfo 记录文件在最近一次加载或保存时的状态，用于检测外部修改
+type FileInfo struct {
+	// Path 文件的路径（打开或保存时使用的路径，可能是符号链接）
+	Path string
+	// ModTime 文件的修改时间
+	ModTime time.Time
+	// Size 文件的大小（字节）
+	Size int64
+	// Mode 文件的权限
+	Mode fs.FileMode
+}
+
+// fileInfoOf 根据文件的状态创建FileInfo
+func fileInfoOf(path string, info fs.FileInfo) *FileInfo {
+	return &FileInfo{
+		Path:    path,
+		ModTime: info.ModTime(),
+		Size:    info.Size(),
+		Mode:    info.Mode().Perm(),
+	}
+}
+
+// OpenFile 从文件加载文本缓冲区，检测文件的编码，并记录文件的状态
+// 文件的状态在读取内容之前获取，读取期间的外部修改之后仍然可以被检测到
+func OpenFile(path string) (*TextBuffer, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	encoding := DetectEncoding(data)
+	tb := NewTextBufferWithText(DecodeText(data, encoding))
+	tb.encoding = encoding
+	tb.fileInfo = fileInfoOf(path, info)
+	return tb, nil
+}
+
+// GetFileInfo 获取最近一次加载或保存时文件的状态，没有关联的文件时返回false
+func (tb *TextBuffer) GetFileInfo() (FileInfo, bool) {
+	tb.mutex.RLock()
+	defer tb.mutex.RUnlock()
+	if tb.fileInfo == nil {
+		return FileInfo{}, false
+	}
+	return *tb.fileInfo, true
+}
+
+// GetFilePath 获取关联的文件路径，没有关联的文件时返回空字符串
+func (tb *TextBuffer) GetFilePath() string {
+	tb.mutex.RLock()
+	defer tb.mutex.RUnlock()
+	if tb.fileInfo == nil {
+		return ""
+	}
+	return tb.fileInfo.Path
+}
+
+// IsDirty 判断文本是否在最近一次加载或保存之后被修改
+// 撤销到保存时的状态后不再是修改过的
+func (tb *TextBuffer) IsDirty() bool {
+	tb.mutex.RLock()
+	defer tb.mutex.RUnlock()
+	return tb.currentOperationID() != tb.savedOperationID
+}
+
+// currentOperationID 获取撤销栈顶的操作标识，撤销栈为空时返回0
+// 调用方必须持有锁
+func (tb *TextBuffer) currentOperationID() uint64 {
+	if operation := tb.undoStack.PeekUndo(); operation != nil {
+		return operation.ID
+	}
+	return 0
+}
+
+// HasExternalChanges 判断文件在最近一次加载或保存之后是否被其他程序修改
+// 根据文件的修改时间和大小判断，文件被删除时也返回true
+func (tb *TextBuffer) HasExternalChanges() (bool, error) {
+	tb.mutex.RLock()
+	fileInfo := tb.fileInfo
+	tb.mutex.RUnlock()
+
+	if fileInfo == nil {
+		return false, ErrNoFilePath
+	}
+	info, err := os.Stat(fileInfo.Path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return true, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return !info.ModTime().Equal(fileInfo.ModTime) || info.Size() != fileInfo.Size, nil
+}
+
+// Save 将文本保存到关联的文件
+func (tb *TextBuffer) Save() error {
+	return tb.SaveWithOptions(SaveOptions{})
+}
+
+// SaveWithOptions 先按options对文本进行保存前的转换，再保存到关联的文件
+func (tb *TextBuffer) SaveWithOptions(options SaveOptions) error {
+	path := tb.GetFilePath()
+	if path == "" {
+		return ErrNoFilePath
+	}
+	return tb.SaveToWithOptions(path, options)
+}
+
+// SaveTo 将文本按GetEncoding的编码保存到指定的文件，并把它作为关联的文件
+// 文本先写入同一目录下的临时文件并同步到磁盘，然后重命名覆盖目标文件，
+// 这样保存中断时不会留下不完整的文件。已有文件的权限会被保留，尽可能保留所有者；
+// 路径是符号链接时写入链接指向的文件，链接本身保持不变
+func (tb *TextBuffer) SaveTo(path string) error {
+	return tb.SaveToWithOptions(path, SaveOptions{})
+}
+
+// SaveToWithOptions 先按options对文本进行保存前的转换，再保存到指定的文件
+// 转换作为一个可撤销的操作应用，与读取要保存的文本在同一次持有锁期间完成，
+// 保存的内容一定是转换后的文本
+func (tb *TextBuffer) SaveToWithOptions(path string, options SaveOptions) error {
+	if err := options.validate(); err != nil {
+		return err
+	}
+
+	// 不在持有锁的时候写文件，保存期间的编辑会使文本缓冲区变为修改过的
+	tb.mutex.Lock()
+	tb.applySaveTransforms(options)
+	text := tb.gapBuffer.GetText()
+	operationID := tb.currentOperationID()
+	versionID := tb.versionID
+	encoding := tb.encoding
+	tb.unlock()
+
+	data, err := EncodeText(text, encoding)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(path, data); err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	tb.mutex.Lock()
+	defer tb.unlock()
+	tb.fileInfo = fileInfoOf(path, info)
+	tb.savedOperationID = operationID
+	tb.queueSaved(path, versionID)
+	return nil
+}
+
+// resolveSymlinks 获取路径最终指向的文件，文件不存在时返回路径本身
+func resolveSymlinks(path string) (string, error) {
+	target, err := filepath.EvalSymlinks(path)
+	if err == nil {
+		return target, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
+
+	// 指向不存在文件的符号链接，在链接指向的位置创建文件
+	if link, linkErr := os.Readlink(path); linkErr == nil {
+		if !filepath.IsAbs(link) {
+			link = filepath.Join(filepath.Dir(path), link)
+		}
+		return link, nil
+	}
+	return path, nil
+}
+
+// writeFileAtomic 通过临时文件和重命名原子地写入文件
+func writeFileAtomic(path string, data []byte) (err error) {
+	target, err := resolveSymlinks(path)
+	if err != nil {
+		return err
+	}
+
+	mode := defaultFileMode
+	existing, statErr := os.Stat(target)
+	if statErr == nil {
+		if !existing.Mode().IsRegular() {
+			return fmt.Errorf("%s is not a regular file", target)
+		}
+		mode = existing.Mode().Perm()
+	}
+
+	dir := filepath.Dir(target)
+	temp, err := os.CreateTemp(dir, "."+filepath.Base(target)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			temp.Close()
+			os.Remove(temp.Name())
+		}
+	}()
+
+	if _, err = temp.Write(data); err != nil {
+		return err
+	}
+	if err = temp.Sync(); err != nil {
+		return err
+	}
+	if err = temp.Chmod(mode); err != nil {
+		return err
+	}
+	if statErr == nil {
+		preserveOwnership(temp, existing)
+	}
+	if err = temp.Close(); err != nil {
+		return err
+	}
+	if err = os.Rename(temp.Name(), target); err != nil {
+		return err
+	}
+
+	// 同步目录，使重命名在断电后仍然有效
+	syncDir(dir)
+	return nil
+}
+
+// ReloadAtVersion 在文本的版本号仍为versionID时，把从文件重新加载的编辑作为一个可撤销的操作应用，
+// 然后记录文件的编码和状态，文本缓冲区变为未修改的；operations为空时只记录文件的编码和状态
+// 所有修改在同一次持有锁期间完成，文本已经被修改时不做任何修改并返回ErrVersionChanged
+// info是读取文件内容之前获取的文件状态
+func (tb *TextBuffer) ReloadAtVersion(versionID int, operations []EditOperation, encoding FileEncoding, path string, info fs.FileInfo) error {
+	tb.mutex.Lock()
+	defer tb.unlock()
+
+	if tb.versionID != versionID {
+		return ErrVersionChanged
+	}
+	edits, err := tb.gapBuffer.resolveEdits(operations)
+	if err != nil {
+		return err
+	}
+
+	tb.pushOperation(&TextOperation{
+		Type:  operationTypeOf(edits),
+		Label: "Reload",
+		Edits: edits,
+	})
+	tb.encoding = encoding
+	tb.fileInfo = fileInfoOf(path, info)
+	tb.savedOperationID = tb.currentOperationID()
+	tb.queueSaved(path, tb.versionID)
+	return nil
+}
+
+// MarkSaved 记录文本与文件的内容一致，例如在文件被外部修改后重新加载了文本
+// info是读取文件内容之前获取的文件状态，之后的修改仍然可以被检测到
+func (tb *TextBuffer) MarkSaved(path string, info fs.FileInfo) {
+	tb.mutex.Lock()
+	defer tb.unlock()
+	tb.fileInfo = fileInfoOf(path, info)
+	tb.savedOperationID = tb.currentOperationID()
+	tb.queueSaved(path, tb.versionID)
+}
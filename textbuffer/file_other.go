@@ -0,0 +1,14 @@
+//go:build !unix
+
+package textbuffer
+
+import (
+	"io/fs"
+	"os"
+)
+
+// preserveOwnership 在不支持文件所有者的平台上不做任何事
+func preserveOwnership(file *os.File, original fs.FileInfo) {}
+
+// syncDir 在不支持同步目录的平台上不做任何事
+func syncDir(dir string) {}
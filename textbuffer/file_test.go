@@ -0,0 +1,171 @@
+package textbuffer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestOpenAndSaveFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "file.txt")
+	if err := os.WriteFile(path, []byte("hello\nworld\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	buffer, err := OpenFile(path)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	if buffer.GetText() != "hello\nworld\n" {
+		t.Errorf("Unexpected text '%s'", buffer.GetText())
+	}
+	if buffer.GetFilePath() != path || buffer.IsDirty() {
+		t.Errorf("Expected a clean buffer for %s", path)
+	}
+
+	buffer.Insert(Position{Line: 0, Column: 5}, ",")
+	if !buffer.IsDirty() {
+		t.Errorf("Expected the buffer to be dirty after an edit")
+	}
+	buffer.Undo()
+	if buffer.IsDirty() {
+		t.Errorf("Expected the buffer to be clean after undoing the edit")
+	}
+	buffer.Redo()
+
+	if err := buffer.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+	if buffer.IsDirty() {
+		t.Errorf("Expected the buffer to be clean after saving")
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "hello,\nworld\n" {
+		t.Errorf("Unexpected file content '%s'", data)
+	}
+
+	// 保留文件的权限，不留下临时文件
+	info, _ := os.Stat(path)
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("Expected mode 0600, got %v", info.Mode().Perm())
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("Expected only the saved file, got %d entries", len(entries))
+	}
+
+	// 新文件使用默认权限
+	newPath := filepath.Join(dir, "new.txt")
+	if err := buffer.SaveTo(newPath); err != nil {
+		t.Fatalf("SaveTo failed: %v", err)
+	}
+	info, _ = os.Stat(newPath)
+	if info.Mode().Perm() != defaultFileMode || buffer.GetFilePath() != newPath {
+		t.Errorf("Unexpected mode %v or path %s", info.Mode().Perm(), buffer.GetFilePath())
+	}
+
+	if err := NewTextBuffer().Save(); err != ErrNoFilePath {
+		t.Errorf("Expected ErrNoFilePath, got %v", err)
+	}
+}
+
+func TestSaveThroughSymlink(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "target.txt")
+	link := filepath.Join(dir, "link.txt")
+	if err := os.WriteFile(target, []byte("old"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("target.txt", link); err != nil {
+		t.Skipf("Symlinks are not supported: %v", err)
+	}
+
+	buffer, err := OpenFile(link)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+	buffer.SetText("new")
+	if err := buffer.Save(); err != nil {
+		t.Fatalf("Save failed: %v", err)
+	}
+
+	info, err := os.Lstat(link)
+	if err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("Expected the symlink to be kept")
+	}
+	data, _ := os.ReadFile(target)
+	if string(data) != "new" {
+		t.Errorf("Expected the target to be written, got '%s'", data)
+	}
+}
+
+func TestHasExternalChanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file.txt")
+	buffer := NewTextBufferWithText("text")
+	if _, err := buffer.HasExternalChanges(); err != ErrNoFilePath {
+		t.Errorf("Expected ErrNoFilePath, got %v", err)
+	}
+	if err := buffer.SaveTo(path); err != nil {
+		t.Fatalf("SaveTo failed: %v", err)
+	}
+
+	changed, err := buffer.HasExternalChanges()
+	if err != nil || changed {
+		t.Errorf("Expected no external changes, got %v, %v", changed, err)
+	}
+
+	// 大小不变时根据修改时间判断
+	if err := os.WriteFile(path, []byte("TEXT"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Hour)
+	os.Chtimes(path, later, later)
+	if changed, _ := buffer.HasExternalChanges(); !changed {
+		t.Errorf("Expected external changes after modifying the file")
+	}
+
+	os.Remove(path)
+	if changed, _ := buffer.HasExternalChanges(); !changed {
+		t.Errorf("Expected external changes after deleting the file")
+	}
+}
+
+func TestSaveWithOptions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file.txt")
+	if err := os.WriteFile(path, []byte("a  \r\nb\t\n\n\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	buffer, err := OpenFile(path)
+	if err != nil {
+		t.Fatalf("OpenFile failed: %v", err)
+	}
+
+	err = buffer.SaveWithOptions(SaveOptions{
+		TrimTrailingWhitespace: true,
+		TrimFinalNewlines:      true,
+		NormalizeEndOfLine:     true,
+		EndOfLine:              EndOfLineLF,
+	})
+	if err != nil {
+		t.Fatalf("SaveWithOptions failed: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "a\nb\n" {
+		t.Errorf("Expected transformed file content, got %q", data)
+	}
+	if buffer.GetText() != "a\nb\n" || buffer.IsDirty() {
+		t.Errorf("Expected a clean transformed buffer, got %q", buffer.GetText())
+	}
+
+	// 转换是一个撤销步骤，撤销后文本与保存的文件不一致
+	buffer.Undo()
+	if buffer.GetText() != "a  \r\nb\t\n\n\n" || !buffer.IsDirty() {
+		t.Errorf("Expected undo to restore the original text, got %q", buffer.GetText())
+	}
+
+	if err := buffer.SaveWithOptions(SaveOptions{InsertFinalNewline: true, RemoveFinalNewline: true}); err == nil {
+		t.Errorf("Expected an error for conflicting options")
+	}
+}
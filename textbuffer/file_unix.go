@@ -0,0 +1,28 @@
+//go:build unix
+
+package textbuffer
+
+import (
+	"io/fs"
+	"os"
+	"syscall"
+)
+
+// preserveOwnership 尽可能把文件的所有者设置为原文件的所有者，没有权限时忽略
+func preserveOwnership(file *os.File, original fs.FileInfo) {
+	stat, ok := original.Sys().(*syscall.Stat_t)
+	if !ok {
+		return
+	}
+	_ = file.Chown(int(stat.Uid), int(stat.Gid))
+}
+
+// syncDir 将目录的修改同步到磁盘，失败时忽略
+func syncDir(dir string) {
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	defer d.Close()
+	_ = d.Sync()
+}
@@ -0,0 +1,239 @@
+package textbuffer
+
+import (
+	"regexp"
+	"sort"
+)
+
+// 默认的最大折叠范围数量
+const defaultMaxFoldingRanges = 5000
+
+// FoldingRangeKind 表示折叠范围的类型
+type FoldingRangeKind string
+
+const (
+	// FoldingRangeKindIndent 表示根据缩进计算的折叠范围
+	FoldingRangeKindIndent FoldingRangeKind = ""
+	// FoldingRangeKindRegion 表示根据区域标记计算的折叠范围
+	FoldingRangeKindRegion FoldingRangeKind = "region"
+)
+
+// FoldingRange 表示一个可以折叠的行区域
+type FoldingRange struct {
+	// StartLine 起始行，折叠后仍然可见
+	StartLine int
+	// EndLine 结束行
+	EndLine int
+	// Kind 折叠范围的类型
+	Kind FoldingRangeKind
+}
+
+// FoldingMarkers 描述区域的开始和结束标记，例如"// #region"和"// #endregion"
+type FoldingMarkers struct {
+	// Start 匹配区域开始行的正则表达式
+	Start *regexp.Regexp
+	// End 匹配区域结束行的正则表达式
+	End *regexp.Regexp
+}
+
+// FoldingRules 描述一种语言的折叠规则
+type FoldingRules struct {
+	// OffSide 是否是越位规则语言（例如Python），此时区域末尾的空行属于前一个区域
+	OffSide bool
+	// Markers 区域标记，为空时只根据缩进计算
+	Markers *FoldingMarkers
+}
+
+// FoldingOptions 描述折叠范围的计算选项
+type FoldingOptions struct {
+	// TabSize 计算缩进宽度时使用的制表符宽度
+	TabSize int
+	// MaxRanges 最多返回的折叠范围数量，超出时优先丢弃嵌套较深的范围
+	MaxRanges int
+}
+
+// 行的区域标记类型
+const (
+	foldingMarkerNone = iota
+	foldingMarkerStart
+	foldingMarkerEnd
+)
+
+// foldingLineInfo 缓存一行的缩进和区域标记
+type foldingLineInfo struct {
+	// 缩进的可见宽度，只包含空白字符的行为-1
+	indent int
+	// 区域标记类型
+	marker int
+}
+
+// foldingIndex 缓存每一行的折叠信息，编辑时只重新扫描受影响的行
+type foldingIndex struct {
+	lines lineDataCache[foldingLineInfo]
+}
+
+// linesChanged 使受编辑影响的行失效
+func (fi *foldingIndex) linesChanged(startLine, removedLines, addedLines int) {
+	fi.lines.linesChanged(startLine, removedLines, addedLines)
+}
+
+// SetFoldingOptions 设置折叠范围的计算选项
+func (tb *TextBuffer) SetFoldingOptions(options FoldingOptions) {
+	tb.mutex.Lock()
+	defer tb.unlock()
+
+	tb.foldingOptions = options
+	if tb.folding != nil {
+		tb.folding.lines.invalidateAll()
+	}
+}
+
+// foldingLine 获取一行的折叠信息，优先使用缓存
+// 调用方必须持有写锁
+func (tb *TextBuffer) foldingLine(lineIndex int, tabSize int, markers *FoldingMarkers) foldingLineInfo {
+	if info, ok := tb.folding.lines.get(lineIndex); ok {
+		return info
+	}
+
+	text := tb.lineText(lineIndex)
+	info := foldingLineInfo{indent: -1, marker: foldingMarkerNone}
+	if indent := leadingWhitespace(text); indent != text {
+		info.indent = visibleColumn(indent, tabSize)
+	}
+	if markers != nil && info.indent >= 0 {
+		if markers.Start != nil && markers.Start.MatchString(text) {
+			info.marker = foldingMarkerStart
+		} else if markers.End != nil && markers.End.MatchString(text) {
+			info.marker = foldingMarkerEnd
+		}
+	}
+
+	tb.folding.lines.set(lineIndex, info)
+	return info
+}
+
+// previousRegion 表示从下往上扫描时尚未闭合的区域
+type previousRegion struct {
+	// 区域的缩进，区域结束标记为-2
+	indent int
+	// 区域之后第一行的行号
+	endAbove int
+	// 区域的起始行
+	line int
+}
+
+// ComputeFoldingRanges 计算可以折叠的行区域
+// 折叠范围根据缩进层级和语言配置中的区域标记计算，结果按起始行排序
+func (tb *TextBuffer) ComputeFoldingRanges() []FoldingRange {
+	tb.mutex.Lock()
+	defer tb.unlock()
+
+	if tb.folding == nil {
+		tb.folding = &foldingIndex{}
+		tb.addLineObserver(tb.folding)
+	}
+
+	tabSize := tb.foldingOptions.TabSize
+	if tabSize <= 0 {
+		tabSize = 4
+	}
+	var rules FoldingRules
+	if folding := tb.getLanguageConfiguration().Folding; folding != nil {
+		rules = *folding
+	}
+
+	lineCount := tb.gapBuffer.GetLineCount()
+	tb.folding.lines.resize(lineCount)
+
+	type indentedRange struct {
+		FoldingRange
+		indent int
+	}
+	var ranges []indentedRange
+
+	// 从下往上扫描，算法参考VSCode的indentRangeProvider
+	previousRegions := []previousRegion{{indent: -1, endAbove: lineCount, line: lineCount}}
+	for line := lineCount - 1; line >= 0; line-- {
+		info := tb.foldingLine(line, tabSize, rules.Markers)
+		previous := &previousRegions[len(previousRegions)-1]
+
+		if info.indent == -1 {
+			if rules.OffSide {
+				// 越位规则语言中，空行属于前一个区域
+				previous.endAbove = line
+			}
+			continue
+		}
+
+		if info.marker == foldingMarkerStart {
+			// 丢弃区域结束标记之后的所有区域
+			i := len(previousRegions) - 1
+			for i > 0 && previousRegions[i].indent != -2 {
+				i--
+			}
+			if i > 0 {
+				previousRegions = previousRegions[:i+1]
+				previous = &previousRegions[i]
+
+				// 根据区域标记创建的折叠范围包括结束行
+				ranges = append(ranges, indentedRange{
+					FoldingRange: FoldingRange{StartLine: line, EndLine: previous.line, Kind: FoldingRangeKindRegion},
+					indent:       info.indent,
+				})
+				previous.line = line
+				previous.indent = info.indent
+				previous.endAbove = line
+				continue
+			}
+			// 没有找到结束标记，作为普通行处理
+		} else if info.marker == foldingMarkerEnd {
+			previousRegions = append(previousRegions, previousRegion{indent: -2, endAbove: line, line: line})
+			continue
+		}
+
+		if previous.indent > info.indent {
+			// 丢弃缩进更大的区域
+			for previous.indent > info.indent {
+				previousRegions = previousRegions[:len(previousRegions)-1]
+				previous = &previousRegions[len(previousRegions)-1]
+			}
+
+			// 新的折叠范围至少包含两行
+			if endLine := previous.endAbove - 1; endLine-line >= 1 {
+				ranges = append(ranges, indentedRange{
+					FoldingRange: FoldingRange{StartLine: line, EndLine: endLine, Kind: FoldingRangeKindIndent},
+					indent:       info.indent,
+				})
+			}
+		}
+
+		if previous.indent == info.indent {
+			previous.endAbove = line
+		} else {
+			// 缩进更大的新区域
+			previousRegions = append(previousRegions, previousRegion{indent: info.indent, endAbove: line, line: line})
+		}
+	}
+
+	// 超出最大数量时，优先保留缩进较小的范围
+	maxRanges := tb.foldingOptions.MaxRanges
+	if maxRanges <= 0 {
+		maxRanges = defaultMaxFoldingRanges
+	}
+	if len(ranges) > maxRanges {
+		sort.SliceStable(ranges, func(i, j int) bool {
+			return ranges[i].indent < ranges[j].indent
+		})
+		ranges = ranges[:maxRanges]
+	}
+
+	result := make([]FoldingRange, len(ranges))
+	for i, r := range ranges {
+		result[i] = r.FoldingRange
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].StartLine < result[j].StartLine
+	})
+
+	return result
+}
@@ -0,0 +1,93 @@
+package textbuffer
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestComputeFoldingRanges(t *testing.T) {
+	lines := []string{
+		"func a() {",    // 0
+		"\tif x {",      // 1
+		"\t\ty()",       // 2
+		"\t}",           // 3
+		"}",             // 4
+		"// #region",    // 5
+		"var b = 1",     // 6
+		"",              // 7
+		"var c = 2",     // 8
+		"// #endregion", // 9
+	}
+	buffer := NewTextBufferWithText(strings.Join(lines, "\n"))
+	buffer.SetLanguageConfiguration(&LanguageConfiguration{
+		Folding: &FoldingRules{
+			Markers: &FoldingMarkers{
+				Start: regexp.MustCompile(`^\s*//\s*#region\b`),
+				End:   regexp.MustCompile(`^\s*//\s*#endregion\b`),
+			},
+		},
+	})
+
+	expected := []FoldingRange{
+		{StartLine: 0, EndLine: 3, Kind: FoldingRangeKindIndent},
+		{StartLine: 1, EndLine: 2, Kind: FoldingRangeKindIndent},
+		{StartLine: 5, EndLine: 9, Kind: FoldingRangeKindRegion},
+	}
+	assertFoldingRanges(t, buffer.ComputeFoldingRanges(), expected)
+
+	// 编辑后只重新扫描受影响的行
+	_ = buffer.Insert(Position{Line: 7, Column: 0}, "func d() {\n\treturn\n}")
+	expected = []FoldingRange{
+		{StartLine: 0, EndLine: 3, Kind: FoldingRangeKindIndent},
+		{StartLine: 1, EndLine: 2, Kind: FoldingRangeKindIndent},
+		{StartLine: 5, EndLine: 11, Kind: FoldingRangeKindRegion},
+		{StartLine: 7, EndLine: 8, Kind: FoldingRangeKindIndent},
+	}
+	assertFoldingRanges(t, buffer.ComputeFoldingRanges(), expected)
+
+	// 超出最大数量时，优先丢弃嵌套较深的范围
+	buffer.SetFoldingOptions(FoldingOptions{TabSize: 4, MaxRanges: 3})
+	ranges := buffer.ComputeFoldingRanges()
+	if len(ranges) != 3 {
+		t.Fatalf("Expected 3 ranges, got %v", ranges)
+	}
+	for _, r := range ranges {
+		if r.StartLine == 1 {
+			t.Errorf("Expected nested range to be dropped, got %v", ranges)
+		}
+	}
+}
+
+func TestComputeFoldingRangesOffSide(t *testing.T) {
+	buffer := NewTextBufferWithText("def f():\n    return 1\n\ndef g():\n    pass")
+	buffer.SetLanguageConfiguration(&LanguageConfiguration{Folding: &FoldingRules{OffSide: true}})
+
+	expected := []FoldingRange{
+		{StartLine: 0, EndLine: 1, Kind: FoldingRangeKindIndent},
+		{StartLine: 3, EndLine: 4, Kind: FoldingRangeKindIndent},
+	}
+	assertFoldingRanges(t, buffer.ComputeFoldingRanges(), expected)
+}
+
+func TestComputeFoldingRangesCRLF(t *testing.T) {
+	// CRLF文件中的空行与LF文件中的空行一样不分割缩进折叠
+	text := "func a\n\tx\n\n\ty\nend\n"
+	expected := []FoldingRange{{StartLine: 0, EndLine: 3, Kind: FoldingRangeKindIndent}}
+	assertFoldingRanges(t, NewTextBufferWithText(text).ComputeFoldingRanges(), expected)
+	crlf := NewTextBufferWithText(strings.ReplaceAll(text, "\n", "\r\n"))
+	assertFoldingRanges(t, crlf.ComputeFoldingRanges(), expected)
+}
+
+// assertFoldingRanges 比较折叠范围
+func assertFoldingRanges(t *testing.T, ranges, expected []FoldingRange) {
+	t.Helper()
+	if len(ranges) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, ranges)
+	}
+	for i := range expected {
+		if ranges[i] != expected[i] {
+			t.Errorf("Expected %v, got %v", expected[i], ranges[i])
+		}
+	}
+}
@@ -102,7 +102,7 @@ func (gb *GapBuffer) GetLength() int {
 	return gb.size
 }
 
-// GetLineCount 获取行数
+// GetLineCount 获取行数，文本末尾的换行符之后不算作新的一行
 func (gb *GapBuffer) GetLineCount() int {
 	if !gb.lineCacheValid {
 		gb.updateLineCache()
@@ -174,6 +174,8 @@ func (gb *GapBuffer) GetLines() []string {
 }
 
 // GetPositionAt 获取指定偏移量对应的位置
+// 文本以换行符结尾时，文本末尾的位置是{GetLineCount(), 0}，即最后一个换行符之后的空行；
+// 按行访问的方法把这一行当作空行，行范围的命令把它截断到最后一行
 func (gb *GapBuffer) GetPositionAt(offset int) Position {
 	if !gb.lineCacheValid {
 		gb.updateLineCache()
@@ -186,6 +188,10 @@ func (gb *GapBuffer) GetPositionAt(offset int) Position {
 	if offset >= gb.size {
 		// 如果偏移量超出文本长度，返回最后一个位置
 		lastLine := len(gb.lineCache) - 1
+		if gb.lineCache[lastLine].hasNewline {
+			// 文本以换行符结尾时，末尾位置位于最后一个换行符之后
+			return Position{Line: lastLine + 1, Column: 0}
+		}
 		lastColumn := gb.lineCache[lastLine].length
 		return Position{Line: lastLine, Column: lastColumn}
 	}
@@ -254,46 +260,39 @@ func (gb *GapBuffer) GetOffsetAt(position Position) int {
 
 // GetTextInRange 获取指定范围内的文本
 func (gb *GapBuffer) GetTextInRange(r Range) string {
-	startOffset := gb.GetOffsetAt(r.Start)
-	endOffset := gb.GetOffsetAt(r.End)
+	return gb.getTextInOffsets(gb.GetOffsetAt(r.Start), gb.GetOffsetAt(r.End))
+}
 
-	if startOffset >= endOffset {
-		return ""
+// getTextInOffsets 获取偏移量区间[startOffset, endOffset)内的文本
+func (gb *GapBuffer) getTextInOffsets(startOffset, endOffset int) string {
+	if startOffset < 0 {
+		startOffset = 0
 	}
-
-	// 将偏移量转换为实际缓冲区索引
-	realStartOffset := startOffset
-	if startOffset > gb.gapStart {
-		realStartOffset += (gb.gapEnd - gb.gapStart)
+	if endOffset > gb.size {
+		endOffset = gb.size
 	}
-
-	realEndOffset := endOffset
-	if endOffset > gb.gapStart {
-		realEndOffset += (gb.gapEnd - gb.gapStart)
+	if startOffset >= endOffset {
+		return ""
 	}
 
 	var builder strings.Builder
 	builder.Grow(endOffset - startOffset)
 
-	// 如果范围不跨越间隙
-	if (startOffset < gb.gapStart && endOffset <= gb.gapStart) ||
-		(startOffset >= gb.gapStart && endOffset > gb.gapStart) {
-		for i := realStartOffset; i < realEndOffset; i++ {
-			builder.WriteRune(gb.buffer[i])
-		}
-	} else {
-		// 范围跨越了间隙
-		for i := realStartOffset; i < gb.gapStart; i++ {
-			builder.WriteRune(gb.buffer[i])
-		}
-		for i := gb.gapEnd; i < realEndOffset; i++ {
-			builder.WriteRune(gb.buffer[i])
-		}
+	for i := startOffset; i < endOffset; i++ {
+		builder.WriteRune(gb.buffer[gb.realIndex(i)])
 	}
 
 	return builder.String()
 }
 
+// realIndex 将文本偏移量转换为缓冲区中的实际索引（跳过间隙）
+func (gb *GapBuffer) realIndex(offset int) int {
+	if offset < gb.gapStart {
+		return offset
+	}
+	return offset + (gb.gapEnd - gb.gapStart)
+}
+
 // moveGap 将间隙移动到指定位置
 func (gb *GapBuffer) moveGap(offset int) {
 	if offset == gb.gapStart {
@@ -463,60 +462,55 @@ func (gb *GapBuffer) updateLineCache() {
 
 	// 计算行信息
 	lineStart := 0
-	lineLength := 0
-	inGap := false
+	offset := 0
 
 	for i := 0; i < len(gb.buffer); i++ {
 		// 跳过间隙
-		if i == gb.gapStart {
-			inGap = true
+		if i == gb.gapStart && gb.gapStart < gb.gapEnd {
 			i = gb.gapEnd - 1 // -1 因为循环会 i++
 			continue
 		}
 
-		if inGap && i < gb.gapEnd {
-			continue
-		}
-
-		// 计算实际偏移量（考虑间隙）
-		realOffset := i
-		if inGap {
-			realOffset -= (gb.gapEnd - gb.gapStart)
-		}
-
 		// 处理换行符
 		if gb.buffer[i] == '\n' {
 			// 添加当前行信息
-			adjustedStart := lineStart
-			if lineStart > gb.gapStart {
-				adjustedStart -= (gb.gapEnd - gb.gapStart)
-			}
 			gb.lineCache = append(gb.lineCache, lineInfo{
-				start:      adjustedStart,
-				length:     lineLength,
+				start:      lineStart,
+				length:     offset - lineStart,
 				hasNewline: true,
 			})
 
 			// 开始新行
-			lineStart = realOffset + 1
-			lineLength = 0
-		} else {
-			lineLength++
+			lineStart = offset + 1
 		}
+		offset++
 	}
 
 	// 添加最后一行（如果没有以换行符结束）
-	if lineLength > 0 || len(gb.lineCache) == 0 {
-		adjustedStart := lineStart
-		if lineStart > gb.gapStart {
-			adjustedStart -= (gb.gapEnd - gb.gapStart)
-		}
+	if lineStart < gb.size || len(gb.lineCache) == 0 {
 		gb.lineCache = append(gb.lineCache, lineInfo{
-			start:      adjustedStart,
-			length:     lineLength,
+			start:      lineStart,
+			length:     gb.size - lineStart,
 			hasNewline: false,
 		})
 	}
 
 	gb.lineCacheValid = true
 }
+
+// runeAt 获取指定偏移量处的字符
+func (gb *GapBuffer) runeAt(offset int) rune {
+	return gb.buffer[gb.realIndex(offset)]
+}
+
+// lineStartAndLength 获取指定行的起始偏移量和长度（不包括换行符）
+func (gb *GapBuffer) lineStartAndLength(lineIndex int) (int, int) {
+	if !gb.lineCacheValid {
+		gb.updateLineCache()
+	}
+	if lineIndex < 0 || lineIndex >= len(gb.lineCache) {
+		return gb.size, 0
+	}
+	line := gb.lineCache[lineIndex]
+	return line.start, line.length
+}
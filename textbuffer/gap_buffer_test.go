@@ -1,6 +1,7 @@
 package textbuffer
 
 import (
+	"math/rand"
 	"strings"
 	"testing"
 )
@@ -167,3 +168,62 @@ func TestGapBufferWithLargeText(t *testing.T) {
 	largeText := strings.Repeat("Large Text ", 1000)
 	buffer.Insert(buffer.GetLength()/2, largeText)
 }
+
+func TestGapBufferRandomEdits(t *testing.T) {
+	// 使用随机的插入和删除操作与简单的rune切片模型进行对比
+	random := rand.New(rand.NewSource(1))
+	alphabet := []rune("ab\nc日")
+
+	for iteration := 0; iteration < 200; iteration++ {
+		buffer := NewGapBuffer()
+		model := []rune{}
+
+		for step := 0; step < 30; step++ {
+			if random.Intn(3) > 0 || len(model) == 0 {
+				offset := random.Intn(len(model) + 1)
+				inserted := make([]rune, random.Intn(140)+1)
+				for i := range inserted {
+					inserted[i] = alphabet[random.Intn(len(alphabet))]
+				}
+				buffer.Insert(offset, string(inserted))
+				model = append(model[:offset:offset], append(inserted, model[offset:]...)...)
+			} else {
+				start := random.Intn(len(model))
+				end := start + random.Intn(len(model)-start) + 1
+				buffer.Delete(start, end)
+				model = append(model[:start:start], model[end:]...)
+			}
+
+			text := string(model)
+			if buffer.GetText() != text {
+				t.Fatalf("Expected '%s', got '%s'", text, buffer.GetText())
+			}
+
+			// 测试行内容
+			expectedLines := strings.SplitAfter(text, "\n")
+			if len(expectedLines) > 1 && expectedLines[len(expectedLines)-1] == "" {
+				expectedLines = expectedLines[:len(expectedLines)-1]
+			}
+			lines := buffer.GetLines()
+			if strings.Join(lines, "|") != strings.Join(expectedLines, "|") {
+				t.Fatalf("Expected lines %q, got %q", expectedLines, lines)
+			}
+
+			// 测试偏移量和位置的相互转换
+			for offset := 0; offset <= len(model); offset++ {
+				position := buffer.GetPositionAt(offset)
+				if buffer.GetOffsetAt(position) != offset {
+					t.Fatalf("Offset %d converted to %v, which maps back to %d", offset, position, buffer.GetOffsetAt(position))
+				}
+			}
+
+			// 测试获取范围内的文本
+			start := random.Intn(len(model) + 1)
+			end := start + random.Intn(len(model)-start+1)
+			r := Range{Start: buffer.GetPositionAt(start), End: buffer.GetPositionAt(end)}
+			if got := buffer.GetTextInRange(r); got != string(model[start:end]) {
+				t.Fatalf("Expected '%s' in range [%d, %d), got '%s'", string(model[start:end]), start, end, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,172 @@
+package textbuffer
+
+import (
+	"time"
+	"unicode/utf8"
+)
+
+// EditSource 表示编辑的来源
+type EditSource int
+
+const (
+	// EditSourceAPI 表示通过API发起的编辑，这是默认的来源
+	EditSourceAPI EditSource = iota
+	// EditSourceUser 表示用户直接发起的编辑
+	EditSourceUser
+	// EditSourceFormatter 表示格式化工具发起的编辑
+	EditSourceFormatter
+	// EditSourceAI 表示AI助手发起的编辑
+	EditSourceAI
+)
+
+// String 获取编辑来源的名称
+func (s EditSource) String() string {
+	switch s {
+	case EditSourceAPI:
+		return "api"
+	case EditSourceUser:
+		return "user"
+	case EditSourceFormatter:
+		return "formatter"
+	case EditSourceAI:
+		return "ai"
+	}
+	return "unknown"
+}
+
+// EditOptions 描述一次编辑的附加信息
+type EditOptions struct {
+	// Label 操作的描述，为空时根据操作类型生成
+	Label string
+	// Source 操作的来源
+	Source EditSource
+	// BeforeSelections 操作前的选区，撤销时恢复
+	BeforeSelections []Selection
+	// AfterSelections 操作后的选区，重做时恢复
+	AfterSelections []Selection
+	// SkipUndo 编辑不推入撤销栈，例如协同编辑中其他用户的编辑
+	// 撤销栈和重做栈中的操作被变换到编辑之后的文本上，撤销时只恢复本地的修改
+	SkipUndo bool
+}
+
+// HistoryEntry 表示编辑历史中的一项
+type HistoryEntry struct {
+	// ID 操作的唯一标识
+	ID uint64
+	// Label 操作的描述
+	Label string
+	// Timestamp 操作发生的时间
+	Timestamp time.Time
+	// Size 操作涉及的字符数（删除和插入的字符之和）
+	Size int
+	// Source 操作的来源
+	Source EditSource
+	// Undone 操作是否已被撤销（位于重做栈中）
+	Undone bool
+}
+
+// UndoState 表示撤销/重做的当前状态
+type UndoState struct {
+	// CanUndo 是否可以撤销
+	CanUndo bool
+	// CanRedo 是否可以重做
+	CanRedo bool
+	// UndoLabel 下一个将被撤销的操作的描述
+	UndoLabel string
+	// RedoLabel 下一个将被重做的操作的描述
+	RedoLabel string
+}
+
+// defaultLabel 根据操作类型生成默认的描述
+func defaultLabel(operationType OperationType) string {
+	switch operationType {
+	case OperationInsert:
+		return "Insert"
+	case OperationDelete:
+		return "Delete"
+	}
+	return "Replace"
+}
+
+// newHistoryEntry 根据操作创建历史项
+func newHistoryEntry(operation *TextOperation, undone bool) HistoryEntry {
+	size := 0
+	for _, edit := range operation.Edits {
+		size += utf8.RuneCountInString(edit.OldText) + utf8.RuneCountInString(edit.NewText)
+	}
+
+	return HistoryEntry{
+		ID:        operation.ID,
+		Label:     operation.Label,
+		Timestamp: operation.Timestamp,
+		Size:      size,
+		Source:    operation.Source,
+		Undone:    undone,
+	}
+}
+
+// CanUndo 判断是否可以撤销
+func (tb *TextBuffer) CanUndo() bool {
+	tb.mutex.RLock()
+	defer tb.mutex.RUnlock()
+	return tb.undoStack.CanUndo()
+}
+
+// CanRedo 判断是否可以重做
+func (tb *TextBuffer) CanRedo() bool {
+	tb.mutex.RLock()
+	defer tb.mutex.RUnlock()
+	return tb.undoStack.CanRedo()
+}
+
+// UndoLabel 获取下一个将被撤销的操作的描述，没有可撤销的操作时返回空字符串
+func (tb *TextBuffer) UndoLabel() string {
+	tb.mutex.RLock()
+	defer tb.mutex.RUnlock()
+	if operation := tb.undoStack.PeekUndo(); operation != nil {
+		return operation.Label
+	}
+	return ""
+}
+
+// RedoLabel 获取下一个将被重做的操作的描述，没有可重做的操作时返回空字符串
+func (tb *TextBuffer) RedoLabel() string {
+	tb.mutex.RLock()
+	defer tb.mutex.RUnlock()
+	if operation := tb.undoStack.PeekRedo(); operation != nil {
+		return operation.Label
+	}
+	return ""
+}
+
+// History 获取编辑历史
+// 先按从旧到新的顺序列出可撤销的操作，再按重做顺序列出已撤销的操作
+func (tb *TextBuffer) History() []HistoryEntry {
+	tb.mutex.RLock()
+	defer tb.mutex.RUnlock()
+
+	var entries []HistoryEntry
+	for _, operation := range tb.undoStack.UndoOperations() {
+		entries = append(entries, newHistoryEntry(operation, false))
+	}
+	for _, operation := range tb.undoStack.RedoOperations() {
+		entries = append(entries, newHistoryEntry(operation, true))
+	}
+	return entries
+}
+
+// undoState 获取撤销/重做的当前状态
+// 调用方必须持有锁
+func (tb *TextBuffer) undoState() UndoState {
+	state := UndoState{
+		CanUndo: tb.undoStack.CanUndo(),
+		CanRedo: tb.undoStack.CanRedo(),
+	}
+	if operation := tb.undoStack.PeekUndo(); operation != nil {
+		state.UndoLabel = operation.Label
+	}
+	if operation := tb.undoStack.PeekRedo(); operation != nil {
+		state.RedoLabel = operation.Label
+	}
+	return state
+}
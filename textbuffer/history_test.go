@@ -0,0 +1,201 @@
+package textbuffer
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTextBufferHistory(t *testing.T) {
+	buffer := NewTextBuffer()
+
+	// 测试初始状态
+	if buffer.CanUndo() || buffer.CanRedo() {
+		t.Errorf("Expected no undo or redo for a new buffer")
+	}
+	if buffer.UndoLabel() != "" || buffer.RedoLabel() != "" {
+		t.Errorf("Expected empty labels, got '%s' and '%s'", buffer.UndoLabel(), buffer.RedoLabel())
+	}
+
+	var states []UndoState
+	remove := buffer.OnUndoStateChanged(func(state UndoState) {
+		// 监听器在释放锁之后调用，可以安全地读取缓冲区
+		if buffer.CanUndo() != state.CanUndo {
+			t.Errorf("Expected CanUndo %v in listener", state.CanUndo)
+		}
+		states = append(states, state)
+	})
+
+	_ = buffer.Insert(Position{Line: 0, Column: 0}, "Hello")
+	err := buffer.ApplyEditsWithOptions([]EditOperation{
+		{Range: NewRange(Position{Line: 0, Column: 0}, Position{Line: 0, Column: 5}), Text: "hello"},
+	}, EditOptions{Label: "Format Document", Source: EditSourceFormatter})
+	if err != nil {
+		t.Fatalf("ApplyEditsWithOptions failed: %v", err)
+	}
+
+	if buffer.UndoLabel() != "Format Document" {
+		t.Errorf("Expected undo label 'Format Document', got '%s'", buffer.UndoLabel())
+	}
+
+	if _, err := buffer.Undo(); err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+	if buffer.UndoLabel() != "Insert" || buffer.RedoLabel() != "Format Document" {
+		t.Errorf("Expected labels 'Insert' and 'Format Document', got '%s' and '%s'", buffer.UndoLabel(), buffer.RedoLabel())
+	}
+
+	// 测试历史记录
+	history := buffer.History()
+	if len(history) != 2 {
+		t.Fatalf("Expected 2 history entries, got %d", len(history))
+	}
+	if history[0].Label != "Insert" || history[0].Source != EditSourceAPI || history[0].Size != 5 || history[0].Undone {
+		t.Errorf("Unexpected first history entry %+v", history[0])
+	}
+	if history[1].Label != "Format Document" || history[1].Source != EditSourceFormatter || history[1].Size != 10 || !history[1].Undone {
+		t.Errorf("Unexpected second history entry %+v", history[1])
+	}
+	if history[0].ID == history[1].ID || history[0].Timestamp.IsZero() {
+		t.Errorf("Expected distinct IDs and timestamps, got %+v", history)
+	}
+
+	// 测试状态变化事件
+	if len(states) != 3 {
+		t.Fatalf("Expected 3 undo state events, got %d", len(states))
+	}
+	last := states[2]
+	if !last.CanUndo || !last.CanRedo || last.UndoLabel != "Insert" || last.RedoLabel != "Format Document" {
+		t.Errorf("Unexpected undo state %+v", last)
+	}
+
+	// 测试移除监听器
+	remove()
+	if _, err := buffer.Redo(); err != nil {
+		t.Fatalf("Redo failed: %v", err)
+	}
+	if len(states) != 3 {
+		t.Errorf("Expected no events after removing listener, got %d", len(states))
+	}
+}
+
+func TestOnContentChanged(t *testing.T) {
+	buffer := NewTextBufferWithText("abc")
+	var events []ContentChangeEvent
+	remove := buffer.OnContentChanged(func(event ContentChangeEvent) {
+		events = append(events, event)
+	})
+
+	buffer.ApplyEdits([]EditOperation{
+		{Range: NewRange(Position{Line: 0, Column: 0}, Position{Line: 0, Column: 1}), Text: "A"},
+		{Range: NewRange(Position{Line: 0, Column: 3}, Position{Line: 0, Column: 3}), Text: "d"},
+	}, nil, nil)
+	buffer.Undo()
+
+	if len(events) != 2 {
+		t.Fatalf("Expected 2 events, got %+v", events)
+	}
+	if len(events[0].Edits) != 2 || events[0].VersionID != 2 || events[1].VersionID != 4 {
+		t.Errorf("Unexpected events %+v", events)
+	}
+	if len(events[0].LineChanges) != 2 || events[0].LineChanges[1] != (LineChange{StartLine: 0}) || events[0].LineCount != 1 {
+		t.Errorf("Unexpected line changes %+v", events[0])
+	}
+
+	// 按顺序重放事件中的编辑得到相同的文本
+	text := []rune("abc")
+	for _, event := range events[:1] {
+		for _, edit := range event.Edits {
+			text = append(text[:edit.Offset:edit.Offset], append([]rune(edit.NewText), text[edit.OldEnd():]...)...)
+		}
+	}
+	if string(text) != "Abcd" {
+		t.Errorf("Expected 'Abcd', got '%s'", string(text))
+	}
+
+	remove()
+	buffer.Redo()
+	if len(events) != 2 {
+		t.Errorf("Expected no events after removing the listener")
+	}
+}
+
+func TestExportImportUndoHistory(t *testing.T) {
+	buffer := NewTextBufferWithText("abc")
+	buffer.Insert(Position{Line: 0, Column: 3}, "d")
+	buffer.Insert(Position{Line: 0, Column: 4}, "e")
+	buffer.Insert(Position{Line: 0, Column: 5}, "f")
+	buffer.Undo()
+
+	history := buffer.ExportUndoHistory()
+	if len(history.Undo) != 2 || len(history.Redo) != 1 {
+		t.Fatalf("Unexpected history %+v", history)
+	}
+
+	restored := NewTextBufferWithText("abcde")
+	if err := restored.ImportUndoHistory(history); err != nil {
+		t.Fatalf("ImportUndoHistory failed: %v", err)
+	}
+	if restored.UndoLabel() != "Insert" || !restored.CanRedo() {
+		t.Errorf("Expected the history to be restored")
+	}
+	restored.Redo()
+	if restored.GetText() != "abcdef" {
+		t.Errorf("Expected 'abcdef', got '%s'", restored.GetText())
+	}
+	restored.Undo()
+	restored.Undo()
+	restored.Undo()
+	if restored.GetText() != "abc" || restored.IsDirty() {
+		t.Errorf("Expected the original clean text, got '%s'", restored.GetText())
+	}
+
+	// 新的操作不会与导入的操作使用相同的标识
+	restored.Insert(Position{Line: 0, Column: 0}, "x")
+	entries := restored.History()
+	if entries[len(entries)-1].ID <= history.Undo[1].ID {
+		t.Errorf("Expected a new operation ID, got %+v", entries)
+	}
+
+	if err := NewTextBufferWithText("other").ImportUndoHistory(history); !errors.Is(err, ErrUndoHistoryMismatch) {
+		t.Errorf("Expected ErrUndoHistoryMismatch, got %v", err)
+	}
+}
+
+func TestSkipUndoRebasesHistory(t *testing.T) {
+	buffer := NewTextBufferWithText("abc")
+	remote := func(offset int, length int, text string) {
+		t.Helper()
+		r := NewRange(buffer.GetPositionAt(offset), buffer.GetPositionAt(offset+length))
+		if err := buffer.ApplyEditsWithOptions([]EditOperation{{Range: r, Text: text}}, EditOptions{SkipUndo: true}); err != nil {
+			t.Fatalf("ApplyEditsWithOptions failed: %v", err)
+		}
+	}
+
+	buffer.Insert(Position{Line: 0, Column: 3}, "X")
+	remote(0, 0, "R")
+	if !buffer.IsDirty() || len(buffer.History()) != 1 {
+		t.Errorf("Expected only the local edit in the history")
+	}
+
+	// 撤销只恢复本地的编辑，远程的编辑之前和之后的位置都被调整
+	buffer.Undo()
+	if buffer.GetText() != "Rabc" {
+		t.Errorf("Expected 'Rabc', got '%s'", buffer.GetText())
+	}
+	remote(4, 0, "Z")
+	buffer.Redo()
+	if buffer.GetText() != "RabcZX" {
+		t.Errorf("Expected 'RabcZX', got '%s'", buffer.GetText())
+	}
+	buffer.Undo()
+	if buffer.GetText() != "RabcZ" {
+		t.Errorf("Expected 'RabcZ', got '%s'", buffer.GetText())
+	}
+	buffer.Redo()
+
+	// 远程编辑修改了本地插入的文本，本地操作无法再撤销
+	remote(5, 1, "Y")
+	if buffer.CanUndo() || buffer.GetText() != "RabcZY" {
+		t.Errorf("Expected the overwritten operation to be dropped, got '%s'", buffer.GetText())
+	}
+}
@@ -0,0 +1,193 @@
+package textbuffer
+
+import (
+	"errors"
+	"strings"
+)
+
+// IndentOptions 描述缩进的方式
+type IndentOptions struct {
+	// TabSize 制表符的宽度，也是一级缩进的宽度
+	TabSize int
+	// InsertSpaces 是否使用空格缩进
+	InsertSpaces bool
+}
+
+// tabSize 获取有效的制表符宽度
+func (o IndentOptions) tabSize() int {
+	if o.TabSize <= 0 {
+		return 4
+	}
+	return o.TabSize
+}
+
+// generateIndent 生成指定可见宽度的缩进
+func (o IndentOptions) generateIndent(visibleColumn int) string {
+	if o.InsertSpaces {
+		return strings.Repeat(" ", visibleColumn)
+	}
+	tabSize := o.tabSize()
+	return strings.Repeat("\t", visibleColumn/tabSize) + strings.Repeat(" ", visibleColumn%tabSize)
+}
+
+// visibleColumn 计算空白字符的可见宽度，制表符会对齐到下一个制表位
+func visibleColumn(whitespace string, tabSize int) int {
+	column := 0
+	for _, ch := range whitespace {
+		if ch == '\t' {
+			column = (column/tabSize + 1) * tabSize
+		} else {
+			column++
+		}
+	}
+	return column
+}
+
+// leadingWhitespace 获取字符串开头的空白字符（空格和制表符）
+func leadingWhitespace(text string) string {
+	for i, ch := range text {
+		if ch != ' ' && ch != '\t' {
+			return text[:i]
+		}
+	}
+	return text
+}
+
+// lineText 获取指定行不包括行尾换行符（"\n"或"\r\n"）的内容
+// 调用方必须持有锁
+func (tb *TextBuffer) lineText(lineIndex int) string {
+	content := tb.gapBuffer.GetLineContent(lineIndex)
+	return content[:len(content)-len(lineEnding(content))]
+}
+
+// clampLineRange 检查并截断行范围
+// 文本末尾位置所在的行（文本以换行符结尾时为GetLineCount()）被截断到最后一行
+// 调用方必须持有锁
+func (tb *TextBuffer) clampLineRange(startLine, endLine int) (int, int, error) {
+	if startLine > endLine {
+		return 0, 0, errors.New("invalid line range")
+	}
+	if startLine < 0 {
+		startLine = 0
+	}
+	lastLine := tb.gapBuffer.GetLineCount() - 1
+	if startLine == lastLine+1 {
+		startLine = lastLine
+	}
+	if endLine > lastLine {
+		endLine = lastLine
+	}
+	if startLine > endLine {
+		return 0, 0, errors.New("invalid line range")
+	}
+	return startLine, endLine, nil
+}
+
+// replaceIndentation 对一个行范围内每一行的缩进进行替换，作为一个操作应用
+// compute返回新的缩进和是否需要修改
+func (tb *TextBuffer) replaceIndentation(startLine, endLine int, label string, compute func(lineIndex int, indent string, text string) (string, bool)) error {
+	tb.mutex.Lock()
+	defer tb.unlock()
+
+	startLine, endLine, err := tb.clampLineRange(startLine, endLine)
+	if err != nil {
+		return err
+	}
+
+	// 从后往前生成编辑，这样每个编辑的偏移量都不受之前编辑的影响
+	var edits []Edit
+	for lineIndex := endLine; lineIndex >= startLine; lineIndex-- {
+		text := tb.lineText(lineIndex)
+		indent := leadingWhitespace(text)
+		newIndent, ok := compute(lineIndex, indent, text)
+		if !ok || newIndent == indent {
+			continue
+		}
+		start, _ := tb.gapBuffer.lineStartAndLength(lineIndex)
+		edits = append(edits, Edit{Offset: start, OldText: indent, NewText: newIndent})
+	}
+
+	tb.pushOperation(&TextOperation{
+		Type:  operationTypeOf(edits),
+		Label: label,
+		Edits: edits,
+	})
+
+	return nil
+}
+
+// IndentLines 将一个行范围内的每一行增加一级缩进
+// 缩进会对齐到下一个制表位；范围包含多行时，空白行保持不变
+func (tb *TextBuffer) IndentLines(startLine, endLine int, options IndentOptions) error {
+	tabSize := options.tabSize()
+	return tb.replaceIndentation(startLine, endLine, "Indent Lines", func(lineIndex int, indent string, text string) (string, bool) {
+		if startLine != endLine && indent == text {
+			return "", false
+		}
+		column := visibleColumn(indent, tabSize)
+		return options.generateIndent((column/tabSize + 1) * tabSize), true
+	})
+}
+
+// OutdentLines 将一个行范围内的每一行减少一级缩进，缩进会对齐到上一个制表位
+func (tb *TextBuffer) OutdentLines(startLine, endLine int, options IndentOptions) error {
+	tabSize := options.tabSize()
+	return tb.replaceIndentation(startLine, endLine, "Outdent Lines", func(lineIndex int, indent string, text string) (string, bool) {
+		column := visibleColumn(indent, tabSize)
+		if column == 0 {
+			return "", false
+		}
+		return options.generateIndent(((column+tabSize-1)/tabSize - 1) * tabSize), true
+	})
+}
+
+// ConvertIndentationToSpaces 将一个行范围内行首的制表符转换为空格
+func (tb *TextBuffer) ConvertIndentationToSpaces(startLine, endLine, tabSize int) error {
+	options := IndentOptions{TabSize: tabSize, InsertSpaces: true}
+	return tb.replaceIndentation(startLine, endLine, "Convert Indentation to Spaces", func(lineIndex int, indent string, text string) (string, bool) {
+		return options.generateIndent(visibleColumn(indent, options.tabSize())), true
+	})
+}
+
+// ConvertIndentationToTabs 将一个行范围内行首的空格转换为制表符，不足一个制表位的空格保留
+func (tb *TextBuffer) ConvertIndentationToTabs(startLine, endLine, tabSize int) error {
+	options := IndentOptions{TabSize: tabSize, InsertSpaces: false}
+	return tb.replaceIndentation(startLine, endLine, "Convert Indentation to Tabs", func(lineIndex int, indent string, text string) (string, bool) {
+		return options.generateIndent(visibleColumn(indent, options.tabSize())), true
+	})
+}
+
+// ReindentLine 重新缩进指定行，使其与上一个非空白行的缩进一致
+// 语言配置有缩进规则时，上一个非空白行匹配增加缩进的规则时增加一级缩进，
+// 当前行匹配减少缩进的规则时减少一级缩进
+func (tb *TextBuffer) ReindentLine(lineIndex int, options IndentOptions) error {
+	return tb.replaceIndentation(lineIndex, lineIndex, "Reindent Line", func(lineIndex int, indent string, text string) (string, bool) {
+		return tb.computeReindent(lineIndex, text, options), true
+	})
+}
+
+// computeReindent 计算指定行重新缩进后的缩进，text是这一行的内容
+// 调用方必须持有锁
+func (tb *TextBuffer) computeReindent(lineIndex int, text string, options IndentOptions) string {
+	tabSize := options.tabSize()
+	rules := tb.getLanguageConfiguration().IndentationRules
+
+	column := 0
+	for previous := lineIndex - 1; previous >= 0; previous-- {
+		previousText := tb.lineText(previous)
+		indent := leadingWhitespace(previousText)
+		if indent == previousText {
+			continue
+		}
+		column = visibleColumn(indent, tabSize)
+		if rules != nil && rules.IncreaseIndentPattern != nil && rules.IncreaseIndentPattern.MatchString(previousText) {
+			column = (column/tabSize + 1) * tabSize
+		}
+		break
+	}
+
+	if rules != nil && rules.DecreaseIndentPattern != nil && rules.DecreaseIndentPattern.MatchString(text) {
+		column = max(((column+tabSize-1)/tabSize-1)*tabSize, 0)
+	}
+	return options.generateIndent(column)
+}
@@ -0,0 +1,105 @@
+package textbuffer
+
+import (
+	"testing"
+)
+
+func TestIndentAndOutdentLines(t *testing.T) {
+	buffer := NewTextBufferWithText("if x {\nfoo()\n\n  bar()\n}")
+	buffer.SetSelections([]Selection{NewSelection(Position{Line: 1, Column: 0}, Position{Line: 3, Column: 7})})
+	options := IndentOptions{TabSize: 4, InsertSpaces: true}
+
+	// 测试增加缩进，空白行保持不变，已有的缩进对齐到下一个制表位
+	if err := buffer.IndentLines(1, 3, options); err != nil {
+		t.Fatalf("IndentLines failed: %v", err)
+	}
+	expected := "if x {\n    foo()\n\n    bar()\n}"
+	if buffer.GetText() != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, buffer.GetText())
+	}
+
+	// 跟踪的选区随编辑调整
+	selections := buffer.GetSelections()
+	if len(selections) != 1 || !selections[0].Active.Equals(Position{Line: 3, Column: 9}) {
+		t.Errorf("Expected active position (3, 9), got %v", selections)
+	}
+
+	// 测试减少缩进
+	if err := buffer.OutdentLines(0, 4, options); err != nil {
+		t.Fatalf("OutdentLines failed: %v", err)
+	}
+	expected = "if x {\nfoo()\n\nbar()\n}"
+	if buffer.GetText() != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, buffer.GetText())
+	}
+
+	// 每个命令都是一个撤销步骤
+	if _, err := buffer.Undo(); err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+	if buffer.GetText() != "if x {\n    foo()\n\n    bar()\n}" {
+		t.Errorf("Unexpected text after undo '%s'", buffer.GetText())
+	}
+	if buffer.UndoLabel() != "Indent Lines" {
+		t.Errorf("Expected undo label 'Indent Lines', got '%s'", buffer.UndoLabel())
+	}
+
+	// 测试无效的行范围
+	if err := buffer.IndentLines(3, 1, options); err == nil {
+		t.Errorf("Expected error for invalid line range")
+	}
+}
+
+func TestConvertIndentation(t *testing.T) {
+	buffer := NewTextBufferWithText("\tone\n\t\t two\n      three")
+
+	if err := buffer.ConvertIndentationToSpaces(0, 2, 4); err != nil {
+		t.Fatalf("ConvertIndentationToSpaces failed: %v", err)
+	}
+	expected := "    one\n         two\n      three"
+	if buffer.GetText() != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, buffer.GetText())
+	}
+
+	if err := buffer.ConvertIndentationToTabs(0, 2, 4); err != nil {
+		t.Fatalf("ConvertIndentationToTabs failed: %v", err)
+	}
+	expected = "\tone\n\t\t two\n\t  three"
+	if buffer.GetText() != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, buffer.GetText())
+	}
+}
+
+func TestReindentLine(t *testing.T) {
+	buffer := NewTextBufferWithText("func f() {\n\treturn\n\n        x\n}")
+
+	if err := buffer.ReindentLine(3, IndentOptions{TabSize: 4, InsertSpaces: false}); err != nil {
+		t.Fatalf("ReindentLine failed: %v", err)
+	}
+	expected := "func f() {\n\treturn\n\n\tx\n}"
+	if buffer.GetText() != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, buffer.GetText())
+	}
+}
+
+func TestIndentCommandsCRLF(t *testing.T) {
+	options := IndentOptions{TabSize: 4, InsertSpaces: true}
+
+	// 只有空白的行不增加缩进
+	buffer := NewTextBufferWithText("a\r\n  \r\nb\r\n")
+	if err := buffer.IndentLines(0, 2, options); err != nil {
+		t.Fatalf("IndentLines failed: %v", err)
+	}
+	if expected := "    a\r\n  \r\n    b\r\n"; buffer.GetText() != expected {
+		t.Errorf("Expected %q, got %q", expected, buffer.GetText())
+	}
+
+	// 重新缩进时跳过空行，使用之前的非空行的缩进
+	buffer = NewTextBufferWithText("\tfoo\r\n\r\nbar\r\n")
+	if err := buffer.ReindentLine(2, IndentOptions{TabSize: 4}); err != nil {
+		t.Fatalf("ReindentLine failed: %v", err)
+	}
+	if expected := "\tfoo\r\n\r\n\tbar\r\n"; buffer.GetText() != expected {
+		t.Errorf("Expected %q, got %q", expected, buffer.GetText())
+	}
+}
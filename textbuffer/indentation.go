@@ -0,0 +1,228 @@
+package textbuffer
+
+// 参与猜测的最大行数
+const maxIndentationGuessLines = 10000
+
+// 允许猜测的缩进宽度，按优先级排列
+var allowedTabSizeGuesses = []int{2, 4, 6, 8, 3, 5, 7}
+
+// 允许猜测的最大缩进宽度
+const maxAllowedTabSizeGuess = 8
+
+// IndentationGuess 表示对文本缩进方式的猜测结果
+type IndentationGuess struct {
+	// TabSize 缩进宽度
+	TabSize int
+	// InsertSpaces 是否使用空格缩进
+	InsertSpaces bool
+	// Confidence 猜测的置信度，取值范围为[0, 1]，为0表示没有足够的依据，使用了默认值
+	Confidence float64
+	// LinesIndentedWithTabs 使用制表符缩进的行数
+	LinesIndentedWithTabs int
+	// LinesIndentedWithSpaces 使用空格缩进的行数
+	LinesIndentedWithSpaces int
+}
+
+// lineView 直接在GapBuffer上访问一行的字符，避免复制行内容
+type lineView struct {
+	gapBuffer *GapBuffer
+	start     int
+	length    int
+}
+
+// at 获取行中指定索引处的字符
+func (lv lineView) at(index int) rune {
+	return lv.gapBuffer.runeAt(lv.start + index)
+}
+
+// spacesDiffResult 表示相邻两行缩进的差异
+type spacesDiffResult struct {
+	// 缩进差异的空格数
+	spacesDiff int
+	// 差异是否看起来是为了对齐
+	looksLikeAlignment bool
+}
+
+// spacesDiff 计算两行缩进之间的空格差异
+// a和b分别是前一行和当前行，aLength和bLength是它们的缩进长度
+func spacesDiff(a lineView, aLength int, b lineView, bLength int) spacesDiffResult {
+	result := spacesDiffResult{}
+
+	// 跳过相同的缩进前缀
+	i := 0
+	for i < aLength && i < bLength && a.at(i) == b.at(i) {
+		i++
+	}
+
+	aSpacesCount, aTabsCount := 0, 0
+	for j := i; j < aLength; j++ {
+		if a.at(j) == ' ' {
+			aSpacesCount++
+		} else {
+			aTabsCount++
+		}
+	}
+
+	bSpacesCount, bTabsCount := 0, 0
+	for j := i; j < bLength; j++ {
+		if b.at(j) == ' ' {
+			bSpacesCount++
+		} else {
+			bTabsCount++
+		}
+	}
+
+	// 混合使用制表符和空格的差异没有参考价值
+	if aSpacesCount > 0 && aTabsCount > 0 {
+		return result
+	}
+	if bSpacesCount > 0 && bTabsCount > 0 {
+		return result
+	}
+
+	tabsDiff := abs(aTabsCount - bTabsCount)
+	spacesDiffCount := abs(aSpacesCount - bSpacesCount)
+
+	if tabsDiff == 0 {
+		result.spacesDiff = spacesDiffCount
+
+		// 检查缩进差异是否是为了对齐，例如：
+		// const a = b + c,
+		//       d = 4;
+		if spacesDiffCount > 0 && 0 <= bSpacesCount-1 && bSpacesCount-1 < a.length && bSpacesCount < b.length {
+			if b.at(bSpacesCount) != ' ' && a.at(bSpacesCount-1) == ' ' {
+				if a.at(a.length-1) == ',' {
+					result.looksLikeAlignment = true
+				}
+			}
+		}
+		return result
+	}
+
+	if spacesDiffCount%tabsDiff == 0 {
+		result.spacesDiff = spacesDiffCount / tabsDiff
+	}
+	return result
+}
+
+// abs 计算整数的绝对值
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
+
+// GuessIndentation 根据相邻行缩进的差异猜测文本使用的缩进方式
+// 算法参考VSCode的guessIndentation，直接读取行索引中的字符，不复制整个文本
+func (tb *TextBuffer) GuessIndentation(defaultTabSize int, defaultInsertSpaces bool) IndentationGuess {
+	tb.mutex.RLock()
+	defer tb.mutex.RUnlock()
+
+	gb := tb.gapBuffer
+	linesCount := gb.GetLineCount()
+	if linesCount > maxIndentationGuessLines {
+		linesCount = maxIndentationGuessLines
+	}
+
+	linesIndentedWithTabs := 0
+	linesIndentedWithSpaces := 0
+	previousLine := lineView{gapBuffer: gb}
+	previousLineIndentation := 0
+	spacesDiffCount := make([]int, maxAllowedTabSizeGuess+1)
+
+	for lineIndex := 0; lineIndex < linesCount; lineIndex++ {
+		start, length := gb.lineStartAndLength(lineIndex)
+		currentLine := lineView{gapBuffer: gb, start: start, length: length}
+
+		hasContent := false
+		indentation := 0
+		spacesCount := 0
+		tabsCount := 0
+		for j := 0; j < length; j++ {
+			switch currentLine.at(j) {
+			case '\t':
+				tabsCount++
+			case ' ':
+				spacesCount++
+			default:
+				hasContent = true
+				indentation = j
+			}
+			if hasContent {
+				break
+			}
+		}
+
+		// 忽略空行和只包含空白字符的行
+		if !hasContent {
+			continue
+		}
+
+		if tabsCount > 0 {
+			linesIndentedWithTabs++
+		} else if spacesCount > 1 {
+			linesIndentedWithSpaces++
+		}
+
+		diff := spacesDiff(previousLine, previousLineIndentation, currentLine, indentation)
+		if diff.looksLikeAlignment {
+			// 如果默认使用空格并且差异恰好等于默认缩进宽度，仍然将其视为缩进，例如：
+			// - item1
+			//   - item2
+			// 否则完全跳过这一行
+			if !(defaultInsertSpaces && defaultTabSize == diff.spacesDiff) {
+				continue
+			}
+		}
+
+		if diff.spacesDiff <= maxAllowedTabSizeGuess {
+			spacesDiffCount[diff.spacesDiff]++
+		}
+
+		previousLine = currentLine
+		previousLineIndentation = indentation
+	}
+
+	guess := IndentationGuess{
+		TabSize:                 defaultTabSize,
+		InsertSpaces:            defaultInsertSpaces,
+		LinesIndentedWithTabs:   linesIndentedWithTabs,
+		LinesIndentedWithSpaces: linesIndentedWithSpaces,
+	}
+
+	indentedLines := linesIndentedWithTabs + linesIndentedWithSpaces
+	if linesIndentedWithTabs != linesIndentedWithSpaces {
+		guess.InsertSpaces = linesIndentedWithTabs < linesIndentedWithSpaces
+		guess.Confidence = float64(max(linesIndentedWithTabs, linesIndentedWithSpaces)) / float64(indentedLines)
+	}
+
+	// 只有使用空格缩进时才猜测缩进宽度
+	if guess.InsertSpaces {
+		tabSizeScore := 0
+		for _, possibleTabSize := range allowedTabSizeGuesses {
+			if spacesDiffCount[possibleTabSize] > tabSizeScore {
+				tabSizeScore = spacesDiffCount[possibleTabSize]
+				guess.TabSize = possibleTabSize
+			}
+		}
+
+		// 如果猜测结果是4，但缩进为2的情况也足够多，则使用2
+		if guess.TabSize == 4 && spacesDiffCount[4] > 0 && spacesDiffCount[2] > 0 && 2*spacesDiffCount[2] >= spacesDiffCount[4] {
+			guess.TabSize = 2
+		}
+
+		// 缩进宽度的置信度是获胜宽度的差异占所有非零差异的比例
+		totalDiffs := 0
+		for size := 1; size <= maxAllowedTabSizeGuess; size++ {
+			totalDiffs += spacesDiffCount[size]
+		}
+		if totalDiffs == 0 || guess.TabSize > maxAllowedTabSizeGuess {
+			guess.Confidence = 0
+		} else {
+			guess.Confidence *= float64(spacesDiffCount[guess.TabSize]) / float64(totalDiffs)
+		}
+	}
+
+	return guess
+}
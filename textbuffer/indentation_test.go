@@ -0,0 +1,63 @@
+package textbuffer
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGuessIndentation(t *testing.T) {
+	tests := []struct {
+		name         string
+		lines        []string
+		tabSize      int
+		insertSpaces bool
+	}{
+		{
+			name:         "tabs",
+			lines:        []string{"func main() {", "\tif ok {", "\t\treturn", "\t}", "}"},
+			tabSize:      4,
+			insertSpaces: false,
+		},
+		{
+			name:         "two spaces",
+			lines:        []string{"a:", "  b:", "    c: 1", "  d: 2", "e: 3"},
+			tabSize:      2,
+			insertSpaces: true,
+		},
+		{
+			name:         "four spaces",
+			lines:        []string{"def f():", "    if x:", "        return 1", "    return 2"},
+			tabSize:      4,
+			insertSpaces: true,
+		},
+		{
+			name:         "eight spaces",
+			lines:        []string{"int main() {", "        foo();", "        if (x) {", "                bar();", "        }", "}"},
+			tabSize:      8,
+			insertSpaces: true,
+		},
+		{
+			name:         "alignment is ignored",
+			lines:        []string{"let a = 1,", "    b = 2;", "if (a) {", "  b();", "}"},
+			tabSize:      2,
+			insertSpaces: true,
+		},
+	}
+
+	for _, test := range tests {
+		buffer := NewTextBufferWithText(strings.Join(test.lines, "\n"))
+		guess := buffer.GuessIndentation(4, false)
+		if guess.TabSize != test.tabSize || guess.InsertSpaces != test.insertSpaces {
+			t.Errorf("%s: expected tabSize %d insertSpaces %v, got %+v", test.name, test.tabSize, test.insertSpaces, guess)
+		}
+		if guess.Confidence <= 0 || guess.Confidence > 1 {
+			t.Errorf("%s: expected confidence in (0, 1], got %f", test.name, guess.Confidence)
+		}
+	}
+
+	// 没有缩进的文本使用默认值，置信度为0
+	guess := NewTextBufferWithText("a\nb\nc").GuessIndentation(4, true)
+	if guess.TabSize != 4 || !guess.InsertSpaces || guess.Confidence != 0 {
+		t.Errorf("Expected defaults with zero confidence, got %+v", guess)
+	}
+}
@@ -0,0 +1,155 @@
+package textbuffer
+
+import (
+	"regexp"
+)
+
+// CharacterPair 表示一对成对出现的字符串，例如括号
+type CharacterPair struct {
+	// Open 开始字符串
+	Open string
+	// Close 结束字符串
+	Close string
+}
+
+// CommentRule 描述一种语言的注释符号
+type CommentRule struct {
+	// LineComment 行注释的开始符号，例如"//"或"#"
+	LineComment string
+	// BlockComment 块注释的开始和结束符号，例如"/*"和"*/"
+	BlockComment *CharacterPair
+}
+
+// AutoClosingPair 描述输入开始字符串时自动插入的结束字符串
+type AutoClosingPair struct {
+	// Open 开始字符串
+	Open string
+	// Close 自动插入的结束字符串
+	Close string
+	// NotIn 不自动闭合的词法单元分类，例如在字符串中输入引号时不自动闭合
+	NotIn []StandardTokenType
+}
+
+// IndentationRules 描述根据行内容调整缩进的规则
+type IndentationRules struct {
+	// IncreaseIndentPattern 匹配的行之后的行增加一级缩进
+	IncreaseIndentPattern *regexp.Regexp
+	// DecreaseIndentPattern 匹配的行减少一级缩进
+	DecreaseIndentPattern *regexp.Regexp
+}
+
+// IndentAction 表示按下回车后新行的缩进方式
+type IndentAction int
+
+const (
+	// IndentActionNone 新行使用与当前行相同的缩进
+	IndentActionNone IndentAction = iota
+	// IndentActionIndent 新行增加一级缩进
+	IndentActionIndent
+	// IndentActionIndentOutdent 插入两行，第一行增加一级缩进，第二行使用当前行的缩进
+	IndentActionIndentOutdent
+	// IndentActionOutdent 新行减少一级缩进
+	IndentActionOutdent
+)
+
+// EnterAction 描述按下回车时的行为
+type EnterAction struct {
+	// Indent 新行的缩进方式
+	Indent IndentAction
+	// AppendText 在新行的缩进之后插入的文本
+	AppendText string
+	// RemoveText 从新行的缩进中删除的字符数
+	RemoveText int
+}
+
+// OnEnterRule 描述按下回车时根据光标前后的文本执行的动作
+type OnEnterRule struct {
+	// BeforeText 匹配光标之前的文本（同一行）
+	BeforeText *regexp.Regexp
+	// AfterText 匹配光标之后的文本（同一行），为空时不检查
+	AfterText *regexp.Regexp
+	// PreviousLineText 匹配上一行的文本，为空时不检查
+	PreviousLineText *regexp.Regexp
+	// Action 规则匹配时执行的动作
+	Action EnterAction
+}
+
+// LanguageConfiguration 描述一种语言的编辑相关配置
+type LanguageConfiguration struct {
+	// Comments 注释符号，为空时不支持切换注释
+	Comments *CommentRule
+	// Brackets 括号对，用于括号匹配和嵌套深度计算
+	Brackets []CharacterPair
+	// AutoClosingPairs 输入时自动闭合的字符对
+	AutoClosingPairs []AutoClosingPair
+	// SurroundingPairs 有选区时输入开始字符串会用它们包围选区的字符对
+	SurroundingPairs []CharacterPair
+	// WordPattern 匹配单词的正则表达式，为空时使用默认的单词规则
+	WordPattern *regexp.Regexp
+	// IndentationRules 缩进规则，为空时新行使用上一行的缩进
+	IndentationRules *IndentationRules
+	// Folding 折叠规则，为空时只根据缩进计算折叠范围
+	Folding *FoldingRules
+	// OnEnterRules 按下回车时的规则，按顺序使用第一个匹配的规则
+	OnEnterRules []OnEnterRule
+}
+
+// DefaultWordPattern 是默认的单词规则：数字，或者不包含空白和常见分隔符的连续字符
+var DefaultWordPattern = regexp.MustCompile("-?\\d*\\.\\d\\w*|[^\\s`~!@#$%^&*()\\-=+\\[{\\]}\\\\|;:'\",.<>/?]+")
+
+// defaultLanguageConfiguration 是没有设置语言时使用的配置
+var defaultLanguageConfiguration = DefaultLanguageConfiguration()
+
+// DefaultLanguageConfiguration 获取默认的语言配置
+func DefaultLanguageConfiguration() *LanguageConfiguration {
+	return &LanguageConfiguration{
+		Brackets: []CharacterPair{
+			{Open: "{", Close: "}"},
+			{Open: "[", Close: "]"},
+			{Open: "(", Close: ")"},
+		},
+	}
+}
+
+// SetLanguageConfiguration 设置文本缓冲区使用的语言配置，设置后优先于语言标识对应的配置
+// 为nil时使用语言标识对应的配置
+func (tb *TextBuffer) SetLanguageConfiguration(config *LanguageConfiguration) {
+	tb.mutex.Lock()
+	defer tb.unlock()
+
+	tb.languageConfiguration = config
+	tb.languageConfigurationChanged()
+}
+
+// GetLanguageConfiguration 获取文本缓冲区使用的语言配置
+func (tb *TextBuffer) GetLanguageConfiguration() *LanguageConfiguration {
+	tb.mutex.RLock()
+	defer tb.mutex.RUnlock()
+	return tb.getLanguageConfiguration()
+}
+
+// getLanguageConfiguration 获取文本缓冲区使用的语言配置
+// 依次使用直接设置的配置、语言标识在注册表中的配置和默认配置
+// 调用方必须持有锁
+func (tb *TextBuffer) getLanguageConfiguration() *LanguageConfiguration {
+	if tb.languageConfiguration != nil {
+		return tb.languageConfiguration
+	}
+	if tb.languageID != "" {
+		if config, ok := tb.getLanguageRegistry().Get(tb.languageID); ok {
+			return config
+		}
+	}
+	return defaultLanguageConfiguration
+}
+
+// languageConfigurationChanged 在语言配置变化后使依赖它的索引失效
+// 调用方必须持有写锁
+func (tb *TextBuffer) languageConfigurationChanged() {
+	if tb.brackets != nil {
+		tb.brackets.invalidateAll()
+	}
+	if tb.folding != nil {
+		tb.folding.lines.invalidateAll()
+	}
+}
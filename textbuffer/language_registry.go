@@ -0,0 +1,236 @@
+package textbuffer
+
+import (
+	"regexp"
+	"sort"
+	"sync"
+)
+
+// LanguageRegistry 是按语言标识索引的语言配置注册表，可以在多个goroutine中使用
+type LanguageRegistry struct {
+	mutex          sync.RWMutex
+	configurations map[string]*LanguageConfiguration
+}
+
+// NewLanguageRegistry 创建一个空的语言配置注册表
+func NewLanguageRegistry() *LanguageRegistry {
+	return &LanguageRegistry{
+		configurations: make(map[string]*LanguageConfiguration),
+	}
+}
+
+// Register 注册一种语言的配置，已经存在的配置会被替换
+// 已经使用这种语言的文本缓冲区需要重新设置语言标识才能使缓存的索引失效
+func (r *LanguageRegistry) Register(languageID string, config *LanguageConfiguration) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.configurations[languageID] = config
+}
+
+// Unregister 移除一种语言的配置
+func (r *LanguageRegistry) Unregister(languageID string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	delete(r.configurations, languageID)
+}
+
+// Get 获取一种语言的配置
+func (r *LanguageRegistry) Get(languageID string) (*LanguageConfiguration, bool) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	config, ok := r.configurations[languageID]
+	return config, ok
+}
+
+// Languages 获取所有已注册的语言标识，按字母顺序排列
+func (r *LanguageRegistry) Languages() []string {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	languages := make([]string, 0, len(r.configurations))
+	for languageID := range r.configurations {
+		languages = append(languages, languageID)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// DefaultLanguageRegistry 是文本缓冲区默认使用的注册表，包含内置语言的配置
+var DefaultLanguageRegistry = newDefaultLanguageRegistry()
+
+// SetLanguageRegistry 设置文本缓冲区查找语言配置时使用的注册表，为nil时使用DefaultLanguageRegistry
+func (tb *TextBuffer) SetLanguageRegistry(registry *LanguageRegistry) {
+	tb.mutex.Lock()
+	defer tb.unlock()
+
+	tb.languageRegistry = registry
+	tb.languageConfigurationChanged()
+}
+
+// getLanguageRegistry 获取文本缓冲区使用的注册表
+// 调用方必须持有锁
+func (tb *TextBuffer) getLanguageRegistry() *LanguageRegistry {
+	if tb.languageRegistry == nil {
+		return DefaultLanguageRegistry
+	}
+	return tb.languageRegistry
+}
+
+// SetLanguageID 设置文本缓冲区的语言标识，语言配置从注册表中查找
+func (tb *TextBuffer) SetLanguageID(languageID string) {
+	tb.mutex.Lock()
+	defer tb.unlock()
+
+	tb.languageID = languageID
+	tb.languageConfigurationChanged()
+}
+
+// GetLanguageID 获取文本缓冲区的语言标识
+func (tb *TextBuffer) GetLanguageID() string {
+	tb.mutex.RLock()
+	defer tb.mutex.RUnlock()
+	return tb.languageID
+}
+
+// cLikeBrackets 是C风格语言的括号
+var cLikeBrackets = []CharacterPair{
+	{Open: "{", Close: "}"},
+	{Open: "[", Close: "]"},
+	{Open: "(", Close: ")"},
+}
+
+// notInStringOrComment 表示在字符串和注释中不自动闭合
+var notInStringOrComment = []StandardTokenType{StandardTokenString, StandardTokenComment}
+
+// regionMarkers 创建使用指定注释符号的区域标记
+func regionMarkers(comment string) *FoldingMarkers {
+	quoted := regexp.QuoteMeta(comment)
+	return &FoldingMarkers{
+		Start: regexp.MustCompile(`^\s*` + quoted + `\s*#?region\b`),
+		End:   regexp.MustCompile(`^\s*` + quoted + `\s*#?endregion\b`),
+	}
+}
+
+// newDefaultLanguageRegistry 创建包含内置语言配置的注册表
+func newDefaultLanguageRegistry() *LanguageRegistry {
+	registry := NewLanguageRegistry()
+
+	registry.Register("go", &LanguageConfiguration{
+		Comments: &CommentRule{
+			LineComment:  "//",
+			BlockComment: &CharacterPair{Open: "/*", Close: "*/"},
+		},
+		Brackets: cLikeBrackets,
+		AutoClosingPairs: []AutoClosingPair{
+			{Open: "{", Close: "}"},
+			{Open: "[", Close: "]"},
+			{Open: "(", Close: ")"},
+			{Open: "\"", Close: "\"", NotIn: notInStringOrComment},
+			{Open: "'", Close: "'", NotIn: notInStringOrComment},
+			{Open: "`", Close: "`", NotIn: notInStringOrComment},
+		},
+		SurroundingPairs: []CharacterPair{
+			{Open: "{", Close: "}"},
+			{Open: "[", Close: "]"},
+			{Open: "(", Close: ")"},
+			{Open: "\"", Close: "\""},
+			{Open: "'", Close: "'"},
+			{Open: "`", Close: "`"},
+		},
+		IndentationRules: &IndentationRules{
+			IncreaseIndentPattern: regexp.MustCompile(`^.*(\{[^}"'` + "`" + `]*|\([^)"'` + "`" + `]*|\[[^\]"'` + "`" + `]*|\bcase\b.*:|\bdefault\s*:)\s*$`),
+			DecreaseIndentPattern: regexp.MustCompile(`^\s*(\bcase\b.*:|\bdefault\s*:|\}[),]?|\)[,]?|\][,]?)\s*$`),
+		},
+		Folding: &FoldingRules{Markers: regionMarkers("//")},
+	})
+
+	registry.Register("json", &LanguageConfiguration{
+		Comments: &CommentRule{
+			LineComment:  "//",
+			BlockComment: &CharacterPair{Open: "/*", Close: "*/"},
+		},
+		Brackets: []CharacterPair{
+			{Open: "{", Close: "}"},
+			{Open: "[", Close: "]"},
+		},
+		AutoClosingPairs: []AutoClosingPair{
+			{Open: "{", Close: "}", NotIn: []StandardTokenType{StandardTokenString}},
+			{Open: "[", Close: "]", NotIn: []StandardTokenType{StandardTokenString}},
+			{Open: "\"", Close: "\"", NotIn: []StandardTokenType{StandardTokenString}},
+		},
+		SurroundingPairs: []CharacterPair{
+			{Open: "{", Close: "}"},
+			{Open: "[", Close: "]"},
+			{Open: "\"", Close: "\""},
+		},
+		IndentationRules: &IndentationRules{
+			IncreaseIndentPattern: regexp.MustCompile(`^.*(\{[^}]*|\[[^\]]*)$`),
+			DecreaseIndentPattern: regexp.MustCompile(`^\s*[}\]],?\s*$`),
+		},
+	})
+
+	registry.Register("markdown", &LanguageConfiguration{
+		Comments: &CommentRule{
+			BlockComment: &CharacterPair{Open: "<!--", Close: "-->"},
+		},
+		Brackets: []CharacterPair{
+			{Open: "{", Close: "}"},
+			{Open: "[", Close: "]"},
+			{Open: "(", Close: ")"},
+		},
+		AutoClosingPairs: []AutoClosingPair{
+			{Open: "{", Close: "}"},
+			{Open: "[", Close: "]"},
+			{Open: "(", Close: ")"},
+			{Open: "<", Close: ">", NotIn: []StandardTokenType{StandardTokenString}},
+		},
+		SurroundingPairs: []CharacterPair{
+			{Open: "(", Close: ")"},
+			{Open: "[", Close: "]"},
+			{Open: "`", Close: "`"},
+			{Open: "_", Close: "_"},
+			{Open: "*", Close: "*"},
+		},
+		WordPattern: regexp.MustCompile(`\p{L}[\p{L}\p{N}'_-]*|\p{N}+`),
+		Folding: &FoldingRules{
+			OffSide: true,
+			Markers: &FoldingMarkers{
+				Start: regexp.MustCompile(`^\s*<!--\s*#?region\b.*-->`),
+				End:   regexp.MustCompile(`^\s*<!--\s*#?endregion\b.*-->`),
+			},
+		},
+		OnEnterRules: []OnEnterRule{
+			{BeforeText: regexp.MustCompile(`^\s*- \S`), Action: EnterAction{AppendText: "- "}},
+			{BeforeText: regexp.MustCompile(`^\s*\* \S`), Action: EnterAction{AppendText: "* "}},
+			{BeforeText: regexp.MustCompile(`^\s*> `), Action: EnterAction{AppendText: "> "}},
+		},
+	})
+
+	registry.Register("shell", &LanguageConfiguration{
+		Comments: &CommentRule{LineComment: "#"},
+		Brackets: cLikeBrackets,
+		AutoClosingPairs: []AutoClosingPair{
+			{Open: "{", Close: "}"},
+			{Open: "[", Close: "]"},
+			{Open: "(", Close: ")"},
+			{Open: "\"", Close: "\"", NotIn: notInStringOrComment},
+			{Open: "'", Close: "'", NotIn: notInStringOrComment},
+			{Open: "`", Close: "`", NotIn: notInStringOrComment},
+		},
+		SurroundingPairs: []CharacterPair{
+			{Open: "{", Close: "}"},
+			{Open: "[", Close: "]"},
+			{Open: "(", Close: ")"},
+			{Open: "\"", Close: "\""},
+			{Open: "'", Close: "'"},
+			{Open: "`", Close: "`"},
+		},
+		IndentationRules: &IndentationRules{
+			IncreaseIndentPattern: regexp.MustCompile(`^\s*(\b(if|while|for|until|select|case)\b.*\b(then|do|in)\b|\belse\b|.*\{|\S+\))\s*(#.*)?$`),
+			DecreaseIndentPattern: regexp.MustCompile(`^\s*(\b(fi|done|esac|else|elif)\b|\}|;;)`),
+		},
+		Folding: &FoldingRules{Markers: regionMarkers("#")},
+	})
+
+	return registry
+}
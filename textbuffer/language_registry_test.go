@@ -0,0 +1,101 @@
+package textbuffer
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestLanguageRegistry(t *testing.T) {
+	languages := DefaultLanguageRegistry.Languages()
+	if len(languages) != 4 || languages[0] != "go" || languages[3] != "shell" {
+		t.Errorf("Unexpected builtin languages %v", languages)
+	}
+
+	registry := NewLanguageRegistry()
+	registry.Register("test", &LanguageConfiguration{
+		Comments: &CommentRule{LineComment: ";"},
+		Brackets: []CharacterPair{{Open: "<", Close: ">"}},
+	})
+
+	buffer := NewTextBufferWithText("<a>\n(b)")
+	buffer.SetLanguageRegistry(registry)
+	buffer.SetLanguageID("test")
+	if buffer.GetLanguageID() != "test" {
+		t.Errorf("Expected language ID 'test', got '%s'", buffer.GetLanguageID())
+	}
+
+	// 括号匹配和注释切换都使用注册表中的配置
+	if match, ok := buffer.MatchBracket(Position{Line: 0, Column: 0}); !ok || !match.Equals(Position{Line: 0, Column: 2}) {
+		t.Errorf("Expected match at (0, 2), got %v %v", match, ok)
+	}
+	if _, ok := buffer.MatchBracket(Position{Line: 1, Column: 0}); ok {
+		t.Errorf("Expected '(' not to be a bracket")
+	}
+	if err := buffer.ToggleLineComment(NewRange(Position{Line: 0, Column: 0}, Position{Line: 0, Column: 0}), nil); err != nil {
+		t.Fatalf("ToggleLineComment failed: %v", err)
+	}
+	if buffer.GetText() != "; <a>\n(b)" {
+		t.Errorf("Expected '; <a>\\n(b)', got '%s'", buffer.GetText())
+	}
+
+	// 直接设置的配置优先于注册表
+	buffer.SetLanguageConfiguration(DefaultLanguageConfiguration())
+	if _, ok := buffer.MatchBracket(Position{Line: 1, Column: 0}); !ok {
+		t.Errorf("Expected '(' to be a bracket with the explicit configuration")
+	}
+
+	// 未注册的语言使用默认配置
+	buffer = NewTextBufferWithText("(x)")
+	buffer.SetLanguageID("unknown")
+	if buffer.GetLanguageConfiguration().Comments != nil {
+		t.Errorf("Expected the default configuration for an unknown language")
+	}
+
+	registry.Unregister("test")
+	if _, ok := registry.Get("test"); ok {
+		t.Errorf("Expected language to be unregistered")
+	}
+}
+
+func TestReindentWithIndentationRules(t *testing.T) {
+	buffer := NewTextBufferWithText("func f() {\nx()\n\t}")
+	buffer.SetLanguageID("go")
+	options := IndentOptions{TabSize: 4}
+
+	if err := buffer.ReindentLine(1, options); err != nil {
+		t.Fatalf("ReindentLine failed: %v", err)
+	}
+	if err := buffer.ReindentLine(2, options); err != nil {
+		t.Fatalf("ReindentLine failed: %v", err)
+	}
+	if buffer.GetText() != "func f() {\n\tx()\n}" {
+		t.Errorf("Expected %q, got %q", "func f() {\n\tx()\n}", buffer.GetText())
+	}
+}
+
+func TestGetWordAtPosition(t *testing.T) {
+	buffer := NewTextBufferWithText("foo.bar(日本語, 3.14)")
+
+	tests := []struct {
+		column   int
+		expected string
+		ok       bool
+	}{
+		{0, "foo", true},
+		{3, "foo", true},
+		{5, "bar", true},
+		{9, "日本語", true},
+		{14, "3.14", true},
+	}
+	for _, test := range tests {
+		r, ok := buffer.GetWordAtPosition(Position{Line: 0, Column: test.column})
+		if ok != test.ok || buffer.GetTextInRange(r) != test.expected {
+			t.Errorf("Column %d: expected '%s', got '%s' (%v)", test.column, test.expected, buffer.GetTextInRange(r), ok)
+		}
+	}
+
+	buffer.SetLanguageConfiguration(&LanguageConfiguration{WordPattern: regexp.MustCompile(`[\w.]+`)})
+	if r, _ := buffer.GetWordAtPosition(Position{Line: 0, Column: 1}); buffer.GetTextInRange(r) != "foo.bar" {
+		t.Errorf("Expected 'foo.bar' with custom word pattern, got '%s'", buffer.GetTextInRange(r))
+	}
+}
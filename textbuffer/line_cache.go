@@ -0,0 +1,97 @@
+package textbuffer
+
+import (
+	"strings"
+)
+
+// lineObserver 接收行变化的通知，用于增量更新按行计算的索引
+type lineObserver interface {
+	// linesChanged 表示从startLine开始的removedLines+1行被替换为addedLines+1行
+	linesChanged(startLine, removedLines, addedLines int)
+}
+
+// lineDataCache 按行缓存派生的数据，编辑时只使受影响的行失效
+type lineDataCache[T any] struct {
+	entries []lineDataEntry[T]
+}
+
+// lineDataEntry 是一行缓存的数据
+type lineDataEntry[T any] struct {
+	value T
+	valid bool
+}
+
+// linesChanged 使受编辑影响的行失效，并根据增加或删除的行移动之后的行
+func (c *lineDataCache[T]) linesChanged(startLine, removedLines, addedLines int) {
+	if startLine >= len(c.entries) {
+		return
+	}
+
+	end := startLine + removedLines + 1
+	if end > len(c.entries) {
+		end = len(c.entries)
+	}
+
+	replacement := make([]lineDataEntry[T], addedLines+1)
+	c.entries = append(c.entries[:startLine], append(replacement, c.entries[end:]...)...)
+}
+
+// resize 使缓存的行数与文本的行数一致
+func (c *lineDataCache[T]) resize(lineCount int) {
+	if len(c.entries) > lineCount {
+		c.entries = c.entries[:lineCount]
+	}
+	for len(c.entries) < lineCount {
+		c.entries = append(c.entries, lineDataEntry[T]{})
+	}
+}
+
+// get 获取一行缓存的数据
+func (c *lineDataCache[T]) get(lineIndex int) (T, bool) {
+	if lineIndex < 0 || lineIndex >= len(c.entries) || !c.entries[lineIndex].valid {
+		var zero T
+		return zero, false
+	}
+	return c.entries[lineIndex].value, true
+}
+
+// set 设置一行缓存的数据
+func (c *lineDataCache[T]) set(lineIndex int, value T) {
+	if lineIndex < 0 || lineIndex >= len(c.entries) {
+		return
+	}
+	c.entries[lineIndex] = lineDataEntry[T]{value: value, valid: true}
+}
+
+// invalidate 使一行失效
+func (c *lineDataCache[T]) invalidate(lineIndex int) {
+	if lineIndex >= 0 && lineIndex < len(c.entries) {
+		c.entries[lineIndex] = lineDataEntry[T]{}
+	}
+}
+
+// invalidateAll 使所有行失效
+func (c *lineDataCache[T]) invalidateAll() {
+	c.entries = nil
+}
+
+// addLineObserver 注册一个行变化的观察者
+// 调用方必须持有写锁
+func (tb *TextBuffer) addLineObserver(observer lineObserver) {
+	tb.lineObservers = append(tb.lineObservers, observer)
+}
+
+// notifyLinesChanged 在应用编辑之前通知观察者受影响的行
+// 调用方必须持有写锁
+func (tb *TextBuffer) notifyLinesChanged(edit Edit) {
+	if len(tb.lineObservers) == 0 {
+		return
+	}
+
+	startLine := tb.gapBuffer.GetPositionAt(edit.Offset).Line
+	removedLines := strings.Count(edit.OldText, "\n")
+	addedLines := strings.Count(edit.NewText, "\n")
+	for _, observer := range tb.lineObservers {
+		observer.linesChanged(startLine, removedLines, addedLines)
+	}
+}
@@ -0,0 +1,478 @@
+package textbuffer
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// 行命令对一个行范围进行操作，每个命令是一个撤销步骤
+// 命令接收当前的选区并返回命令执行后的选区，两者都记录在撤销栈中；
+// 传入的选区为空时使用跟踪的选区
+
+// SortOptions 描述排序行的方式
+type SortOptions struct {
+	// Descending 是否降序排列
+	Descending bool
+	// IgnoreCase 是否忽略大小写
+	IgnoreCase bool
+	// Natural 是否使用自然排序，连续的数字按数值比较
+	Natural bool
+}
+
+// blockLine 是行范围内的一行，换行符随内容一起移动
+type blockLine struct {
+	// content 行的内容，不包括换行符
+	content string
+	// eol 行末尾的换行符，文本的最后一行为空字符串
+	eol string
+}
+
+// blockLines 获取一个行范围内的每一行，每一行保留它原来的换行符
+// 调用方必须持有锁
+func (tb *TextBuffer) blockLines(startLine, endLine int) []blockLine {
+	lines := make([]blockLine, 0, endLine-startLine+1)
+	for lineIndex := startLine; lineIndex <= endLine; lineIndex++ {
+		text := tb.gapBuffer.GetLineContent(lineIndex)
+		eol := lineEnding(text)
+		lines = append(lines, blockLine{content: strings.TrimSuffix(text, eol), eol: eol})
+	}
+	return lines
+}
+
+// blockContents 获取每一行不包括换行符的内容
+func blockContents(lines []blockLine) []string {
+	contents := make([]string, len(lines))
+	for i, line := range lines {
+		contents[i] = line.content
+	}
+	return contents
+}
+
+// joinBlockLines 把行连接为文本，每一行使用它自己的换行符
+// noFinalEOL表示行范围位于没有末尾换行符的文本末尾，此时最后一行不写入换行符，
+// 原来的最后一行被移动到中间时使用移动到最后的行的换行符；
+// 仍然缺少换行符时（例如复制了最后一行），使用这些行中出现的第一个换行符
+func joinBlockLines(lines []blockLine, noFinalEOL bool) string {
+	fallback := "\n"
+	for _, line := range lines {
+		if line.eol != "" {
+			fallback = line.eol
+			break
+		}
+	}
+
+	last := len(lines) - 1
+	displaced := ""
+	if noFinalEOL {
+		displaced = lines[last].eol
+	}
+	var builder strings.Builder
+	for i, line := range lines {
+		builder.WriteString(line.content)
+		switch {
+		case i == last:
+			if !noFinalEOL {
+				builder.WriteString(line.eol)
+			}
+		case line.eol != "":
+			builder.WriteString(line.eol)
+		case displaced != "":
+			builder.WriteString(displaced)
+			displaced = ""
+		default:
+			builder.WriteString(fallback)
+		}
+	}
+	return builder.String()
+}
+
+// replaceLineBlock 用newLines替换一个行范围内的所有行（包括它们的换行符），作为一个操作应用
+// mapPosition把编辑之前的选区位置映射到编辑之后的位置
+// 调用方必须持有写锁
+func (tb *TextBuffer) replaceLineBlock(startLine, endLine int, newLines []blockLine, label string, selections []Selection, mapPosition func(Position) Position) []Selection {
+	start, _ := tb.gapBuffer.lineStartAndLength(startLine)
+	end := tb.gapBuffer.GetLength()
+	if endLine < tb.gapBuffer.GetLineCount()-1 {
+		end, _ = tb.gapBuffer.lineStartAndLength(endLine + 1)
+	}
+	oldText := tb.gapBuffer.getTextInOffsets(start, end)
+	noFinalEOL := lineEnding(oldText) == ""
+	return tb.pushLineEdit(start, oldText, joinBlockLines(newLines, noFinalEOL), label, selections, mapPosition)
+}
+
+// commandSelections 获取命令使用的选区，传入的选区为空时使用跟踪的选区
+// 调用方必须持有锁
+func (tb *TextBuffer) commandSelections(selections []Selection) []Selection {
+	if selections == nil && len(tb.selections) > 0 {
+		return tb.getSelections()
+	}
+	return cloneSelections(selections)
+}
+
+// pushLineEdit 将从offset开始的oldText替换为newText，并按mapPosition调整选区
+// 调用方必须持有写锁
+func (tb *TextBuffer) pushLineEdit(offset int, oldText, newText string, label string, selections []Selection, mapPosition func(Position) Position) []Selection {
+	selections = tb.commandSelections(selections)
+
+	edit := minimalEdit(oldText, newText)
+	if edit.OldText == edit.NewText {
+		return selections
+	}
+	edit.Offset += offset
+
+	var after []Selection
+	if selections != nil {
+		after = make([]Selection, len(selections))
+		for i, selection := range selections {
+			after[i] = NewSelection(mapPosition(selection.Anchor), mapPosition(selection.Active))
+		}
+	}
+
+	tb.pushOperation(&TextOperation{
+		Type:             operationTypeOf([]Edit{edit}),
+		Label:            label,
+		Edits:            []Edit{edit},
+		BeforeSelections: selections,
+		AfterSelections:  cloneSelections(after),
+	})
+
+	return after
+}
+
+// shiftLines 把位于一个行范围之后的位置平移delta行，其他位置由mapLine处理
+func shiftLines(endLine, delta int, mapLine func(Position) Position) func(Position) Position {
+	return func(position Position) Position {
+		if position.Line > endLine {
+			return Position{Line: position.Line + delta, Column: position.Column}
+		}
+		return mapLine(position)
+	}
+}
+
+// keepPositions 创建一个保持选区位置不变的映射，用于原地重排行的命令
+// 被删除的行上的位置移动到新行范围的末尾
+func keepPositions(startLine, endLine int, newLines []blockLine) func(Position) Position {
+	newEnd := startLine + len(newLines) - 1
+	return shiftLines(endLine, newEnd-endLine, func(position Position) Position {
+		if position.Line < startLine {
+			return position
+		}
+		line := min(position.Line, newEnd)
+		length := utf8.RuneCountInString(newLines[line-startLine].content)
+		if position.Line > newEnd {
+			return Position{Line: line, Column: length}
+		}
+		return Position{Line: line, Column: min(position.Column, length)}
+	})
+}
+
+// MoveLinesUp 将一个行范围内的行向上移动一行，选区随行移动
+func (tb *TextBuffer) MoveLinesUp(startLine, endLine int, selections []Selection) ([]Selection, error) {
+	tb.mutex.Lock()
+	defer tb.unlock()
+
+	startLine, endLine, err := tb.clampLineRange(startLine, endLine)
+	if err != nil {
+		return nil, err
+	}
+	if startLine == 0 {
+		return tb.commandSelections(selections), nil
+	}
+
+	lines := tb.blockLines(startLine-1, endLine)
+	newLines := append(lines[1:len(lines):len(lines)], lines[0])
+	return tb.replaceLineBlock(startLine-1, endLine, newLines, "Move Lines Up", selections, func(position Position) Position {
+		switch {
+		case position.Line == startLine-1:
+			position.Line = endLine
+		case position.Line >= startLine && position.Line <= endLine:
+			position.Line--
+		}
+		return position
+	}), nil
+}
+
+// MoveLinesDown 将一个行范围内的行向下移动一行，选区随行移动
+func (tb *TextBuffer) MoveLinesDown(startLine, endLine int, selections []Selection) ([]Selection, error) {
+	tb.mutex.Lock()
+	defer tb.unlock()
+
+	startLine, endLine, err := tb.clampLineRange(startLine, endLine)
+	if err != nil {
+		return nil, err
+	}
+	if endLine == tb.gapBuffer.GetLineCount()-1 {
+		return tb.commandSelections(selections), nil
+	}
+
+	lines := tb.blockLines(startLine, endLine+1)
+	newLines := append([]blockLine{lines[len(lines)-1]}, lines[:len(lines)-1]...)
+	return tb.replaceLineBlock(startLine, endLine+1, newLines, "Move Lines Down", selections, func(position Position) Position {
+		switch {
+		case position.Line == endLine+1:
+			position.Line = startLine
+		case position.Line >= startLine && position.Line <= endLine:
+			position.Line++
+		}
+		return position
+	}), nil
+}
+
+// DuplicateLines 在一个行范围之后插入它的副本，选区移动到副本上
+func (tb *TextBuffer) DuplicateLines(startLine, endLine int, selections []Selection) ([]Selection, error) {
+	tb.mutex.Lock()
+	defer tb.unlock()
+
+	startLine, endLine, err := tb.clampLineRange(startLine, endLine)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := tb.blockLines(startLine, endLine)
+	count := len(lines)
+	newLines := append(lines[:count:count], lines...)
+	return tb.replaceLineBlock(startLine, endLine, newLines, "Duplicate Lines", selections, func(position Position) Position {
+		if position.Line >= startLine {
+			position.Line += count
+		}
+		return position
+	}), nil
+}
+
+// DeleteLines 删除一个行范围内的所有行（包括换行符），范围内的选区变为下一行开头的光标
+func (tb *TextBuffer) DeleteLines(startLine, endLine int, selections []Selection) ([]Selection, error) {
+	tb.mutex.Lock()
+	defer tb.unlock()
+
+	startLine, endLine, err := tb.clampLineRange(startLine, endLine)
+	if err != nil {
+		return nil, err
+	}
+
+	lineCount := tb.gapBuffer.GetLineCount()
+	start, _ := tb.gapBuffer.lineStartAndLength(startLine)
+	end := tb.gapBuffer.GetLength()
+	if endLine < lineCount-1 {
+		end, _ = tb.gapBuffer.lineStartAndLength(endLine + 1)
+	} else if startLine > 0 && (end == 0 || tb.gapBuffer.runeAt(end-1) != '\n') {
+		// 删除没有换行符的最后一行时，同时删除上一行的换行符
+		start--
+		if start > 0 && tb.gapBuffer.runeAt(start-1) == '\r' {
+			start--
+		}
+	}
+
+	count := endLine - startLine + 1
+	cursorLine := min(startLine, max(lineCount-count-1, 0))
+	return tb.pushLineEdit(start, tb.gapBuffer.getTextInOffsets(start, end), "", "Delete Lines", selections,
+		shiftLines(endLine, -count, func(position Position) Position {
+			if position.Line < startLine {
+				return position
+			}
+			return Position{Line: cursorLine, Column: 0}
+		})), nil
+}
+
+// JoinLines 将一个行范围内的行合并为一行，范围只有一行时与下一行合并
+// 合并时去掉行首和行尾的空白，两个非空的行之间用一个空格分隔
+func (tb *TextBuffer) JoinLines(startLine, endLine int, selections []Selection) ([]Selection, error) {
+	tb.mutex.Lock()
+	defer tb.unlock()
+
+	startLine, endLine, err := tb.clampLineRange(startLine, endLine)
+	if err != nil {
+		return nil, err
+	}
+	if startLine == endLine {
+		if endLine == tb.gapBuffer.GetLineCount()-1 {
+			return tb.commandSelections(selections), nil
+		}
+		endLine++
+	}
+
+	block := tb.blockLines(startLine, endLine)
+	lines := blockContents(block)
+
+	// 记录每一行在合并后的起始列、去掉的行首空白和保留的长度，用于映射选区
+	base := make([]int, len(lines))
+	leading := make([]int, len(lines))
+	kept := make([]int, len(lines))
+	var builder strings.Builder
+	joinedLength := 0
+	for i, line := range lines {
+		text := line
+		if i > 0 {
+			text = strings.TrimLeftFunc(text, isTrailingWhitespace)
+			leading[i] = utf8.RuneCountInString(line) - utf8.RuneCountInString(text)
+		}
+		if i < len(lines)-1 {
+			text = strings.TrimRightFunc(text, isTrailingWhitespace)
+		}
+		if i > 0 && joinedLength > 0 && text != "" {
+			builder.WriteByte(' ')
+			joinedLength++
+		}
+		base[i] = joinedLength
+		kept[i] = utf8.RuneCountInString(text)
+		builder.WriteString(text)
+		joinedLength += kept[i]
+	}
+
+	joined := []blockLine{{content: builder.String(), eol: block[len(block)-1].eol}}
+	return tb.replaceLineBlock(startLine, endLine, joined, "Join Lines", selections,
+		shiftLines(endLine, startLine-endLine, func(position Position) Position {
+			if position.Line < startLine {
+				return position
+			}
+			i := position.Line - startLine
+			column := min(max(position.Column-leading[i], 0), kept[i])
+			return Position{Line: startLine, Column: base[i] + column}
+		})), nil
+}
+
+// SortLines 对一个行范围内的行进行排序，相等的行保持原来的顺序，选区保持不变
+func (tb *TextBuffer) SortLines(startLine, endLine int, options SortOptions, selections []Selection) ([]Selection, error) {
+	tb.mutex.Lock()
+	defer tb.unlock()
+
+	startLine, endLine, err := tb.clampLineRange(startLine, endLine)
+	if err != nil {
+		return nil, err
+	}
+
+	sorted := tb.blockLines(startLine, endLine)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if options.Descending {
+			return compareLines(sorted[j].content, sorted[i].content, options) < 0
+		}
+		return compareLines(sorted[i].content, sorted[j].content, options) < 0
+	})
+
+	return tb.replaceLineBlock(startLine, endLine, sorted, "Sort Lines", selections, keepPositions(startLine, endLine, sorted)), nil
+}
+
+// compareLines 按排序选项比较两行
+func compareLines(a, b string, options SortOptions) int {
+	if options.IgnoreCase {
+		a = strings.ToLower(a)
+		b = strings.ToLower(b)
+	}
+	if options.Natural {
+		return compareNatural(a, b)
+	}
+	return strings.Compare(a, b)
+}
+
+// compareNatural 自然排序比较，连续的数字按数值比较，其他字符按码点比较
+func compareNatural(a, b string) int {
+	for a != "" && b != "" {
+		aDigits := leadingDigits(a)
+		bDigits := leadingDigits(b)
+		if aDigits != "" && bDigits != "" {
+			// 去掉前导零之后，位数多的数值更大
+			aNumber := strings.TrimLeft(aDigits, "0")
+			bNumber := strings.TrimLeft(bDigits, "0")
+			if len(aNumber) != len(bNumber) {
+				return len(aNumber) - len(bNumber)
+			}
+			if c := strings.Compare(aNumber, bNumber); c != 0 {
+				return c
+			}
+			a = a[len(aDigits):]
+			b = b[len(bDigits):]
+			continue
+		}
+
+		aRune, aSize := utf8.DecodeRuneInString(a)
+		bRune, bSize := utf8.DecodeRuneInString(b)
+		if aRune != bRune {
+			return int(aRune) - int(bRune)
+		}
+		a = a[aSize:]
+		b = b[bSize:]
+	}
+	return len(a) - len(b)
+}
+
+// leadingDigits 获取字符串开头的连续数字
+func leadingDigits(text string) string {
+	for i, ch := range text {
+		if !unicode.IsDigit(ch) {
+			return text[:i]
+		}
+	}
+	return text
+}
+
+// UniqueLines 删除一个行范围内重复的行，只保留第一次出现的行，选区保持不变
+func (tb *TextBuffer) UniqueLines(startLine, endLine int, selections []Selection) ([]Selection, error) {
+	tb.mutex.Lock()
+	defer tb.unlock()
+
+	startLine, endLine, err := tb.clampLineRange(startLine, endLine)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := tb.blockLines(startLine, endLine)
+	seen := make(map[string]bool, len(lines))
+	unique := make([]blockLine, 0, len(lines))
+	for _, line := range lines {
+		if !seen[line.content] {
+			seen[line.content] = true
+			unique = append(unique, line)
+		}
+	}
+
+	return tb.replaceLineBlock(startLine, endLine, unique, "Unique Lines", selections, keepPositions(startLine, endLine, unique)), nil
+}
+
+// ReverseLines 反转一个行范围内行的顺序，选区保持不变
+func (tb *TextBuffer) ReverseLines(startLine, endLine int, selections []Selection) ([]Selection, error) {
+	tb.mutex.Lock()
+	defer tb.unlock()
+
+	startLine, endLine, err := tb.clampLineRange(startLine, endLine)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := tb.blockLines(startLine, endLine)
+	for i, j := 0, len(lines)-1; i < j; i, j = i+1, j-1 {
+		lines[i], lines[j] = lines[j], lines[i]
+	}
+
+	return tb.replaceLineBlock(startLine, endLine, lines, "Reverse Lines", selections, keepPositions(startLine, endLine, lines)), nil
+}
+
+// TransposeLines 交换一个行范围内的第一行和最后一行，范围只有一行时与下一行交换
+// 选区随行移动
+func (tb *TextBuffer) TransposeLines(startLine, endLine int, selections []Selection) ([]Selection, error) {
+	tb.mutex.Lock()
+	defer tb.unlock()
+
+	startLine, endLine, err := tb.clampLineRange(startLine, endLine)
+	if err != nil {
+		return nil, err
+	}
+	if startLine == endLine {
+		if endLine == tb.gapBuffer.GetLineCount()-1 {
+			return tb.commandSelections(selections), nil
+		}
+		endLine++
+	}
+
+	lines := tb.blockLines(startLine, endLine)
+	lines[0], lines[len(lines)-1] = lines[len(lines)-1], lines[0]
+	return tb.replaceLineBlock(startLine, endLine, lines, "Transpose Lines", selections, func(position Position) Position {
+		switch position.Line {
+		case startLine:
+			position.Line = endLine
+		case endLine:
+			position.Line = startLine
+		}
+		return position
+	}), nil
+}
@@ -0,0 +1,167 @@
+package textbuffer
+
+import (
+	"testing"
+)
+
+func TestMoveAndDuplicateLines(t *testing.T) {
+	buffer := NewTextBufferWithText("a\nb\nc\nd")
+	selections := []Selection{NewSelection(Position{Line: 1, Column: 0}, Position{Line: 2, Column: 1})}
+
+	// 测试向上移动，选区随行移动
+	selections, err := buffer.MoveLinesUp(1, 2, selections)
+	if err != nil {
+		t.Fatalf("MoveLinesUp failed: %v", err)
+	}
+	if buffer.GetText() != "b\nc\na\nd" {
+		t.Errorf("Expected 'b\\nc\\na\\nd', got '%s'", buffer.GetText())
+	}
+	if !selections[0].Equals(NewSelection(Position{Line: 0, Column: 0}, Position{Line: 1, Column: 1})) {
+		t.Errorf("Unexpected selections %v", selections)
+	}
+
+	// 测试向下移动到最后一行
+	selections, err = buffer.MoveLinesDown(2, 2, []Selection{NewCursor(Position{Line: 2, Column: 1})})
+	if err != nil {
+		t.Fatalf("MoveLinesDown failed: %v", err)
+	}
+	if buffer.GetText() != "b\nc\nd\na" || !selections[0].Active.Equals(Position{Line: 3, Column: 1}) {
+		t.Errorf("Unexpected result '%s' %v", buffer.GetText(), selections)
+	}
+
+	// 第一行不能再向上移动
+	if _, err := buffer.MoveLinesUp(0, 0, nil); err != nil || buffer.GetText() != "b\nc\nd\na" {
+		t.Errorf("Expected no change, got '%s' (%v)", buffer.GetText(), err)
+	}
+
+	// 测试复制行，选区移动到副本上
+	selections, err = buffer.DuplicateLines(0, 1, []Selection{NewCursor(Position{Line: 1, Column: 1})})
+	if err != nil {
+		t.Fatalf("DuplicateLines failed: %v", err)
+	}
+	if buffer.GetText() != "b\nc\nb\nc\nd\na" || !selections[0].Active.Equals(Position{Line: 3, Column: 1}) {
+		t.Errorf("Unexpected result '%s' %v", buffer.GetText(), selections)
+	}
+
+	// 每个命令是一个撤销步骤，撤销时恢复命令之前的选区
+	result, err := buffer.Undo()
+	if err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+	if buffer.GetText() != "b\nc\nd\na" || !result.Selections[0].Active.Equals(Position{Line: 1, Column: 1}) {
+		t.Errorf("Unexpected undo result '%s' %v", buffer.GetText(), result.Selections)
+	}
+}
+
+func TestDeleteAndJoinLines(t *testing.T) {
+	buffer := NewTextBufferWithText("a\nb\nc")
+	if _, err := buffer.DeleteLines(1, 1, nil); err != nil || buffer.GetText() != "a\nc" {
+		t.Errorf("Expected 'a\\nc', got '%s' (%v)", buffer.GetText(), err)
+	}
+	selections, err := buffer.DeleteLines(1, 1, []Selection{NewCursor(Position{Line: 1, Column: 1})})
+	if err != nil || buffer.GetText() != "a" || !selections[0].Active.Equals(Position{Line: 0, Column: 0}) {
+		t.Errorf("Unexpected result '%s' %v (%v)", buffer.GetText(), selections, err)
+	}
+
+	buffer = NewTextBufferWithText("x\r\ny\r\n")
+	if _, err := buffer.DeleteLines(0, 0, nil); err != nil || buffer.GetText() != "y\r\n" {
+		t.Errorf("Expected 'y\\r\\n', got %q (%v)", buffer.GetText(), err)
+	}
+
+	// 测试合并行，去掉多余的空白，选区跟随合并后的文本
+	buffer = NewTextBufferWithText("foo  \n   bar\n\nbaz\nqux")
+	selections, err = buffer.JoinLines(0, 3, []Selection{NewCursor(Position{Line: 1, Column: 4}), NewCursor(Position{Line: 4, Column: 1})})
+	if err != nil {
+		t.Fatalf("JoinLines failed: %v", err)
+	}
+	if buffer.GetText() != "foo bar baz\nqux" {
+		t.Errorf("Expected 'foo bar baz\\nqux', got '%s'", buffer.GetText())
+	}
+	if !selections[0].Active.Equals(Position{Line: 0, Column: 5}) || !selections[1].Active.Equals(Position{Line: 1, Column: 1}) {
+		t.Errorf("Unexpected selections %v", selections)
+	}
+
+	// 只有一行时与下一行合并
+	if _, err := buffer.JoinLines(0, 0, nil); err != nil || buffer.GetText() != "foo bar baz qux" {
+		t.Errorf("Expected 'foo bar baz qux', got '%s' (%v)", buffer.GetText(), err)
+	}
+}
+
+func TestSortLines(t *testing.T) {
+	tests := []struct {
+		options  SortOptions
+		expected string
+	}{
+		{SortOptions{}, "B\na\nb\nitem10\nitem2\n"},
+		{SortOptions{IgnoreCase: true}, "a\nb\nB\nitem10\nitem2\n"},
+		{SortOptions{Natural: true}, "B\na\nb\nitem2\nitem10\n"},
+		{SortOptions{Natural: true, Descending: true}, "item10\nitem2\nb\na\nB\n"},
+	}
+
+	for _, test := range tests {
+		buffer := NewTextBufferWithText("item10\nb\nB\nitem2\na\n")
+		if _, err := buffer.SortLines(0, 4, test.options, nil); err != nil {
+			t.Fatalf("SortLines failed: %v", err)
+		}
+		if buffer.GetText() != test.expected {
+			t.Errorf("Options %+v: expected %q, got %q", test.options, test.expected, buffer.GetText())
+		}
+	}
+}
+
+func TestUniqueReverseAndTransposeLines(t *testing.T) {
+	buffer := NewTextBufferWithText("a\nb\na\nc\nb")
+	buffer.SetSelections([]Selection{NewCursor(Position{Line: 4, Column: 1})})
+
+	// 跟踪的选区在行被删除后移动到新范围的末尾
+	selections, err := buffer.UniqueLines(0, 4, nil)
+	if err != nil || buffer.GetText() != "a\nb\nc" {
+		t.Fatalf("Expected 'a\\nb\\nc', got '%s' (%v)", buffer.GetText(), err)
+	}
+	if !selections[0].Active.Equals(Position{Line: 2, Column: 1}) || !buffer.GetSelections()[0].Equals(selections[0]) {
+		t.Errorf("Unexpected selections %v", selections)
+	}
+
+	if _, err := buffer.ReverseLines(0, 2, nil); err != nil || buffer.GetText() != "c\nb\na" {
+		t.Errorf("Expected 'c\\nb\\na', got '%s' (%v)", buffer.GetText(), err)
+	}
+
+	selections, err = buffer.TransposeLines(0, 0, []Selection{NewCursor(Position{Line: 0, Column: 1})})
+	if err != nil || buffer.GetText() != "b\nc\na" || !selections[0].Active.Equals(Position{Line: 1, Column: 1}) {
+		t.Errorf("Unexpected result '%s' %v (%v)", buffer.GetText(), selections, err)
+	}
+
+	if buffer.UndoLabel() != "Transpose Lines" {
+		t.Errorf("Expected undo label 'Transpose Lines', got '%s'", buffer.UndoLabel())
+	}
+}
+
+func TestLineCommandsKeepLineEndings(t *testing.T) {
+	buffer := NewTextBufferWithText("b\r\na\nc\r\nd")
+
+	if _, err := buffer.SortLines(0, 1, SortOptions{}, nil); err != nil || buffer.GetText() != "a\nb\r\nc\r\nd" {
+		t.Errorf("Expected line endings to move with their lines, got %q (%v)", buffer.GetText(), err)
+	}
+
+	// 没有换行符的最后一行移动到中间时，使用移动到最后的行的换行符
+	if _, err := buffer.MoveLinesUp(3, 3, nil); err != nil || buffer.GetText() != "a\nb\r\nd\r\nc" {
+		t.Errorf("Unexpected text after moving the last line up %q (%v)", buffer.GetText(), err)
+	}
+	if _, err := buffer.DuplicateLines(3, 3, nil); err != nil || buffer.GetText() != "a\nb\r\nd\r\nc\nc" {
+		t.Errorf("Unexpected text after duplicating the last line %q (%v)", buffer.GetText(), err)
+	}
+	if _, err := buffer.JoinLines(0, 1, nil); err != nil || buffer.GetText() != "a b\r\nd\r\nc\nc" {
+		t.Errorf("Unexpected text after joining lines %q (%v)", buffer.GetText(), err)
+	}
+
+	// 命令没有修改文本时返回跟踪的选区
+	buffer.SetSelections([]Selection{NewCursor(Position{Line: 0, Column: 1})})
+	selections, err := buffer.MoveLinesUp(0, 0, nil)
+	if err != nil || len(selections) != 1 || !selections[0].Active.Equals(Position{Line: 0, Column: 1}) {
+		t.Errorf("Expected the tracked selections, got %v (%v)", selections, err)
+	}
+	selections, err = buffer.UniqueLines(0, 0, nil)
+	if err != nil || len(selections) != 1 {
+		t.Errorf("Expected the tracked selections for an unchanged block, got %v (%v)", selections, err)
+	}
+}
@@ -0,0 +1,221 @@
+package textbuffer
+
+import (
+	"errors"
+	"strings"
+	"unicode"
+)
+
+// EndOfLine 表示换行符的类型
+type EndOfLine int
+
+const (
+	// EndOfLineLF 使用"\n"换行
+	EndOfLineLF EndOfLine = iota
+	// EndOfLineCRLF 使用"\r\n"换行
+	EndOfLineCRLF
+)
+
+// String 获取换行符类型的名称
+func (e EndOfLine) String() string {
+	if e == EndOfLineCRLF {
+		return "CRLF"
+	}
+	return "LF"
+}
+
+// Sequence 获取换行符对应的字符序列
+func (e EndOfLine) Sequence() string {
+	if e == EndOfLineCRLF {
+		return "\r\n"
+	}
+	return "\n"
+}
+
+// SaveOptions 描述保存之前对文本进行的转换
+type SaveOptions struct {
+	// TrimTrailingWhitespace 是否删除行尾的空白字符
+	TrimTrailingWhitespace bool
+	// KeepMarkdownHardBreaks 删除行尾空白时，是否保留Markdown的硬换行（行尾两个以上的空格）
+	KeepMarkdownHardBreaks bool
+	// InsertFinalNewline 是否在文本末尾没有换行符时插入换行符
+	InsertFinalNewline bool
+	// RemoveFinalNewline 是否删除文本末尾的换行符（包括末尾的所有空行）
+	RemoveFinalNewline bool
+	// TrimFinalNewlines 是否把文本末尾的多个空行合并，只保留一个换行符
+	TrimFinalNewlines bool
+	// NormalizeEndOfLine 是否把所有换行符统一为EndOfLine
+	NormalizeEndOfLine bool
+	// EndOfLine 统一后的换行符类型，也是插入末尾换行符时使用的类型
+	EndOfLine EndOfLine
+}
+
+// GetEndOfLine 获取文本中使用最多的换行符类型，没有换行符时返回EndOfLineLF
+func (tb *TextBuffer) GetEndOfLine() EndOfLine {
+	tb.mutex.RLock()
+	defer tb.mutex.RUnlock()
+	return tb.getEndOfLine()
+}
+
+// getEndOfLine 获取文本中使用最多的换行符类型
+// 调用方必须持有锁
+func (tb *TextBuffer) getEndOfLine() EndOfLine {
+	crlf := 0
+	lf := 0
+	for lineIndex := 0; lineIndex < tb.gapBuffer.GetLineCount(); lineIndex++ {
+		switch lineEnding(tb.gapBuffer.GetLineContent(lineIndex)) {
+		case "\r\n":
+			crlf++
+		case "\n":
+			lf++
+		}
+	}
+	if crlf > lf {
+		return EndOfLineCRLF
+	}
+	return EndOfLineLF
+}
+
+// lineEnding 获取行末尾的换行符，最后一行没有换行符时返回空字符串
+func lineEnding(line string) string {
+	switch {
+	case strings.HasSuffix(line, "\r\n"):
+		return "\r\n"
+	case strings.HasSuffix(line, "\n"):
+		return "\n"
+	}
+	return ""
+}
+
+// isTrailingWhitespace 判断字符是否是可以从行尾删除的空白字符
+func isTrailingWhitespace(ch rune) bool {
+	return ch != '\r' && ch != '\n' && unicode.IsSpace(ch)
+}
+
+// trimTrailingWhitespace 删除一行内容（不包括换行符）末尾的空白字符
+func trimTrailingWhitespace(content string, keepMarkdownHardBreaks bool) string {
+	trimmed := strings.TrimRightFunc(content, isTrailingWhitespace)
+	if keepMarkdownHardBreaks && trimmed != "" {
+		// Markdown的硬换行是行尾两个以上的空格
+		if trailing := content[len(trimmed):]; len(trailing) >= 2 && strings.Trim(trailing, " ") == "" {
+			return content
+		}
+	}
+	return trimmed
+}
+
+// ApplySaveTransforms 在保存之前对文本进行转换，所有转换作为一个可撤销的操作应用
+// 每一行的修改都是独立的编辑，跟踪的选区会随编辑调整
+func (tb *TextBuffer) ApplySaveTransforms(options SaveOptions) error {
+	if err := options.validate(); err != nil {
+		return err
+	}
+
+	tb.mutex.Lock()
+	defer tb.unlock()
+	tb.applySaveTransforms(options)
+	return nil
+}
+
+// validate 检查保存选项是否互相冲突
+func (options SaveOptions) validate() error {
+	if options.InsertFinalNewline && options.RemoveFinalNewline {
+		return errors.New("conflicting final newline options")
+	}
+	return nil
+}
+
+// applySaveTransforms 对文本进行保存前的转换
+// 调用方必须持有锁
+func (tb *TextBuffer) applySaveTransforms(options SaveOptions) {
+	type line struct {
+		content string
+		eol     string
+	}
+
+	lineCount := tb.gapBuffer.GetLineCount()
+	oldLines := make([]line, lineCount)
+	newLines := make([]line, lineCount)
+	for lineIndex := range oldLines {
+		text := tb.gapBuffer.GetLineContent(lineIndex)
+		eol := lineEnding(text)
+		oldLines[lineIndex] = line{content: strings.TrimSuffix(text, eol), eol: eol}
+	}
+	copy(newLines, oldLines)
+
+	endOfLine := tb.getEndOfLine()
+	if options.NormalizeEndOfLine {
+		endOfLine = options.EndOfLine
+	}
+
+	for lineIndex := range newLines {
+		if options.TrimTrailingWhitespace {
+			newLines[lineIndex].content = trimTrailingWhitespace(newLines[lineIndex].content, options.KeepMarkdownHardBreaks)
+		}
+		if options.NormalizeEndOfLine && newLines[lineIndex].eol != "" {
+			newLines[lineIndex].eol = endOfLine.Sequence()
+		}
+	}
+
+	// 找到最后一个非空行，之后的都是末尾的空行
+	lastNonEmpty := len(newLines) - 1
+	for lastNonEmpty >= 0 && newLines[lastNonEmpty].content == "" {
+		lastNonEmpty--
+	}
+
+	switch {
+	case options.RemoveFinalNewline:
+		for lineIndex := lastNonEmpty + 1; lineIndex < len(newLines); lineIndex++ {
+			newLines[lineIndex] = line{}
+		}
+		if lastNonEmpty >= 0 {
+			newLines[lastNonEmpty].eol = ""
+		}
+	case options.TrimFinalNewlines && lastNonEmpty+1 < len(newLines):
+		// 最后一个非空行保留换行符，之后的空行全部删除
+		for lineIndex := lastNonEmpty + 1; lineIndex < len(newLines); lineIndex++ {
+			newLines[lineIndex] = line{}
+		}
+		if lastNonEmpty >= 0 && newLines[lastNonEmpty].eol == "" {
+			newLines[lastNonEmpty].eol = endOfLine.Sequence()
+		}
+	}
+
+	if options.InsertFinalNewline && lastNonEmpty >= 0 {
+		last := len(newLines) - 1
+		for newLines[last].content == "" && newLines[last].eol == "" && last > lastNonEmpty {
+			last--
+		}
+		if newLines[last].eol == "" {
+			newLines[last].eol = endOfLine.Sequence()
+		}
+	}
+
+	// 从后往前生成每一行的编辑，这样每个编辑的偏移量都不受之前编辑的影响
+	var edits []Edit
+	for lineIndex := lineCount - 1; lineIndex >= 0; lineIndex-- {
+		oldText := oldLines[lineIndex].content + oldLines[lineIndex].eol
+		newText := newLines[lineIndex].content + newLines[lineIndex].eol
+		if oldText == newText {
+			continue
+		}
+		start, _ := tb.gapBuffer.lineStartAndLength(lineIndex)
+		edit := minimalEdit(oldText, newText)
+		edit.Offset += start
+
+		// 相邻行的删除合并为一个编辑
+		if n := len(edits); n > 0 && edit.NewText == "" && edits[n-1].NewText == "" && edit.OldEnd() == edits[n-1].Offset {
+			edits[n-1].Offset = edit.Offset
+			edits[n-1].OldText = edit.OldText + edits[n-1].OldText
+			continue
+		}
+		edits = append(edits, edit)
+	}
+
+	tb.pushOperation(&TextOperation{
+		Type:   operationTypeOf(edits),
+		Label:  "Save Transforms",
+		Source: EditSourceFormatter,
+		Edits:  edits,
+	})
+}
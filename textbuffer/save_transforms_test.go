@@ -0,0 +1,67 @@
+package textbuffer
+
+import (
+	"testing"
+)
+
+func TestApplySaveTransforms(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		options  SaveOptions
+		expected string
+	}{
+		{"trim whitespace", "a  \nb\t\n c \n", SaveOptions{TrimTrailingWhitespace: true}, "a\nb\n c\n"},
+		{"markdown hard break", "line  \nnext \n   \n", SaveOptions{TrimTrailingWhitespace: true, KeepMarkdownHardBreaks: true}, "line  \nnext\n\n"},
+		{"insert final newline", "a\nb", SaveOptions{InsertFinalNewline: true}, "a\nb\n"},
+		{"insert final newline crlf", "a\r\nb", SaveOptions{InsertFinalNewline: true}, "a\r\nb\r\n"},
+		{"insert into empty text", "", SaveOptions{InsertFinalNewline: true}, ""},
+		{"remove final newline", "a\nb\n\n\n", SaveOptions{RemoveFinalNewline: true}, "a\nb"},
+		{"trim final newlines", "a\n\n\n", SaveOptions{TrimFinalNewlines: true}, "a\n"},
+		{"trim final newlines after whitespace", "a\n  \n\t\n", SaveOptions{TrimTrailingWhitespace: true, TrimFinalNewlines: true}, "a\n"},
+		{"normalize to crlf", "a\nb\r\nc", SaveOptions{NormalizeEndOfLine: true, EndOfLine: EndOfLineCRLF}, "a\r\nb\r\nc"},
+		{"normalize to lf", "a\r\nb\r\n", SaveOptions{NormalizeEndOfLine: true, EndOfLine: EndOfLineLF, InsertFinalNewline: true}, "a\nb\n"},
+	}
+
+	for _, test := range tests {
+		buffer := NewTextBufferWithText(test.text)
+		if err := buffer.ApplySaveTransforms(test.options); err != nil {
+			t.Fatalf("%s: ApplySaveTransforms failed: %v", test.name, err)
+		}
+		if buffer.GetText() != test.expected {
+			t.Errorf("%s: expected %q, got %q", test.name, test.expected, buffer.GetText())
+		}
+	}
+
+	// 所有转换是一个撤销步骤，跟踪的选区随编辑调整
+	buffer := NewTextBufferWithText("foo  \r\nbar  \r\n\r\n\r\n")
+	buffer.SetSelections([]Selection{NewCursor(Position{Line: 1, Column: 3})})
+	err := buffer.ApplySaveTransforms(SaveOptions{
+		TrimTrailingWhitespace: true,
+		TrimFinalNewlines:      true,
+		NormalizeEndOfLine:     true,
+		EndOfLine:              EndOfLineLF,
+	})
+	if err != nil {
+		t.Fatalf("ApplySaveTransforms failed: %v", err)
+	}
+	if buffer.GetText() != "foo\nbar\n" {
+		t.Errorf("Expected %q, got %q", "foo\nbar\n", buffer.GetText())
+	}
+	if selections := buffer.GetSelections(); !selections[0].Active.Equals(Position{Line: 1, Column: 3}) {
+		t.Errorf("Expected cursor at (1, 3), got %v", selections)
+	}
+	if buffer.UndoLabel() != "Save Transforms" {
+		t.Errorf("Expected undo label 'Save Transforms', got '%s'", buffer.UndoLabel())
+	}
+	if _, err := buffer.Undo(); err != nil || buffer.GetText() != "foo  \r\nbar  \r\n\r\n\r\n" {
+		t.Errorf("Expected undo to restore the original text, got %q (%v)", buffer.GetText(), err)
+	}
+
+	if buffer.GetEndOfLine() != EndOfLineCRLF {
+		t.Errorf("Expected CRLF, got %v", buffer.GetEndOfLine())
+	}
+	if err := buffer.ApplySaveTransforms(SaveOptions{InsertFinalNewline: true, RemoveFinalNewline: true}); err == nil {
+		t.Errorf("Expected error for conflicting options")
+	}
+}
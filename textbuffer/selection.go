@@ -0,0 +1,138 @@
+package textbuffer
+
+import (
+	"unicode/utf8"
+)
+
+// Selection 表示文本中的一个选区，由锚点和活动位置组成
+// 锚点是开始选择的位置，活动位置是光标当前所在的位置，
+// 当锚点和活动位置相同时，选区退化为一个光标
+type Selection struct {
+	// Anchor 选区的锚点
+	Anchor Position
+	// Active 选区的活动位置（光标位置）
+	Active Position
+}
+
+// NewSelection 创建一个新的Selection
+func NewSelection(anchor, active Position) Selection {
+	return Selection{
+		Anchor: anchor,
+		Active: active,
+	}
+}
+
+// NewCursor 创建一个位于指定位置的空选区
+func NewCursor(position Position) Selection {
+	return Selection{
+		Anchor: position,
+		Active: position,
+	}
+}
+
+// IsEmpty 判断选区是否为空（即只是一个光标）
+func (s Selection) IsEmpty() bool {
+	return s.Anchor.Equals(s.Active)
+}
+
+// IsReversed 判断选区是否是反向的（活动位置在锚点之前）
+func (s Selection) IsReversed() bool {
+	return s.Active.IsBefore(s.Anchor)
+}
+
+// Range 获取选区覆盖的范围，起始位置总是在结束位置之前
+func (s Selection) Range() Range {
+	if s.IsReversed() {
+		return NewRange(s.Active, s.Anchor)
+	}
+	return NewRange(s.Anchor, s.Active)
+}
+
+// Equals 判断两个选区是否相等
+func (s Selection) Equals(other Selection) bool {
+	return s.Anchor.Equals(other.Anchor) && s.Active.Equals(other.Active)
+}
+
+// cloneSelections 复制选区列表，避免调用方修改已记录的选区
+func cloneSelections(selections []Selection) []Selection {
+	if selections == nil {
+		return nil
+	}
+	result := make([]Selection, len(selections))
+	copy(result, selections)
+	return result
+}
+
+// selectionOffsets 是用偏移量表示的选区，编辑时可以直接平移
+type selectionOffsets struct {
+	anchor int
+	active int
+}
+
+// transformOffset 计算偏移量在应用编辑之后的新位置
+// 编辑之前的偏移量保持不变，编辑之后的偏移量随编辑平移，
+// 被替换区域内的偏移量保持在替换后的文本内
+func transformOffset(offset int, edit Edit) int {
+	if offset <= edit.Offset {
+		return offset
+	}
+	oldEnd := edit.OldEnd()
+	if offset >= oldEnd {
+		return offset + utf8.RuneCountInString(edit.NewText) - (oldEnd - edit.Offset)
+	}
+	if newEnd := edit.NewEnd(); offset > newEnd {
+		return newEnd
+	}
+	return offset
+}
+
+// SetSelections 设置文本缓冲区跟踪的选区，之后的编辑会自动调整这些选区
+func (tb *TextBuffer) SetSelections(selections []Selection) {
+	tb.mutex.Lock()
+	defer tb.unlock()
+	tb.setSelections(selections)
+	tb.pruneAutoClosed(selections)
+}
+
+// GetSelections 获取文本缓冲区跟踪的选区
+func (tb *TextBuffer) GetSelections() []Selection {
+	tb.mutex.RLock()
+	defer tb.mutex.RUnlock()
+	return tb.getSelections()
+}
+
+// setSelections 设置跟踪的选区
+// 调用方必须持有写锁
+func (tb *TextBuffer) setSelections(selections []Selection) {
+	tb.selections = make([]selectionOffsets, len(selections))
+	for i, selection := range selections {
+		tb.selections[i] = selectionOffsets{
+			anchor: tb.gapBuffer.GetOffsetAt(selection.Anchor),
+			active: tb.gapBuffer.GetOffsetAt(selection.Active),
+		}
+	}
+}
+
+// getSelections 获取跟踪的选区
+// 调用方必须持有锁
+func (tb *TextBuffer) getSelections() []Selection {
+	selections := make([]Selection, len(tb.selections))
+	for i, selection := range tb.selections {
+		selections[i] = NewSelection(
+			tb.gapBuffer.GetPositionAt(selection.anchor),
+			tb.gapBuffer.GetPositionAt(selection.active),
+		)
+	}
+	return selections
+}
+
+// transformSelections 根据已应用的编辑调整跟踪的选区
+// 调用方必须持有写锁
+func (tb *TextBuffer) transformSelections(edit Edit) {
+	for i, selection := range tb.selections {
+		tb.selections[i] = selectionOffsets{
+			anchor: transformOffset(selection.anchor, edit),
+			active: transformOffset(selection.active, edit),
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package textbuffer
+
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrOperationNotFound 表示撤销栈中找不到指定的操作
+	ErrOperationNotFound = errors.New("operation not found")
+	// ErrUndoConflict 表示后续的编辑与要撤销的操作重叠，无法单独撤销
+	ErrUndoConflict = errors.New("undo conflict")
+)
+
+// UndoOperation 单独撤销历史中的某个操作，保留在它之后的所有操作
+// 该操作的逆编辑会依次变换到之后的每个操作之上，然后作为一个新的可撤销操作应用
+// 如果之后的编辑与该操作修改的区域重叠，返回ErrUndoConflict
+func (tb *TextBuffer) UndoOperation(id uint64) error {
+	tb.mutex.Lock()
+	defer tb.unlock()
+
+	operations := tb.undoStack.UndoOperations()
+	index := -1
+	for i, operation := range operations {
+		if operation.ID == id {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return fmt.Errorf("%w: %d", ErrOperationNotFound, id)
+	}
+
+	target := operations[index]
+	inverse := invertEdits(target.Edits)
+
+	// 将逆编辑变换到之后的每个操作之上
+	for _, later := range operations[index+1:] {
+		for _, edit := range later.Edits {
+			var ok bool
+			inverse, _, ok = transformEdits(inverse, edit)
+			if !ok {
+				return fmt.Errorf("%w: operation %d overlaps operation %d", ErrUndoConflict, later.ID, id)
+			}
+		}
+	}
+
+	// 应用变换后的逆编辑，同时确认要恢复的区域仍然是该操作插入的文本
+	for i, edit := range inverse {
+		if tb.gapBuffer.getTextInOffsets(edit.Offset, edit.OldEnd()) != edit.OldText {
+			// 回滚已经应用的编辑
+			for j := i - 1; j >= 0; j-- {
+				tb.applyEdit(inverse[j].Inverse())
+			}
+			return fmt.Errorf("%w: text of operation %d has changed", ErrUndoConflict, id)
+		}
+		tb.applyEdit(edit)
+	}
+
+	tb.recordOperation(&TextOperation{
+		Type:  operationTypeOf(inverse),
+		Label: "Undo " + target.Label,
+		Edits: inverse,
+	})
+
+	return nil
+}
@@ -0,0 +1,72 @@
+package textbuffer
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTextBufferUndoOperation(t *testing.T) {
+	buffer := NewTextBufferWithText("line one\nline two")
+
+	_ = buffer.Insert(Position{Line: 0, Column: 0}, "// ")
+	_ = buffer.Replace(NewRange(Position{Line: 1, Column: 5}, Position{Line: 1, Column: 8}), "2")
+	_ = buffer.Insert(Position{Line: 1, Column: 0}, "> ")
+	if buffer.GetText() != "// line one\n> line 2" {
+		t.Fatalf("Unexpected text '%s'", buffer.GetText())
+	}
+
+	// 单独撤销第一个操作，保留之后的操作
+	history := buffer.History()
+	if err := buffer.UndoOperation(history[0].ID); err != nil {
+		t.Fatalf("UndoOperation failed: %v", err)
+	}
+	if buffer.GetText() != "line one\n> line 2" {
+		t.Errorf("Expected 'line one\\n> line 2', got '%s'", buffer.GetText())
+	}
+	if buffer.UndoLabel() != "Undo Insert" {
+		t.Errorf("Expected undo label 'Undo Insert', got '%s'", buffer.UndoLabel())
+	}
+
+	// 单独撤销替换操作，它的位置已经被之后的插入移动
+	if err := buffer.UndoOperation(history[1].ID); err != nil {
+		t.Fatalf("UndoOperation failed: %v", err)
+	}
+	if buffer.GetText() != "line one\n> line two" {
+		t.Errorf("Expected 'line one\\n> line two', got '%s'", buffer.GetText())
+	}
+
+	// 选择性撤销本身也是一个可撤销的操作
+	if _, err := buffer.Undo(); err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+	if buffer.GetText() != "line one\n> line 2" {
+		t.Errorf("Expected 'line one\\n> line 2', got '%s'", buffer.GetText())
+	}
+}
+
+func TestTextBufferUndoOperationConflict(t *testing.T) {
+	buffer := NewTextBuffer()
+
+	_ = buffer.Insert(Position{Line: 0, Column: 0}, "hello world")
+	_ = buffer.Replace(NewRange(Position{Line: 0, Column: 0}, Position{Line: 0, Column: 5}), "HELLO")
+
+	// 之后的编辑与要撤销的区域重叠
+	history := buffer.History()
+	err := buffer.UndoOperation(history[0].ID)
+	if !errors.Is(err, ErrUndoConflict) {
+		t.Errorf("Expected ErrUndoConflict, got %v", err)
+	}
+	if buffer.GetText() != "HELLO world" {
+		t.Errorf("Expected text to be unchanged, got '%s'", buffer.GetText())
+	}
+	if len(buffer.History()) != 2 {
+		t.Errorf("Expected history to be unchanged, got %d entries", len(buffer.History()))
+	}
+
+	// 已经撤销的操作无法单独撤销
+	_, _ = buffer.Undo()
+	err = buffer.UndoOperation(history[1].ID)
+	if !errors.Is(err, ErrOperationNotFound) {
+		t.Errorf("Expected ErrOperationNotFound, got %v", err)
+	}
+}
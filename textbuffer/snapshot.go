@@ -0,0 +1,78 @@
+package textbuffer
+
+import (
+	"strings"
+)
+
+// Snapshot 是文本缓冲区在某一时刻的只读快照，可以在其他goroutine中安全地使用
+type Snapshot struct {
+	// 创建快照时文本缓冲区的版本号
+	versionID int
+	// 快照的文本
+	text string
+	// 快照的所有行（包括换行符）
+	lines []string
+}
+
+// newSnapshot 根据文本创建快照
+func newSnapshot(versionID int, text string) *Snapshot {
+	lines := strings.SplitAfter(text, "\n")
+	if len(lines) > 1 && lines[len(lines)-1] == "" {
+		// 以换行符结尾的文本不包含额外的空行，与GapBuffer的行划分保持一致
+		lines = lines[:len(lines)-1]
+	}
+	return &Snapshot{
+		versionID: versionID,
+		text:      text,
+		lines:     lines,
+	}
+}
+
+// NewSnapshotFromText 根据文本创建一个快照，版本号为0
+func NewSnapshotFromText(text string) *Snapshot {
+	return newSnapshot(0, text)
+}
+
+// VersionID 获取创建快照时文本缓冲区的版本号
+func (s *Snapshot) VersionID() int {
+	return s.versionID
+}
+
+// GetText 获取快照的文本
+func (s *Snapshot) GetText() string {
+	return s.text
+}
+
+// GetLineCount 获取快照的行数
+func (s *Snapshot) GetLineCount() int {
+	return len(s.lines)
+}
+
+// GetLineContent 获取快照中指定行的内容（包括换行符）
+func (s *Snapshot) GetLineContent(lineIndex int) string {
+	if lineIndex < 0 || lineIndex >= len(s.lines) {
+		return ""
+	}
+	return s.lines[lineIndex]
+}
+
+// GetLines 获取快照的所有行（包括换行符）
+func (s *Snapshot) GetLines() []string {
+	lines := make([]string, len(s.lines))
+	copy(lines, s.lines)
+	return lines
+}
+
+// CreateSnapshot 创建文本缓冲区当前内容的快照
+func (tb *TextBuffer) CreateSnapshot() *Snapshot {
+	tb.mutex.RLock()
+	defer tb.mutex.RUnlock()
+	return newSnapshot(tb.versionID, tb.gapBuffer.GetText())
+}
+
+// GetVersionID 获取文本缓冲区的版本号，每次修改文本后版本号都会增加
+func (tb *TextBuffer) GetVersionID() int {
+	tb.mutex.RLock()
+	defer tb.mutex.RUnlock()
+	return tb.versionID
+}
@@ -3,6 +3,7 @@ package textbuffer
 import (
 	"errors"
 	"sync"
+	"time"
 )
 
 // TextBuffer 是一个文本缓冲区，用于存储和操作文本
@@ -13,6 +14,55 @@ type TextBuffer struct {
 	mutex sync.RWMutex
 	// 撤销/重做栈
 	undoStack *UndoStack
+	// 版本号，每次修改文本后增加
+	versionID int
+	// 跟踪的选区，编辑时自动调整
+	selections []selectionOffsets
+	// 输入时自动插入的结束字符的偏移量，编辑时自动调整，只有这些字符可以被输入跳过
+	autoClosed []int
+	// 命名检查点
+	checkpoints map[string]*checkpoint
+	// 直接设置的语言配置，优先于语言标识对应的配置
+	languageConfiguration *LanguageConfiguration
+	// 语言标识，用于从注册表中查找语言配置
+	languageID string
+	// 查找语言配置的注册表，为空时使用DefaultLanguageRegistry
+	languageRegistry *LanguageRegistry
+	// 按行增量更新的索引
+	lineObservers []lineObserver
+	// 括号索引
+	brackets *bracketIndex
+	// 折叠索引
+	folding *foldingIndex
+	// 分词结果
+	tokens *tokenStore
+	// 输入时使用的缩进方式
+	indentOptions IndentOptions
+	// 关联的文件在最近一次加载或保存时的状态
+	fileInfo *FileInfo
+	// 最近一次加载或保存时撤销栈顶的操作标识，用于判断文本是否被修改
+	// 应用了不推入撤销栈的编辑之后为unsavedOperationID
+	savedOperationID uint64
+	// 保存文件时使用的编码，加载文件时检测
+	encoding FileEncoding
+	// 折叠范围的计算选项
+	foldingOptions FoldingOptions
+	// 监听器互斥锁，注册和移除监听器时使用
+	listenerMutex sync.Mutex
+	// 撤销/重做状态变化的监听器
+	undoStateListeners listenerList[UndoState]
+	// 文本内容变化的监听器
+	contentChangeListeners listenerList[ContentChangeEvent]
+	// 正在合并编辑的内容变化事件，释放写锁时清空
+	pendingContentChange *ContentChangeEvent
+	// 分词结果变化的监听器
+	tokensChangedListeners listenerList[TokensChangedEvent]
+	// 正在合并的分词结果变化事件，释放写锁时清空
+	pendingTokensChange *TokensChangedEvent
+	// 文本与文件一致的监听器
+	savedListeners listenerList[SavedEvent]
+	// 等待在释放写锁后触发的事件
+	pendingEvents []func()
 }
 
 // NewTextBuffer 创建一个新的TextBuffer
@@ -45,7 +95,7 @@ func (tb *TextBuffer) GetLength() int {
 	return tb.gapBuffer.GetLength()
 }
 
-// GetLineCount 获取行数
+// GetLineCount 获取行数，文本末尾的换行符之后不算作新的一行
 func (tb *TextBuffer) GetLineCount() int {
 	tb.mutex.RLock()
 	defer tb.mutex.RUnlock()
@@ -67,6 +117,7 @@ func (tb *TextBuffer) GetLines() []string {
 }
 
 // GetPositionAt 获取指定偏移量对应的位置
+// 文本以换行符结尾时，文本末尾的位置是{GetLineCount(), 0}，参见GapBuffer.GetPositionAt
 func (tb *TextBuffer) GetPositionAt(offset int) Position {
 	tb.mutex.RLock()
 	defer tb.mutex.RUnlock()
@@ -87,182 +138,274 @@ func (tb *TextBuffer) GetTextInRange(r Range) string {
 	return tb.gapBuffer.GetTextInRange(r)
 }
 
+// UndoResult 表示撤销或重做操作的结果
+type UndoResult struct {
+	// Selections 操作完成后需要恢复的选区
+	Selections []Selection
+	// ChangedRanges 操作完成后发生变化的文本范围
+	ChangedRanges []Range
+}
+
 // Insert 在指定位置插入文本
 func (tb *TextBuffer) Insert(position Position, text string) error {
+	return tb.InsertWithSelections(position, text, nil, nil)
+}
+
+// InsertWithSelections 在指定位置插入文本，并记录操作前后的选区用于撤销/重做
+func (tb *TextBuffer) InsertWithSelections(position Position, text string, beforeSelections, afterSelections []Selection) error {
 	if text == "" {
 		return nil
 	}
 
-	tb.mutex.Lock()
-	defer tb.mutex.Unlock()
-
-	offset := tb.gapBuffer.GetOffsetAt(position)
-
-	// 记录操作用于撤销
-	tb.undoStack.Push(&TextOperation{
-		Type:     OperationInsert,
-		Position: position,
-		Text:     text,
-		OldText:  "",
-	})
-
-	// 执行插入操作
-	tb.gapBuffer.Insert(offset, text)
-
-	return nil
+	return tb.ApplyEdits([]EditOperation{{Range: NewRange(position, position), Text: text}}, beforeSelections, afterSelections)
 }
 
 // Delete 删除指定范围的文本
 func (tb *TextBuffer) Delete(r Range) error {
-	tb.mutex.Lock()
-	defer tb.mutex.Unlock()
+	return tb.DeleteWithSelections(r, nil, nil)
+}
 
+// DeleteWithSelections 删除指定范围的文本，并记录操作前后的选区用于撤销/重做
+func (tb *TextBuffer) DeleteWithSelections(r Range, beforeSelections, afterSelections []Selection) error {
+	tb.mutex.RLock()
 	startOffset := tb.gapBuffer.GetOffsetAt(r.Start)
 	endOffset := tb.gapBuffer.GetOffsetAt(r.End)
+	tb.mutex.RUnlock()
 
 	if startOffset >= endOffset {
 		return errors.New("invalid range")
 	}
 
-	// 获取要删除的文本
-	oldText := tb.gapBuffer.GetTextInRange(r)
+	return tb.ApplyEdits([]EditOperation{{Range: r, Text: ""}}, beforeSelections, afterSelections)
+}
+
+// Replace 替换指定范围的文本
+func (tb *TextBuffer) Replace(r Range, text string) error {
+	return tb.ReplaceWithSelections(r, text, nil, nil)
+}
+
+// ReplaceWithSelections 替换指定范围的文本，并记录操作前后的选区用于撤销/重做
+func (tb *TextBuffer) ReplaceWithSelections(r Range, text string, beforeSelections, afterSelections []Selection) error {
+	return tb.ApplyEdits([]EditOperation{{Range: r, Text: text}}, beforeSelections, afterSelections)
+}
 
-	// 记录操作用于撤销
-	tb.undoStack.Push(&TextOperation{
-		Type:     OperationDelete,
-		Position: r.Start,
-		Text:     "",
-		OldText:  oldText,
+// ApplyEdits 将一组编辑作为一个操作应用到文本缓冲区
+// 所有编辑的范围都基于编辑前的文档，并且不能相互重叠；整个操作可以一次撤销
+func (tb *TextBuffer) ApplyEdits(operations []EditOperation, beforeSelections, afterSelections []Selection) error {
+	return tb.ApplyEditsWithOptions(operations, EditOptions{
+		BeforeSelections: beforeSelections,
+		AfterSelections:  afterSelections,
 	})
+}
+
+// ApplyEditsWithOptions 将一组编辑作为一个操作应用到文本缓冲区，并记录操作的描述和来源
+// options.SkipUndo为true时编辑不推入撤销栈
+func (tb *TextBuffer) ApplyEditsWithOptions(operations []EditOperation, options EditOptions) error {
+	tb.mutex.Lock()
+	defer tb.unlock()
+
+	edits, err := tb.gapBuffer.resolveEdits(operations)
+	if err != nil {
+		return err
+	}
 
-	// 执行删除操作
-	tb.gapBuffer.Delete(startOffset, endOffset)
+	if options.SkipUndo {
+		tb.applyUntracked(edits)
+		if options.AfterSelections != nil {
+			tb.setSelections(options.AfterSelections)
+		}
+		return nil
+	}
+
+	tb.pushOperation(&TextOperation{
+		Type:             operationTypeOf(edits),
+		Label:            options.Label,
+		Source:           options.Source,
+		Edits:            edits,
+		BeforeSelections: cloneSelections(options.BeforeSelections),
+		AfterSelections:  cloneSelections(options.AfterSelections),
+	})
 
 	return nil
 }
 
-// Replace 替换指定范围的文本
-func (tb *TextBuffer) Replace(r Range, text string) error {
-	tb.mutex.Lock()
-	defer tb.mutex.Unlock()
+// operationTypeOf 根据编辑内容推断操作类型
+func operationTypeOf(edits []Edit) OperationType {
+	if len(edits) == 1 {
+		switch {
+		case edits[0].OldText == "":
+			return OperationInsert
+		case edits[0].NewText == "":
+			return OperationDelete
+		}
+	}
+	return OperationReplace
+}
 
-	startOffset := tb.gapBuffer.GetOffsetAt(r.Start)
-	endOffset := tb.gapBuffer.GetOffsetAt(r.End)
+// pushOperation 应用一个操作并将其推入撤销栈，所有修改文本的方法都通过它完成
+// 调用方必须持有写锁
+func (tb *TextBuffer) pushOperation(operation *TextOperation) {
+	if len(operation.Edits) == 0 {
+		return
+	}
 
-	if startOffset > endOffset {
-		return errors.New("invalid range")
+	// 没有提供选区时，记录跟踪的选区用于撤销/重做
+	if operation.BeforeSelections == nil && len(tb.selections) > 0 {
+		operation.BeforeSelections = tb.getSelections()
 	}
 
-	// 获取要替换的文本
-	oldText := tb.gapBuffer.GetTextInRange(r)
+	tb.applyEdits(operation.Edits)
+	if operation.AfterSelections != nil {
+		tb.setSelections(operation.AfterSelections)
+	} else if len(tb.selections) > 0 {
+		operation.AfterSelections = tb.getSelections()
+	}
+	tb.recordOperation(operation)
+}
 
-	// 记录操作用于撤销
-	tb.undoStack.Push(&TextOperation{
-		Type:     OperationReplace,
-		Position: r.Start,
-		Text:     text,
-		OldText:  oldText,
-	})
+// recordOperation 将一个已经应用的操作推入撤销栈
+// 调用方必须持有写锁
+func (tb *TextBuffer) recordOperation(operation *TextOperation) {
+	if operation.Label == "" {
+		operation.Label = defaultLabel(operation.Type)
+	}
+	if operation.Timestamp.IsZero() {
+		operation.Timestamp = time.Now()
+	}
 
-	// 执行替换操作
-	tb.gapBuffer.Delete(startOffset, endOffset)
-	tb.gapBuffer.Insert(startOffset, text)
+	tb.undoStack.Push(operation)
+	tb.queueUndoStateChanged()
+}
 
-	return nil
+// unsavedOperationID 是不会分配给任何操作的标识，文本与文件不再能通过撤销变为一致
+const unsavedOperationID = ^uint64(0)
+
+// applyUntracked 应用一组不推入撤销栈的编辑，并把撤销栈和重做栈变换到编辑之后的文本上
+// 调用方必须持有写锁
+func (tb *TextBuffer) applyUntracked(edits []Edit) {
+	if len(edits) == 0 {
+		return
+	}
+	for _, edit := range edits {
+		tb.applyEdit(edit)
+		tb.undoStack.rebase(edit)
+	}
+	tb.savedOperationID = unsavedOperationID
+	tb.queueUndoStateChanged()
+}
+
+// applyEdits 按顺序应用一组编辑
+// 调用方必须持有写锁
+func (tb *TextBuffer) applyEdits(edits []Edit) {
+	for _, edit := range edits {
+		tb.applyEdit(edit)
+	}
 }
 
-// Undo 撤销上一次操作
-func (tb *TextBuffer) Undo() error {
+// applyEdit 应用一个编辑，更新按行计算的索引，并调整跟踪的选区
+// 调用方必须持有写锁
+func (tb *TextBuffer) applyEdit(edit Edit) {
+	tb.notifyLinesChanged(edit)
+	tb.gapBuffer.applyEdit(edit)
+	tb.versionID++
+	tb.transformSelections(edit)
+	tb.transformAutoClosed(edit)
+	tb.queueContentChanged(edit)
+}
+
+// Undo 撤销上一次操作，返回需要恢复的选区和发生变化的范围
+func (tb *TextBuffer) Undo() (*UndoResult, error) {
 	tb.mutex.Lock()
-	defer tb.mutex.Unlock()
+	defer tb.unlock()
 
 	operation, err := tb.undoStack.Undo()
 	if err != nil {
-		return err
+		return nil, err
 	}
+	tb.queueUndoStateChanged()
 
-	switch operation.Type {
-	case OperationInsert:
-		// 撤销插入操作，需要删除插入的文本
-		startOffset := tb.gapBuffer.GetOffsetAt(operation.Position)
-		endOffset := startOffset + len([]rune(operation.Text))
-		tb.gapBuffer.Delete(startOffset, endOffset)
-	case OperationDelete:
-		// 撤销删除操作，需要重新插入删除的文本
-		offset := tb.gapBuffer.GetOffsetAt(operation.Position)
-		tb.gapBuffer.Insert(offset, operation.OldText)
-	case OperationReplace:
-		// 撤销替换操作，需要恢复原来的文本
-		startOffset := tb.gapBuffer.GetOffsetAt(operation.Position)
-		endOffset := startOffset + len([]rune(operation.Text))
-		tb.gapBuffer.Delete(startOffset, endOffset)
-		tb.gapBuffer.Insert(startOffset, operation.OldText)
+	// 按相反顺序应用逆编辑，恢复到操作前的文本
+	inverse := invertEdits(operation.Edits)
+	tb.applyEdits(inverse)
+	if operation.BeforeSelections != nil {
+		tb.setSelections(operation.BeforeSelections)
 	}
 
-	return nil
+	return &UndoResult{
+		Selections:    cloneSelections(operation.BeforeSelections),
+		ChangedRanges: tb.changedRanges(inverse),
+	}, nil
 }
 
-// Redo 重做上一次撤销的操作
-func (tb *TextBuffer) Redo() error {
+// Redo 重做上一次撤销的操作，返回需要恢复的选区和发生变化的范围
+func (tb *TextBuffer) Redo() (*UndoResult, error) {
 	tb.mutex.Lock()
-	defer tb.mutex.Unlock()
+	defer tb.unlock()
 
 	operation, err := tb.undoStack.Redo()
 	if err != nil {
-		return err
+		return nil, err
 	}
+	tb.queueUndoStateChanged()
 
-	switch operation.Type {
-	case OperationInsert:
-		// 重做插入操作
-		offset := tb.gapBuffer.GetOffsetAt(operation.Position)
-		tb.gapBuffer.Insert(offset, operation.Text)
-	case OperationDelete:
-		// 重做删除操作
-		startOffset := tb.gapBuffer.GetOffsetAt(operation.Position)
-		endOffset := startOffset + len([]rune(operation.OldText))
-		tb.gapBuffer.Delete(startOffset, endOffset)
-	case OperationReplace:
-		// 重做替换操作
-		startOffset := tb.gapBuffer.GetOffsetAt(operation.Position)
-		endOffset := startOffset + len([]rune(operation.OldText))
-		tb.gapBuffer.Delete(startOffset, endOffset)
-		tb.gapBuffer.Insert(startOffset, operation.Text)
+	tb.applyEdits(operation.Edits)
+	if operation.AfterSelections != nil {
+		tb.setSelections(operation.AfterSelections)
 	}
 
-	return nil
+	return &UndoResult{
+		Selections:    cloneSelections(operation.AfterSelections),
+		ChangedRanges: tb.changedRanges(operation.Edits),
+	}, nil
+}
+
+// changedRanges 计算一组已应用的编辑在当前文本中影响的范围
+func (tb *TextBuffer) changedRanges(edits []Edit) []Range {
+	offsetRanges := changedOffsetRanges(edits)
+	ranges := make([]Range, len(offsetRanges))
+	for i, r := range offsetRanges {
+		ranges[i] = NewRange(tb.gapBuffer.GetPositionAt(r.start), tb.gapBuffer.GetPositionAt(r.end))
+	}
+	return ranges
 }
 
 // Clear 清空文本缓冲区
 func (tb *TextBuffer) Clear() {
 	tb.mutex.Lock()
-	defer tb.mutex.Unlock()
-
-	// 记录操作用于撤销
-	tb.undoStack.Push(&TextOperation{
-		Type:     OperationDelete,
-		Position: Position{Line: 0, Column: 0},
-		Text:     "",
-		OldText:  tb.gapBuffer.GetText(),
-	})
+	defer tb.unlock()
+
+	if tb.gapBuffer.GetLength() == 0 {
+		return
+	}
 
-	// 清空行缓冲区
-	tb.gapBuffer.Clear()
+	tb.pushOperation(&TextOperation{
+		Type:  OperationDelete,
+		Label: "Clear",
+		Edits: []Edit{{
+			Offset:  0,
+			OldText: tb.gapBuffer.GetText(),
+			NewText: "",
+		}},
+	})
 }
 
 // SetText 设置整个文本内容
 func (tb *TextBuffer) SetText(text string) {
 	tb.mutex.Lock()
-	defer tb.mutex.Unlock()
-
-	// 记录操作用于撤销
-	tb.undoStack.Push(&TextOperation{
-		Type:     OperationReplace,
-		Position: Position{Line: 0, Column: 0},
-		Text:     text,
-		OldText:  tb.gapBuffer.GetText(),
-	})
+	defer tb.unlock()
+
+	oldText := tb.gapBuffer.GetText()
+	if oldText == text {
+		return
+	}
 
-	// 设置行缓冲区的文本
-	tb.gapBuffer.SetText(text)
+	tb.pushOperation(&TextOperation{
+		Type:  OperationReplace,
+		Label: "Set Text",
+		Edits: []Edit{{
+			Offset:  0,
+			OldText: oldText,
+			NewText: text,
+		}},
+	})
 }
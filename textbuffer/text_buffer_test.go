@@ -1,6 +1,7 @@
 package textbuffer
 
 import (
+	"math/rand"
 	"testing"
 )
 
@@ -42,7 +43,7 @@ func TestTextBuffer(t *testing.T) {
 	}
 
 	// 测试撤销操作
-	err = buffer.Undo()
+	_, err = buffer.Undo()
 	if err != nil {
 		t.Errorf("Undo failed: %v", err)
 	}
@@ -51,7 +52,7 @@ func TestTextBuffer(t *testing.T) {
 	}
 
 	// 测试重做操作
-	err = buffer.Redo()
+	_, err = buffer.Redo()
 	if err != nil {
 		t.Errorf("Redo failed: %v", err)
 	}
@@ -209,3 +210,215 @@ func TestTextBufferWithMultilineText(t *testing.T) {
 		t.Errorf("Expected 'Line 1e 2\\nNew line\\nLine 3', got '%s'", buffer.GetText())
 	}
 }
+
+func TestTextBufferUndoRestoresSelections(t *testing.T) {
+	buffer := NewTextBufferWithText("Hello World")
+
+	before := []Selection{NewCursor(Position{Line: 0, Column: 5})}
+	after := []Selection{NewCursor(Position{Line: 0, Column: 6})}
+	err := buffer.InsertWithSelections(Position{Line: 0, Column: 5}, ",", before, after)
+	if err != nil {
+		t.Fatalf("Insert failed: %v", err)
+	}
+
+	// 测试撤销返回操作前的选区
+	result, err := buffer.Undo()
+	if err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+	if len(result.Selections) != 1 || !result.Selections[0].Equals(before[0]) {
+		t.Errorf("Expected selections %v, got %v", before, result.Selections)
+	}
+	if len(result.ChangedRanges) != 1 || !result.ChangedRanges[0].IsEmpty() ||
+		!result.ChangedRanges[0].Start.Equals(Position{Line: 0, Column: 5}) {
+		t.Errorf("Expected empty changed range at (0, 5), got %v", result.ChangedRanges)
+	}
+
+	// 测试重做返回操作后的选区
+	result, err = buffer.Redo()
+	if err != nil {
+		t.Fatalf("Redo failed: %v", err)
+	}
+	if len(result.Selections) != 1 || !result.Selections[0].Equals(after[0]) {
+		t.Errorf("Expected selections %v, got %v", after, result.Selections)
+	}
+	expectedRange := NewRange(Position{Line: 0, Column: 5}, Position{Line: 0, Column: 6})
+	if len(result.ChangedRanges) != 1 || result.ChangedRanges[0] != expectedRange {
+		t.Errorf("Expected changed range %v, got %v", expectedRange, result.ChangedRanges)
+	}
+}
+
+// randomPosition 生成一个随机位置，可能超出行尾或文档末尾以覆盖位置被截断的情况
+func randomPosition(random *rand.Rand, buffer *TextBuffer) Position {
+	return Position{
+		Line:   random.Intn(buffer.GetLineCount() + 1),
+		Column: random.Intn(8),
+	}
+}
+
+// randomRange 生成一个随机范围，起始位置不在结束位置之后
+func randomRange(random *rand.Rand, buffer *TextBuffer) Range {
+	start := buffer.GetOffsetAt(randomPosition(random, buffer))
+	end := buffer.GetOffsetAt(randomPosition(random, buffer))
+	if start > end {
+		start, end = end, start
+	}
+	return Range{Start: buffer.GetPositionAt(start), End: buffer.GetPositionAt(end)}
+}
+
+// randomText 生成一段随机文本
+func randomText(random *rand.Rand) string {
+	alphabet := []rune("xy\n日 ")
+	runes := make([]rune, random.Intn(6))
+	for i := range runes {
+		runes[i] = alphabet[random.Intn(len(alphabet))]
+	}
+	return string(runes)
+}
+
+func TestTextBufferUndoRedoIdentity(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+
+	for iteration := 0; iteration < 100; iteration++ {
+		buffer := NewTextBufferWithText("alpha\nbeta\n\ngamma")
+		history := []string{buffer.GetText()}
+
+		for step := 0; step < 20; step++ {
+			switch random.Intn(6) {
+			case 0:
+				_ = buffer.Insert(randomPosition(random, buffer), randomText(random))
+			case 1:
+				_ = buffer.Delete(randomRange(random, buffer))
+			case 2:
+				_ = buffer.Replace(randomRange(random, buffer), randomText(random))
+			case 3:
+				// 多范围编辑，范围基于编辑前的文档
+				first := randomRange(random, buffer)
+				second := randomRange(random, buffer)
+				_ = buffer.ApplyEdits([]EditOperation{
+					{Range: first, Text: randomText(random)},
+					{Range: second, Text: randomText(random)},
+				}, nil, nil)
+			case 4:
+				buffer.SetText(randomText(random))
+			case 5:
+				if random.Intn(4) == 0 {
+					buffer.Clear()
+				}
+			}
+
+			text := buffer.GetText()
+			if text == history[len(history)-1] {
+				continue
+			}
+			history = append(history, text)
+
+			// 撤销后重做必须回到同样的文本
+			if _, err := buffer.Undo(); err != nil {
+				t.Fatalf("Undo failed: %v", err)
+			}
+			if buffer.GetText() != history[len(history)-2] {
+				t.Fatalf("Expected '%s' after undo, got '%s'", history[len(history)-2], buffer.GetText())
+			}
+			if _, err := buffer.Redo(); err != nil {
+				t.Fatalf("Redo failed: %v", err)
+			}
+			if buffer.GetText() != text {
+				t.Fatalf("Expected '%s' after redo, got '%s'", text, buffer.GetText())
+			}
+		}
+
+		// 撤销全部操作后应回到初始文本，重做全部操作后应回到最终文本
+		for i := len(history) - 2; i >= 0; i-- {
+			if _, err := buffer.Undo(); err != nil {
+				t.Fatalf("Undo failed: %v", err)
+			}
+			if buffer.GetText() != history[i] {
+				t.Fatalf("Expected '%s' after undo, got '%s'", history[i], buffer.GetText())
+			}
+		}
+		for i := 1; i < len(history); i++ {
+			if _, err := buffer.Redo(); err != nil {
+				t.Fatalf("Redo failed: %v", err)
+			}
+			if buffer.GetText() != history[i] {
+				t.Fatalf("Expected '%s' after redo, got '%s'", history[i], buffer.GetText())
+			}
+		}
+	}
+}
+
+func TestTextBufferApplyEdits(t *testing.T) {
+	buffer := NewTextBufferWithText("one two three")
+
+	// 测试多范围编辑，范围基于编辑前的文档
+	err := buffer.ApplyEdits([]EditOperation{
+		{Range: NewRange(Position{Line: 0, Column: 8}, Position{Line: 0, Column: 13}), Text: "3"},
+		{Range: NewRange(Position{Line: 0, Column: 0}, Position{Line: 0, Column: 3}), Text: "1"},
+	}, nil, nil)
+	if err != nil {
+		t.Fatalf("ApplyEdits failed: %v", err)
+	}
+	if buffer.GetText() != "1 two 3" {
+		t.Errorf("Expected '1 two 3', got '%s'", buffer.GetText())
+	}
+
+	// 测试撤销返回所有变化的范围
+	result, err := buffer.Undo()
+	if err != nil {
+		t.Fatalf("Undo failed: %v", err)
+	}
+	if buffer.GetText() != "one two three" {
+		t.Errorf("Expected 'one two three', got '%s'", buffer.GetText())
+	}
+	expectedRanges := []Range{
+		NewRange(Position{Line: 0, Column: 0}, Position{Line: 0, Column: 3}),
+		NewRange(Position{Line: 0, Column: 8}, Position{Line: 0, Column: 13}),
+	}
+	if len(result.ChangedRanges) != len(expectedRanges) {
+		t.Fatalf("Expected %d changed ranges, got %v", len(expectedRanges), result.ChangedRanges)
+	}
+	for i, r := range expectedRanges {
+		if result.ChangedRanges[i] != r {
+			t.Errorf("Expected changed range %v, got %v", r, result.ChangedRanges[i])
+		}
+	}
+
+	// 测试重叠的范围
+	err = buffer.ApplyEdits([]EditOperation{
+		{Range: NewRange(Position{Line: 0, Column: 0}, Position{Line: 0, Column: 5}), Text: "a"},
+		{Range: NewRange(Position{Line: 0, Column: 3}, Position{Line: 0, Column: 7}), Text: "b"},
+	}, nil, nil)
+	if err == nil {
+		t.Errorf("Expected error for overlapping ranges")
+	}
+}
+
+func TestEndPositionAfterTrailingNewline(t *testing.T) {
+	buffer := NewTextBufferWithText("x\ny\n")
+
+	// 末尾的换行符之后的位置不算作新的一行
+	end := buffer.GetPositionAt(buffer.GetLength())
+	if buffer.GetLineCount() != 2 || !end.Equals(Position{Line: 2, Column: 0}) {
+		t.Fatalf("Expected 2 lines and end position (2, 0), got %d, %v", buffer.GetLineCount(), end)
+	}
+	if offset := buffer.GetOffsetAt(end); offset != 4 {
+		t.Errorf("Expected offset 4, got %d", offset)
+	}
+
+	// 按行访问的方法把末尾位置所在的行当作空行
+	if content := buffer.GetLineContent(end.Line); content != "" {
+		t.Errorf("Expected empty line content, got %q", content)
+	}
+	if tokens := buffer.GetLineTokens(end.Line); tokens != nil {
+		t.Errorf("Expected no tokens, got %v", tokens)
+	}
+
+	// 行范围的命令把它截断到最后一行
+	if _, err := buffer.MoveLinesUp(end.Line, end.Line, nil); err != nil {
+		t.Fatalf("MoveLinesUp failed: %v", err)
+	}
+	if buffer.GetText() != "y\nx\n" {
+		t.Errorf("Expected %q, got %q", "y\nx\n", buffer.GetText())
+	}
+}
@@ -0,0 +1,315 @@
+package textbuffer
+
+import (
+	"context"
+	"strings"
+)
+
+// TokenizerState 表示分词器在一行结束时的状态，作为下一行的初始状态
+type TokenizerState interface {
+	// Clone 复制状态，分词器可以修改复制后的状态
+	Clone() TokenizerState
+	// Equals 判断两个状态是否相等，相等的状态对之后的行产生相同的分词结果
+	Equals(other TokenizerState) bool
+}
+
+// Tokenizer 按行对文本进行分词
+type Tokenizer interface {
+	// InitialState 获取第一行的初始状态
+	InitialState() TokenizerState
+	// Tokenize 对一行文本（不包括换行符）进行分词，返回这一行的词法单元和结束时的状态
+	// state是上一行结束时状态的副本
+	Tokenize(line string, state TokenizerState) ([]Token, TokenizerState)
+}
+
+// Token 表示一行中的一个词法单元，从StartColumn开始，到下一个词法单元的开始或行尾结束
+type Token struct {
+	// StartColumn 词法单元的起始列号（以rune为单位）
+	StartColumn int
+	// Type 词法单元的类型，用"."分隔的层级名称，例如"comment.line"或"string.quoted"
+	Type string
+}
+
+// StandardTokenType 表示括号匹配、注释等功能关心的词法单元分类
+type StandardTokenType int
+
+const (
+	// StandardTokenOther 普通代码
+	StandardTokenOther StandardTokenType = iota
+	// StandardTokenComment 注释
+	StandardTokenComment
+	// StandardTokenString 字符串
+	StandardTokenString
+	// StandardTokenRegExp 正则表达式
+	StandardTokenRegExp
+)
+
+// StandardTokenTypeOf 根据词法单元类型的第一级名称获取它的分类
+func StandardTokenTypeOf(tokenType string) StandardTokenType {
+	first, _, _ := strings.Cut(tokenType, ".")
+	switch first {
+	case "comment":
+		return StandardTokenComment
+	case "string":
+		return StandardTokenString
+	case "regexp":
+		return StandardTokenRegExp
+	}
+	return StandardTokenOther
+}
+
+// LineTokens 是一行的分词结果
+type LineTokens struct {
+	// Tokens 这一行的词法单元，按起始列号排序
+	Tokens []Token
+	// EndState 这一行结束时分词器的状态
+	EndState TokenizerState
+}
+
+// tokenAt 获取包含指定列的词法单元
+func tokenAt(tokens []Token, column int) (Token, bool) {
+	index := -1
+	for i, token := range tokens {
+		if token.StartColumn > column {
+			break
+		}
+		index = i
+	}
+	if index < 0 {
+		return Token{}, false
+	}
+	return tokens[index], true
+}
+
+// lineTokenData 是一行缓存的分词结果，同时记录开始时的状态用于判断缓存是否仍然有效
+type lineTokenData struct {
+	startState TokenizerState
+	tokens     LineTokens
+}
+
+// tokenStore 缓存每一行的分词结果
+// 编辑后从第一个受影响的行开始重新分词，遇到开始状态与缓存相同的行时停止，
+// 之后的行继续使用缓存的结果
+type tokenStore struct {
+	tokenizer Tokenizer
+	lines     lineDataCache[lineTokenData]
+	// firstInvalidLine 第一个可能需要重新分词的行
+	firstInvalidLine int
+}
+
+// linesChanged 使受编辑影响的行失效
+func (ts *tokenStore) linesChanged(startLine, removedLines, addedLines int) {
+	ts.lines.linesChanged(startLine, removedLines, addedLines)
+	ts.firstInvalidLine = min(ts.firstInvalidLine, startLine)
+}
+
+// SetTokenizer 设置文本缓冲区使用的分词器，为nil时不进行分词
+func (tb *TextBuffer) SetTokenizer(tokenizer Tokenizer) {
+	tb.mutex.Lock()
+	defer tb.unlock()
+
+	if tb.tokens == nil {
+		tb.tokens = &tokenStore{}
+		tb.addLineObserver(tb.tokens)
+	}
+	tb.tokens.tokenizer = tokenizer
+	tb.tokens.lines.invalidateAll()
+	tb.tokens.firstInvalidLine = 0
+	tb.queueTokensChanged(0, tb.gapBuffer.GetLineCount())
+
+	// 括号的扫描结果依赖分词结果
+	if tb.brackets != nil {
+		tb.brackets.invalidateAll()
+	}
+}
+
+// GetTokenizer 获取文本缓冲区使用的分词器
+func (tb *TextBuffer) GetTokenizer() Tokenizer {
+	tb.mutex.RLock()
+	defer tb.mutex.RUnlock()
+	if tb.tokens == nil {
+		return nil
+	}
+	return tb.tokens.tokenizer
+}
+
+// GetLineTokens 获取指定行的分词结果，没有设置分词器时返回nil
+func (tb *TextBuffer) GetLineTokens(lineIndex int) []Token {
+	tb.mutex.Lock()
+	defer tb.unlock()
+
+	if lineIndex < 0 || lineIndex >= tb.gapBuffer.GetLineCount() {
+		return nil
+	}
+	return tb.lineTokens(lineIndex)
+}
+
+// GetTokenAt 获取包含指定位置的词法单元
+func (tb *TextBuffer) GetTokenAt(position Position) (Token, bool) {
+	tb.mutex.Lock()
+	defer tb.unlock()
+
+	if position.Line < 0 || position.Line >= tb.gapBuffer.GetLineCount() {
+		return Token{}, false
+	}
+	return tokenAt(tb.lineTokens(position.Line), position.Column)
+}
+
+// hasTokenizer 判断是否设置了分词器
+// 调用方必须持有锁
+func (tb *TextBuffer) hasTokenizer() bool {
+	return tb.tokens != nil && tb.tokens.tokenizer != nil
+}
+
+// lineTokens 获取指定行的分词结果，必要时先对之前的行重新分词
+// 调用方必须持有写锁
+func (tb *TextBuffer) lineTokens(lineIndex int) []Token {
+	if !tb.hasTokenizer() {
+		return nil
+	}
+
+	store := tb.tokens
+	store.lines.resize(tb.gapBuffer.GetLineCount())
+
+	for line := store.firstInvalidLine; line <= lineIndex; line++ {
+		var state TokenizerState
+		if line == 0 {
+			state = store.tokenizer.InitialState()
+		} else {
+			previous, _ := store.lines.get(line - 1)
+			state = previous.tokens.EndState
+		}
+
+		// 开始状态与缓存相同时，这一行的分词结果仍然有效
+		if cached, ok := store.lines.get(line); ok && cached.startState.Equals(state) {
+			continue
+		}
+
+		tokens, endState := store.tokenizer.Tokenize(tb.lineText(line), state.Clone())
+		store.lines.set(line, lineTokenData{
+			startState: state,
+			tokens:     LineTokens{Tokens: tokens, EndState: endState},
+		})
+
+		// 这一行的词法单元可能变化，依赖它的括号需要重新扫描
+		if tb.brackets != nil {
+			tb.brackets.invalidate(line)
+		}
+		tb.queueTokensChanged(line, line+1)
+	}
+	store.firstInvalidLine = max(store.firstInvalidLine, lineIndex+1)
+
+	data, _ := store.lines.get(lineIndex)
+	return data.tokens.Tokens
+}
+
+// TokenizeSnapshot 对快照的所有行进行分词，可以在其他goroutine中调用
+// ctx被取消时停止分词并返回ctx的错误
+func TokenizeSnapshot(ctx context.Context, snapshot *Snapshot, tokenizer Tokenizer) ([]LineTokens, error) {
+	result := make([]LineTokens, snapshot.GetLineCount())
+	state := tokenizer.InitialState()
+	for lineIndex := range result {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		line := strings.TrimSuffix(strings.TrimSuffix(snapshot.GetLineContent(lineIndex), "\n"), "\r")
+		tokens, endState := tokenizer.Tokenize(line, state.Clone())
+		result[lineIndex] = LineTokens{Tokens: tokens, EndState: endState}
+		state = endState
+	}
+	return result, nil
+}
+
+// TokenizeInBackground 在不持有锁的情况下对当前文本的快照进行分词，然后安装分词结果
+// 分词期间文本被修改时，使用新的快照重新分词，直到成功或ctx被取消
+// 这个方法会阻塞，通常在单独的goroutine中调用
+func (tb *TextBuffer) TokenizeInBackground(ctx context.Context) error {
+	for {
+		tb.mutex.RLock()
+		if !tb.hasTokenizer() {
+			tb.mutex.RUnlock()
+			return nil
+		}
+		tokenizer := tb.tokens.tokenizer
+		snapshot := newSnapshot(tb.versionID, tb.gapBuffer.GetText())
+		tb.mutex.RUnlock()
+
+		lines, err := TokenizeSnapshot(ctx, snapshot, tokenizer)
+		if err != nil {
+			return err
+		}
+		if tb.installTokens(snapshot.VersionID(), tokenizer, lines) {
+			return nil
+		}
+	}
+}
+
+// installTokens 在文本和分词器都没有变化时安装后台分词的结果
+func (tb *TextBuffer) installTokens(versionID int, tokenizer Tokenizer, lines []LineTokens) bool {
+	tb.mutex.Lock()
+	defer tb.unlock()
+
+	if tb.versionID != versionID || !tb.hasTokenizer() || tb.tokens.tokenizer != tokenizer {
+		return false
+	}
+
+	store := tb.tokens
+	store.lines.resize(len(lines))
+	state := tokenizer.InitialState()
+	for lineIndex, line := range lines {
+		store.lines.set(lineIndex, lineTokenData{startState: state, tokens: line})
+		state = line.EndState
+	}
+	store.firstInvalidLine = len(lines)
+
+	if tb.brackets != nil {
+		tb.brackets.invalidateAll()
+	}
+	tb.queueTokensChanged(0, len(lines))
+	return true
+}
+
+// TokenizedLine 是一行的文本和分词结果
+type TokenizedLine struct {
+	// Text 行的内容（不包括换行符）
+	Text string
+	// Tokens 行的词法单元
+	Tokens []Token
+}
+
+// GetTokenizedLines 获取所有行的文本和分词结果，结果来自同一个版本的文本
+// 没有设置分词器时每一行的词法单元都为nil
+func (tb *TextBuffer) GetTokenizedLines() []TokenizedLine {
+	tb.mutex.Lock()
+	defer tb.unlock()
+
+	lines, _ := tb.tokenizedLines(0, tb.gapBuffer.GetLineCount())
+	return lines
+}
+
+// GetTokenizedLinesInRange 获取[startLine, endLine)范围内的行的文本和分词结果，以及它们对应的版本号
+// 范围超出文本时被截断
+func (tb *TextBuffer) GetTokenizedLinesInRange(startLine, endLine int) ([]TokenizedLine, int) {
+	tb.mutex.Lock()
+	defer tb.unlock()
+	return tb.tokenizedLines(startLine, endLine)
+}
+
+// tokenizedLines 获取[startLine, endLine)范围内的行的文本和分词结果
+// 调用方必须持有写锁
+func (tb *TextBuffer) tokenizedLines(startLine, endLine int) ([]TokenizedLine, int) {
+	startLine = max(startLine, 0)
+	endLine = min(endLine, tb.gapBuffer.GetLineCount())
+	if startLine >= endLine {
+		return []TokenizedLine{}, tb.versionID
+	}
+
+	lines := make([]TokenizedLine, endLine-startLine)
+	for i := range lines {
+		lines[i] = TokenizedLine{
+			Text:   tb.lineText(startLine + i),
+			Tokens: tb.lineTokens(startLine + i),
+		}
+	}
+	return lines, tb.versionID
+}
@@ -0,0 +1,179 @@
+package textbuffer
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// commentState 记录是否处于块注释中
+type commentState struct {
+	inComment bool
+}
+
+func (s *commentState) Clone() TokenizerState {
+	clone := *s
+	return &clone
+}
+
+func (s *commentState) Equals(other TokenizerState) bool {
+	o, ok := other.(*commentState)
+	return ok && *s == *o
+}
+
+// testTokenizer 识别块注释"/* */"和双引号字符串，并记录分词的次数
+type testTokenizer struct {
+	calls int
+}
+
+func (t *testTokenizer) InitialState() TokenizerState {
+	return &commentState{}
+}
+
+func (t *testTokenizer) Tokenize(line string, state TokenizerState) ([]Token, TokenizerState) {
+	t.calls++
+	s := state.(*commentState)
+	runes := []rune(line)
+	var tokens []Token
+	add := func(column int, tokenType string) {
+		if len(tokens) == 0 || tokens[len(tokens)-1].Type != tokenType {
+			tokens = append(tokens, Token{StartColumn: column, Type: tokenType})
+		}
+	}
+
+	for i := 0; i < len(runes); i++ {
+		switch {
+		case s.inComment:
+			add(i, "comment.block")
+			if strings.HasPrefix(string(runes[i:]), "*/") {
+				i++
+				s.inComment = false
+			}
+		case strings.HasPrefix(string(runes[i:]), "/*"):
+			add(i, "comment.block")
+			i++
+			s.inComment = true
+		case runes[i] == '"':
+			add(i, "string.quoted")
+			for i++; i < len(runes) && runes[i] != '"'; i++ {
+			}
+			tokens = append(tokens, Token{StartColumn: i + 1, Type: "source"})
+		default:
+			add(i, "source")
+		}
+	}
+	return tokens, s
+}
+
+func TestTokenization(t *testing.T) {
+	lines := make([]string, 100)
+	for i := range lines {
+		lines[i] = "x()"
+	}
+	buffer := NewTextBufferWithText(strings.Join(lines, "\n"))
+	tokenizer := &testTokenizer{}
+	buffer.SetTokenizer(tokenizer)
+
+	if tokens := buffer.GetLineTokens(99); len(tokens) != 1 || tokens[0].Type != "source" {
+		t.Fatalf("Unexpected tokens %v", tokens)
+	}
+	if tokenizer.calls != 100 {
+		t.Errorf("Expected 100 tokenize calls, got %d", tokenizer.calls)
+	}
+
+	// 不改变结束状态的编辑只重新分词被编辑的行
+	tokenizer.calls = 0
+	_ = buffer.Insert(Position{Line: 10, Column: 0}, "y")
+	buffer.GetLineTokens(99)
+	if tokenizer.calls != 1 {
+		t.Errorf("Expected 1 tokenize call, got %d", tokenizer.calls)
+	}
+
+	// 开始块注释会使之后的所有行重新分词
+	tokenizer.calls = 0
+	_ = buffer.Insert(Position{Line: 50, Column: 0}, "/*")
+	if token, ok := buffer.GetTokenAt(Position{Line: 80, Column: 1}); !ok || StandardTokenTypeOf(token.Type) != StandardTokenComment {
+		t.Errorf("Expected comment token, got %v", token)
+	}
+	if tokenizer.calls != 31 {
+		t.Errorf("Expected 31 tokenize calls, got %d", tokenizer.calls)
+	}
+
+	// 关闭注释后，在开始状态与缓存一致的第81行停止重新分词
+	tokenizer.calls = 0
+	_ = buffer.Insert(Position{Line: 52, Column: 3}, "*/")
+	buffer.GetLineTokens(99)
+	if tokenizer.calls != 29 {
+		t.Errorf("Expected 29 tokenize calls, got %d", tokenizer.calls)
+	}
+	if token, _ := buffer.GetTokenAt(Position{Line: 60, Column: 0}); token.Type != "source" {
+		t.Errorf("Expected source token after the comment, got %v", token)
+	}
+}
+
+func TestOnTokensChanged(t *testing.T) {
+	buffer := NewTextBufferWithText("a\nb\nc\nd")
+	var events []TokensChangedEvent
+	buffer.OnTokensChanged(func(event TokensChangedEvent) {
+		events = append(events, event)
+	})
+
+	buffer.SetTokenizer(&testTokenizer{})
+	buffer.GetLineTokens(3)
+	if len(events) != 2 || events[0].EndLine != 4 || events[1].StartLine != 0 || events[1].EndLine != 4 {
+		t.Fatalf("Unexpected events %+v", events)
+	}
+
+	// 开始块注释之后，读取到的行的分词结果都发生了变化
+	events = nil
+	_ = buffer.Insert(Position{Line: 1, Column: 0}, "/*")
+	buffer.GetLineTokens(2)
+	if len(events) != 1 || events[0].StartLine != 1 || events[0].EndLine != 3 || events[0].VersionID != 1 {
+		t.Errorf("Unexpected events %+v", events)
+	}
+}
+
+func TestBracketsSkipCommentsAndStrings(t *testing.T) {
+	buffer := NewTextBufferWithText("f(\"(\", /* ) */\n  x)")
+	buffer.SetTokenizer(&testTokenizer{})
+
+	if match, ok := buffer.MatchBracket(Position{Line: 0, Column: 1}); !ok || !match.Equals(Position{Line: 1, Column: 3}) {
+		t.Errorf("Expected match at (1, 3), got %v %v", match, ok)
+	}
+
+	// 之前的行开始块注释后，括号不再匹配
+	_ = buffer.Insert(Position{Line: 0, Column: 0}, "/*\n")
+	if _, ok := buffer.MatchBracket(Position{Line: 1, Column: 1}); ok {
+		t.Errorf("Expected no match inside a comment")
+	}
+}
+
+func TestTokenizeInBackground(t *testing.T) {
+	buffer := NewTextBufferWithText("a\n/* b\nc */ d")
+	tokenizer := &testTokenizer{}
+	buffer.SetTokenizer(tokenizer)
+
+	done := make(chan error)
+	go func() {
+		done <- buffer.TokenizeInBackground(context.Background())
+	}()
+	if err := <-done; err != nil {
+		t.Fatalf("TokenizeInBackground failed: %v", err)
+	}
+
+	// 后台分词的结果直接使用，不需要再次分词
+	calls := tokenizer.calls
+	tokens := buffer.GetLineTokens(2)
+	if tokenizer.calls != calls {
+		t.Errorf("Expected background tokens to be reused")
+	}
+	if len(tokens) != 2 || tokens[0].Type != "comment.block" || tokens[1].StartColumn != 4 {
+		t.Errorf("Unexpected tokens %v", tokens)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := TokenizeSnapshot(ctx, buffer.CreateSnapshot(), tokenizer); err == nil {
+		t.Errorf("Expected error for cancelled context")
+	}
+}
@@ -0,0 +1,388 @@
+package textbuffer
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// autoCloseBefore 是自动闭合时允许出现在光标之后的字符，光标在行尾时也会自动闭合
+const autoCloseBefore = ";:.,=}])> \t"
+
+// SetIndentOptions 设置输入时使用的缩进方式
+func (tb *TextBuffer) SetIndentOptions(options IndentOptions) {
+	tb.mutex.Lock()
+	defer tb.unlock()
+	tb.indentOptions = options
+}
+
+// GetIndentOptions 获取输入时使用的缩进方式
+func (tb *TextBuffer) GetIndentOptions() IndentOptions {
+	tb.mutex.RLock()
+	defer tb.mutex.RUnlock()
+	return tb.indentOptions
+}
+
+// typedEdit 是在一个选区上输入字符产生的编辑
+type typedEdit struct {
+	// 被替换的偏移量区间
+	start int
+	end   int
+	// 插入的文本
+	text string
+	// 输入之后选区的锚点和活动位置，相对于插入文本的开头
+	anchor int
+	active int
+	// 是否在输入的字符之后自动插入了结束字符
+	autoClosed bool
+	// 是否跳过了自动插入的结束字符
+	overtyped bool
+}
+
+// TypeCharacter 在每个选区上输入一个字符，按照编辑器的规则处理，所有选区的修改作为一个操作应用
+// 规则来自语言配置：输入开始字符时自动插入结束字符，在自动插入的相同结束字符前输入时跳过它，
+// 有选区时输入开始字符会用字符对包围选区，输入换行符时自动缩进（包括"{|}"之间的额外缩进）
+// selections为空时使用跟踪的选区，返回输入之后的选区
+func (tb *TextBuffer) TypeCharacter(selections []Selection, ch rune) ([]Selection, error) {
+	tb.mutex.Lock()
+	defer tb.unlock()
+
+	if selections == nil {
+		selections = tb.getSelections()
+	}
+	config := tb.getLanguageConfiguration()
+	tb.pruneAutoClosed(selections)
+
+	typed := make([]typedEdit, len(selections))
+	order := make([]int, len(selections))
+	for i, selection := range selections {
+		typed[i] = tb.typeCharacter(selection, ch, config)
+		order[i] = i
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		return typed[order[i]].start < typed[order[j]].start
+	})
+
+	// 输入之后的选区和自动插入的结束字符用编辑之后的偏移量表示，在应用编辑之后再转换为位置
+	offsets := make([]selectionOffsets, len(selections))
+	var autoClosed, overtyped []int
+	operations := make([]EditOperation, len(selections))
+	shift := 0
+	for k, i := range order {
+		edit := typed[i]
+		if k > 0 && edit.start < typed[order[k-1]].end {
+			return nil, errors.New("overlapping selections")
+		}
+
+		base := edit.start + shift
+		offsets[i] = selectionOffsets{anchor: base + edit.anchor, active: base + edit.active}
+		if edit.autoClosed {
+			// 结束字符紧跟在输入的字符之后
+			autoClosed = append(autoClosed, base+1)
+		}
+		if edit.overtyped {
+			overtyped = append(overtyped, edit.start)
+		}
+		operations[k] = EditOperation{
+			Range: NewRange(tb.gapBuffer.GetPositionAt(edit.start), tb.gapBuffer.GetPositionAt(edit.end)),
+			Text:  edit.text,
+		}
+		shift += utf8.RuneCountInString(edit.text) - (edit.end - edit.start)
+	}
+
+	edits, err := tb.gapBuffer.resolveEdits(operations)
+	if err != nil {
+		return nil, err
+	}
+
+	// 跳过结束字符的编辑没有修改文本，不会通过编辑调整，需要单独停止跟踪被跳过的字符
+	for _, offset := range overtyped {
+		tb.removeAutoClosed(offset)
+	}
+	tb.applyEdits(edits)
+	tb.selections = offsets
+	tb.autoClosed = append(tb.autoClosed, autoClosed...)
+	after := tb.getSelections()
+
+	if len(edits) > 0 {
+		tb.recordOperation(&TextOperation{
+			Type:             operationTypeOf(edits),
+			Label:            "Typing",
+			Source:           EditSourceUser,
+			Edits:            edits,
+			BeforeSelections: cloneSelections(selections),
+			AfterSelections:  cloneSelections(after),
+		})
+	}
+
+	return after, nil
+}
+
+// typeCharacter 计算在一个选区上输入字符产生的编辑
+// 调用方必须持有写锁
+func (tb *TextBuffer) typeCharacter(selection Selection, ch rune, config *LanguageConfiguration) typedEdit {
+	r := selection.Range()
+	start := tb.gapBuffer.GetOffsetAt(r.Start)
+	end := tb.gapBuffer.GetOffsetAt(r.End)
+	text := string(ch)
+
+	if ch == '\n' {
+		return tb.typeEnter(start, end, config)
+	}
+
+	// 用字符对包围选区，包围之后仍然选中原来的文本
+	if start < end {
+		for _, pair := range config.SurroundingPairs {
+			if pair.Open != text {
+				continue
+			}
+			selected := tb.gapBuffer.getTextInOffsets(start, end)
+			openLength := utf8.RuneCountInString(pair.Open)
+			edit := typedEdit{start: start, end: end, text: pair.Open + selected + pair.Close}
+			edit.anchor = openLength
+			edit.active = openLength + (end - start)
+			if selection.IsReversed() {
+				edit.anchor, edit.active = edit.active, edit.anchor
+			}
+			return edit
+		}
+		return typedEdit{start: start, end: end, text: text, anchor: 1, active: 1}
+	}
+
+	position := tb.gapBuffer.GetPositionAt(start)
+	line := []rune(tb.lineText(position.Line))
+	column := min(position.Column, len(line))
+	var next rune
+	if column < len(line) {
+		next = line[column]
+	}
+
+	// 在自动插入的相同结束字符前输入时跳过它
+	if next == ch && tb.isAutoClosed(start) {
+		for _, pair := range config.AutoClosingPairs {
+			if pair.Close == text {
+				return typedEdit{start: start, end: start + 1, text: text, anchor: 1, active: 1, overtyped: true}
+			}
+		}
+	}
+
+	// 自动插入结束字符
+	if next == 0 || strings.ContainsRune(autoCloseBefore, next) {
+		before := string(line[:column]) + text
+		for _, pair := range config.AutoClosingPairs {
+			if !strings.HasSuffix(before, pair.Open) || !tb.canAutoClose(pair, position.Line, line, column) {
+				continue
+			}
+			return typedEdit{start: start, end: start, text: text + pair.Close, anchor: 1, active: 1, autoClosed: true}
+		}
+	}
+
+	return typedEdit{start: start, end: start, text: text, anchor: 1, active: 1}
+}
+
+// isAutoClosed 判断偏移量处的字符是否是输入时自动插入的结束字符
+// 调用方必须持有锁
+func (tb *TextBuffer) isAutoClosed(offset int) bool {
+	for _, autoClosed := range tb.autoClosed {
+		if autoClosed == offset {
+			return true
+		}
+	}
+	return false
+}
+
+// removeAutoClosed 停止跟踪偏移量处自动插入的结束字符
+// 调用方必须持有写锁
+func (tb *TextBuffer) removeAutoClosed(offset int) {
+	for i, autoClosed := range tb.autoClosed {
+		if autoClosed == offset {
+			tb.autoClosed = append(tb.autoClosed[:i], tb.autoClosed[i+1:]...)
+			return
+		}
+	}
+}
+
+// transformAutoClosed 根据已应用的编辑调整自动插入的结束字符，被删除或替换的字符不再跟踪
+// 调用方必须持有写锁
+func (tb *TextBuffer) transformAutoClosed(edit Edit) {
+	if len(tb.autoClosed) == 0 {
+		return
+	}
+	kept := tb.autoClosed[:0]
+	delta := utf8.RuneCountInString(edit.NewText) - utf8.RuneCountInString(edit.OldText)
+	for _, offset := range tb.autoClosed {
+		switch {
+		case offset < edit.Offset:
+			kept = append(kept, offset)
+		case offset >= edit.OldEnd():
+			kept = append(kept, offset+delta)
+		}
+	}
+	tb.autoClosed = kept
+}
+
+// pruneAutoClosed 丢弃不在任何选区所在行上的自动插入的结束字符，光标离开之后输入结束字符不再跳过它们
+// 调用方必须持有写锁
+func (tb *TextBuffer) pruneAutoClosed(selections []Selection) {
+	if len(tb.autoClosed) == 0 {
+		return
+	}
+	kept := tb.autoClosed[:0]
+	for _, offset := range tb.autoClosed {
+		line := tb.gapBuffer.GetPositionAt(offset).Line
+		for _, selection := range selections {
+			if selection.Active.Line == line {
+				kept = append(kept, offset)
+				break
+			}
+		}
+	}
+	tb.autoClosed = kept
+}
+
+// canAutoClose 判断在指定列输入字符对的开始字符时是否自动闭合
+// 调用方必须持有写锁
+func (tb *TextBuffer) canAutoClose(pair AutoClosingPair, lineIndex int, line []rune, column int) bool {
+	// 开始和结束相同的字符对（例如引号）紧跟在单词之后时不自动闭合
+	if pair.Open == pair.Close && column > 0 {
+		if previous := line[column-1]; unicode.IsLetter(previous) || unicode.IsDigit(previous) || previous == '_' {
+			return false
+		}
+	}
+
+	if len(pair.NotIn) == 0 {
+		return true
+	}
+	token, ok := tokenAt(tb.lineTokens(lineIndex), column)
+	if !ok {
+		return true
+	}
+	tokenType := StandardTokenTypeOf(token.Type)
+	for _, notIn := range pair.NotIn {
+		if notIn == tokenType {
+			return false
+		}
+	}
+	return true
+}
+
+// typeEnter 计算在一个选区上输入换行符产生的编辑，新行根据语言配置自动缩进
+// 调用方必须持有写锁
+func (tb *TextBuffer) typeEnter(start, end int, config *LanguageConfiguration) typedEdit {
+	startPosition := tb.gapBuffer.GetPositionAt(start)
+	endPosition := tb.gapBuffer.GetPositionAt(end)
+	startLine := []rune(tb.lineText(startPosition.Line))
+	endLine := []rune(tb.lineText(endPosition.Line))
+
+	beforeText := string(startLine[:min(startPosition.Column, len(startLine))])
+	afterText := string(endLine[min(endPosition.Column, len(endLine)):])
+
+	// 光标之后的空白会被删除
+	trimmedAfter := strings.TrimLeft(afterText, " \t")
+	end += utf8.RuneCountInString(afterText) - utf8.RuneCountInString(trimmedAfter)
+	afterText = trimmedAfter
+
+	var previousLineText string
+	if startPosition.Line > 0 {
+		previousLineText = tb.lineText(startPosition.Line - 1)
+	}
+
+	action, matched := matchOnEnterRules(config.OnEnterRules, beforeText, afterText, previousLineText)
+	if !matched {
+		action = tb.enterActionFromIndentation(config, beforeText, afterText)
+	}
+
+	options := tb.indentOptions
+	tabSize := options.tabSize()
+	column := visibleColumn(leadingWhitespace(beforeText), tabSize)
+	indentColumn := column
+	switch action.Indent {
+	case IndentActionIndent, IndentActionIndentOutdent:
+		indentColumn = (column/tabSize + 1) * tabSize
+	case IndentActionOutdent:
+		indentColumn = max(((column+tabSize-1)/tabSize-1)*tabSize, 0)
+	}
+
+	indent := options.generateIndent(indentColumn)
+	if action.RemoveText > 0 {
+		runes := []rune(indent)
+		indent = string(runes[:max(len(runes)-action.RemoveText, 0)])
+	}
+
+	eol := tb.lineEndOfLine(startPosition.Line)
+	text := eol + indent + action.AppendText
+	cursor := utf8.RuneCountInString(text)
+	if action.Indent == IndentActionIndentOutdent {
+		text += eol + options.generateIndent(column)
+	}
+
+	return typedEdit{start: start, end: end, text: text, anchor: cursor, active: cursor}
+}
+
+// lineEndOfLine 获取在指定行插入换行时使用的换行符：这一行的换行符，
+// 最后一行没有换行符时使用上一行的换行符，只有一行时使用默认的换行符
+// 调用方必须持有锁
+func (tb *TextBuffer) lineEndOfLine(lineIndex int) string {
+	for line := lineIndex; line >= max(lineIndex-1, 0); line-- {
+		if eol := lineEnding(tb.gapBuffer.GetLineContent(line)); eol != "" {
+			return eol
+		}
+	}
+	return EndOfLineLF.Sequence()
+}
+
+// matchOnEnterRules 查找第一个匹配的回车规则
+func matchOnEnterRules(rules []OnEnterRule, beforeText, afterText, previousLineText string) (EnterAction, bool) {
+	for _, rule := range rules {
+		if rule.BeforeText == nil || !rule.BeforeText.MatchString(beforeText) {
+			continue
+		}
+		if rule.AfterText != nil && !rule.AfterText.MatchString(afterText) {
+			continue
+		}
+		if rule.PreviousLineText != nil && !rule.PreviousLineText.MatchString(previousLineText) {
+			continue
+		}
+		return rule.Action, true
+	}
+	return EnterAction{}, false
+}
+
+// enterActionFromIndentation 根据缩进规则和括号确定换行的缩进方式
+func (tb *TextBuffer) enterActionFromIndentation(config *LanguageConfiguration, beforeText, afterText string) EnterAction {
+	rules := config.IndentationRules
+	trimmedBefore := strings.TrimRight(beforeText, " \t")
+
+	increase := false
+	if rules != nil && rules.IncreaseIndentPattern != nil {
+		increase = rules.IncreaseIndentPattern.MatchString(beforeText)
+	}
+	// 光标在开括号和对应的闭括号之间
+	between := false
+	for _, pair := range config.Brackets {
+		if strings.HasSuffix(trimmedBefore, pair.Open) {
+			increase = true
+			if strings.HasPrefix(afterText, pair.Close) {
+				between = true
+			}
+		}
+	}
+
+	decrease := false
+	if rules != nil && rules.DecreaseIndentPattern != nil {
+		decrease = rules.DecreaseIndentPattern.MatchString(afterText)
+	}
+
+	switch {
+	case increase && (between || decrease):
+		return EnterAction{Indent: IndentActionIndentOutdent}
+	case increase:
+		return EnterAction{Indent: IndentActionIndent}
+	case decrease:
+		return EnterAction{Indent: IndentActionOutdent}
+	}
+	return EnterAction{Indent: IndentActionNone}
+}
@@ -0,0 +1,201 @@
+package textbuffer
+
+import (
+	"regexp"
+	"testing"
+)
+
+// typeText 依次输入每个字符，返回最后的选区
+func typeText(t *testing.T, buffer *TextBuffer, selections []Selection, text string) []Selection {
+	t.Helper()
+	for _, ch := range text {
+		var err error
+		selections, err = buffer.TypeCharacter(selections, ch)
+		if err != nil {
+			t.Fatalf("TypeCharacter(%q) failed: %v", ch, err)
+		}
+	}
+	return selections
+}
+
+func TestTypeCharacterAutoClose(t *testing.T) {
+	buffer := NewTextBuffer()
+	buffer.SetLanguageID("go")
+	selections := []Selection{NewCursor(Position{Line: 0, Column: 0})}
+
+	// 自动闭合括号和引号，输入结束字符时跳过已有的字符
+	selections = typeText(t, buffer, selections, `f("a`)
+	if buffer.GetText() != `f("a")` || !selections[0].Active.Equals(Position{Line: 0, Column: 4}) {
+		t.Errorf("Unexpected result %q %v", buffer.GetText(), selections)
+	}
+	selections = typeText(t, buffer, selections, `")`)
+	if buffer.GetText() != `f("a")` || !selections[0].Active.Equals(Position{Line: 0, Column: 6}) {
+		t.Errorf("Unexpected result after overtyping %q %v", buffer.GetText(), selections)
+	}
+
+	// 单词之后的引号和光标之后有其他字符时不自动闭合
+	buffer = NewTextBufferWithText("dont x")
+	buffer.SetLanguageID("go")
+	typeText(t, buffer, []Selection{NewCursor(Position{Line: 0, Column: 3})}, "'")
+	typeText(t, buffer, []Selection{NewCursor(Position{Line: 0, Column: 6})}, "(")
+	if buffer.GetText() != "don't (x" {
+		t.Errorf("Expected %q, got %q", "don't (x", buffer.GetText())
+	}
+
+	// 每次输入是一个撤销步骤
+	if buffer.UndoLabel() != "Typing" {
+		t.Errorf("Expected undo label 'Typing', got '%s'", buffer.UndoLabel())
+	}
+}
+
+func TestTypeCharacterOvertypesOnlyAutoClosed(t *testing.T) {
+	// 文本中已有的结束字符不会被跳过
+	buffer := NewTextBufferWithText("f()")
+	buffer.SetLanguageID("go")
+	typeText(t, buffer, []Selection{NewCursor(Position{Line: 0, Column: 2})}, ")")
+	if buffer.GetText() != "f())" {
+		t.Errorf("Expected %q, got %q", "f())", buffer.GetText())
+	}
+
+	// 嵌套的自动闭合字符依次被跳过
+	buffer = NewTextBufferWithText("\n")
+	buffer.SetLanguageID("go")
+	selections := typeText(t, buffer, []Selection{NewCursor(Position{Line: 0, Column: 0})}, "((")
+	selections = typeText(t, buffer, selections, "))")
+	if buffer.GetText() != "(())\n" || !selections[0].Active.Equals(Position{Line: 0, Column: 4}) {
+		t.Errorf("Unexpected result %q %v", buffer.GetText(), selections)
+	}
+
+	// 光标离开所在的行之后不再跳过
+	buffer.SetSelections([]Selection{NewCursor(Position{Line: 0, Column: 4})})
+	selections = typeText(t, buffer, nil, "[")
+	buffer.SetSelections([]Selection{NewCursor(Position{Line: 1, Column: 0})})
+	buffer.SetSelections([]Selection{selections[0]})
+	typeText(t, buffer, nil, "]")
+	if buffer.GetText() != "(())[]]\n" {
+		t.Errorf("Expected %q, got %q", "(())[]]\n", buffer.GetText())
+	}
+
+	// 撤销删除自动插入的字符后，重新输入的结束字符不会被跳过
+	buffer = NewTextBuffer()
+	buffer.SetLanguageID("go")
+	selections = typeText(t, buffer, []Selection{NewCursor(Position{Line: 0, Column: 0})}, "(")
+	buffer.Undo()
+	buffer.Insert(Position{Line: 0, Column: 0}, "()")
+	typeText(t, buffer, []Selection{NewCursor(Position{Line: 0, Column: 1})}, ")")
+	if buffer.GetText() != "())" {
+		t.Errorf("Expected %q, got %q", "())", buffer.GetText())
+	}
+
+	// 被跳过的结束字符不再被跟踪
+	buffer = NewTextBuffer()
+	buffer.SetLanguageID("go")
+	typeText(t, buffer, []Selection{NewCursor(Position{Line: 0, Column: 0})}, "()")
+	typeText(t, buffer, []Selection{NewCursor(Position{Line: 0, Column: 1})}, ")")
+	if buffer.GetText() != "())" {
+		t.Errorf("Expected %q, got %q", "())", buffer.GetText())
+	}
+}
+
+func TestTypeCharacterNotInString(t *testing.T) {
+	buffer := NewTextBufferWithText(`s := "ab"`)
+	buffer.SetLanguageID("go")
+	buffer.SetTokenizer(&testTokenizer{})
+
+	// 在字符串中输入引号时不自动闭合
+	typeText(t, buffer, []Selection{NewCursor(Position{Line: 0, Column: 7})}, "'")
+	if buffer.GetText() != `s := "a'b"` {
+		t.Errorf("Expected %q, got %q", `s := "a'b"`, buffer.GetText())
+	}
+}
+
+func TestTypeCharacterSurround(t *testing.T) {
+	buffer := NewTextBufferWithText("a foo b")
+	buffer.SetLanguageID("go")
+
+	selections := typeText(t, buffer, []Selection{NewSelection(Position{Line: 0, Column: 5}, Position{Line: 0, Column: 2})}, "(")
+	if buffer.GetText() != "a (foo) b" {
+		t.Errorf("Expected %q, got %q", "a (foo) b", buffer.GetText())
+	}
+	if !selections[0].Equals(NewSelection(Position{Line: 0, Column: 6}, Position{Line: 0, Column: 3})) {
+		t.Errorf("Expected the original text to stay selected, got %v", selections)
+	}
+
+	// 不是包围字符对时替换选区
+	selections = typeText(t, buffer, selections, "x")
+	if buffer.GetText() != "a (x) b" || !selections[0].Active.Equals(Position{Line: 0, Column: 4}) {
+		t.Errorf("Unexpected result %q %v", buffer.GetText(), selections)
+	}
+}
+
+func TestTypeCharacterEnter(t *testing.T) {
+	buffer := NewTextBuffer()
+	buffer.SetLanguageID("go")
+	selections := typeText(t, buffer, []Selection{NewCursor(Position{})}, "func f() {\n")
+
+	// 在"{|}"之间换行时，光标所在的新行增加缩进，闭括号移动到下一行
+	expected := "func f() {\n\t\n}"
+	if buffer.GetText() != expected || !selections[0].Active.Equals(Position{Line: 1, Column: 1}) {
+		t.Errorf("Expected %q, got %q %v", expected, buffer.GetText(), selections)
+	}
+
+	// 普通行保持缩进
+	selections = typeText(t, buffer, selections, "x()\n")
+	expected = "func f() {\n\tx()\n\t\n}"
+	if buffer.GetText() != expected || !selections[0].Active.Equals(Position{Line: 2, Column: 1}) {
+		t.Errorf("Expected %q, got %q %v", expected, buffer.GetText(), selections)
+	}
+
+	// 使用空格缩进，回车规则可以添加文本
+	buffer = NewTextBufferWithText("- item")
+	buffer.SetLanguageID("markdown")
+	buffer.SetIndentOptions(IndentOptions{TabSize: 2, InsertSpaces: true})
+	selections = typeText(t, buffer, []Selection{NewCursor(Position{Line: 0, Column: 6})}, "\n")
+	if buffer.GetText() != "- item\n- " || !selections[0].Active.Equals(Position{Line: 1, Column: 2}) {
+		t.Errorf("Unexpected result %q %v", buffer.GetText(), selections)
+	}
+}
+
+func TestTypeCharacterEnterCRLF(t *testing.T) {
+	buffer := NewTextBufferWithText("// a\r\nx\r\ny")
+	buffer.SetLanguageConfiguration(&LanguageConfiguration{
+		OnEnterRules: []OnEnterRule{{
+			BeforeText:       regexp.MustCompile(`^x$`),
+			PreviousLineText: regexp.MustCompile(`^// a$`),
+			Action:           EnterAction{AppendText: "// "},
+		}},
+	})
+
+	// 新的换行符与所在的行相同，上一行的文本不包括换行符
+	typeText(t, buffer, []Selection{NewCursor(Position{Line: 1, Column: 1})}, "\n")
+	typeText(t, buffer, []Selection{NewCursor(Position{Line: 3, Column: 1})}, "\n")
+	if expected := "// a\r\nx\r\n// \r\ny\r\n"; buffer.GetText() != expected {
+		t.Errorf("Expected %q, got %q", expected, buffer.GetText())
+	}
+}
+
+func TestTypeCharacterMultipleSelections(t *testing.T) {
+	buffer := NewTextBufferWithText("a\nb\nc")
+	buffer.SetLanguageID("go")
+	buffer.SetSelections([]Selection{
+		NewCursor(Position{Line: 2, Column: 1}),
+		NewCursor(Position{Line: 0, Column: 1}),
+	})
+
+	selections := typeText(t, buffer, nil, "[")
+	if buffer.GetText() != "a[]\nb\nc[]" {
+		t.Errorf("Expected %q, got %q", "a[]\nb\nc[]", buffer.GetText())
+	}
+	if !selections[0].Active.Equals(Position{Line: 2, Column: 2}) || !selections[1].Active.Equals(Position{Line: 0, Column: 2}) {
+		t.Errorf("Unexpected selections %v", selections)
+	}
+	if tracked := buffer.GetSelections(); !tracked[0].Equals(selections[0]) {
+		t.Errorf("Expected tracked selections to be updated, got %v", tracked)
+	}
+
+	// 撤销恢复输入之前的选区
+	result, err := buffer.Undo()
+	if err != nil || buffer.GetText() != "a\nb\nc" || !result.Selections[1].Active.Equals(Position{Line: 0, Column: 1}) {
+		t.Errorf("Unexpected undo result %q %v (%v)", buffer.GetText(), result, err)
+	}
+}
@@ -0,0 +1,95 @@
+package textbuffer
+
+import (
+	"errors"
+	"fmt"
+	"unicode/utf8"
+)
+
+// ErrUndoHistoryMismatch 表示撤销历史与当前文本不一致
+var ErrUndoHistoryMismatch = errors.New("undo history does not match the text")
+
+// UndoHistory 是撤销/重做历史的副本，用于持久化和恢复
+type UndoHistory struct {
+	// Undo 撤销栈中的操作，按从旧到新的顺序排列
+	Undo []TextOperation
+	// Redo 重做栈中的操作，下一个将被重做的操作在前
+	Redo []TextOperation
+	// SavedOperationID 文本与文件一致时撤销栈顶的操作标识，0表示撤销栈为空时一致
+	// 设置为不存在的标识表示没有任何状态与文件一致
+	SavedOperationID uint64
+}
+
+// ExportUndoHistory 获取撤销/重做历史的副本
+func (tb *TextBuffer) ExportUndoHistory() UndoHistory {
+	tb.mutex.RLock()
+	defer tb.mutex.RUnlock()
+
+	history := UndoHistory{SavedOperationID: tb.savedOperationID}
+	for _, operation := range tb.undoStack.UndoOperations() {
+		history.Undo = append(history.Undo, cloneOperation(operation))
+	}
+	for _, operation := range tb.undoStack.RedoOperations() {
+		history.Redo = append(history.Redo, cloneOperation(operation))
+	}
+	return history
+}
+
+// ImportUndoHistory 用给定的历史替换撤销/重做历史，文本内容不变
+// 历史必须与当前文本一致：依次撤销所有操作、再依次重做所有操作都必须能够应用，
+// 否则返回ErrUndoHistoryMismatch，原来的历史保持不变
+func (tb *TextBuffer) ImportUndoHistory(history UndoHistory) error {
+	tb.mutex.Lock()
+	defer tb.unlock()
+
+	text := []rune(tb.gapBuffer.GetText())
+	var err error
+	for i := len(history.Undo) - 1; i >= 0 && err == nil; i-- {
+		text, err = replayEdits(text, invertEdits(history.Undo[i].Edits))
+	}
+	text = []rune(tb.gapBuffer.GetText())
+	for i := 0; i < len(history.Redo) && err == nil; i++ {
+		text, err = replayEdits(text, history.Redo[i].Edits)
+	}
+	if err != nil {
+		return err
+	}
+
+	tb.undoStack.Clear()
+	nextID := history.SavedOperationID
+	for i := range history.Undo {
+		operation := cloneOperation(&history.Undo[i])
+		tb.undoStack.undoStack = append(tb.undoStack.undoStack, &operation)
+		nextID = max(nextID, operation.ID)
+	}
+	for i := len(history.Redo) - 1; i >= 0; i-- {
+		operation := cloneOperation(&history.Redo[i])
+		tb.undoStack.redoStack = append(tb.undoStack.redoStack, &operation)
+		nextID = max(nextID, operation.ID)
+	}
+	tb.undoStack.nextID = max(tb.undoStack.nextID, nextID)
+	tb.savedOperationID = history.SavedOperationID
+	tb.queueUndoStateChanged()
+	return nil
+}
+
+// replayEdits 在文本上按顺序应用编辑，检查每个编辑替换的文本是否一致
+func replayEdits(text []rune, edits []Edit) ([]rune, error) {
+	for _, edit := range edits {
+		oldLength := utf8.RuneCountInString(edit.OldText)
+		if edit.Offset < 0 || edit.Offset+oldLength > len(text) || string(text[edit.Offset:edit.Offset+oldLength]) != edit.OldText {
+			return nil, fmt.Errorf("%w: edit at offset %d", ErrUndoHistoryMismatch, edit.Offset)
+		}
+		text = append(text[:edit.Offset:edit.Offset], append([]rune(edit.NewText), text[edit.Offset+oldLength:]...)...)
+	}
+	return text, nil
+}
+
+// cloneOperation 复制一个操作，编辑和选区不与原操作共享
+func cloneOperation(operation *TextOperation) TextOperation {
+	clone := *operation
+	clone.Edits = append([]Edit(nil), operation.Edits...)
+	clone.BeforeSelections = cloneSelections(operation.BeforeSelections)
+	clone.AfterSelections = cloneSelections(operation.AfterSelections)
+	return clone
+}
@@ -2,6 +2,7 @@ package textbuffer
 
 import (
 	"errors"
+	"time"
 )
 
 // OperationType 表示文本操作的类型
@@ -16,16 +17,24 @@ const (
 	OperationReplace
 )
 
-// TextOperation 表示一个文本操作
+// TextOperation 表示一个文本操作，由一组按顺序应用的可逆编辑组成
 type TextOperation struct {
+	// ID 操作的唯一标识，推入撤销栈时分配
+	ID uint64
 	// 操作类型
 	Type OperationType
-	// 操作位置
-	Position Position
-	// 操作的文本
-	Text string
-	// 操作前的文本（用于撤销）
-	OldText string
+	// Label 操作的描述，用于在菜单和历史面板中显示
+	Label string
+	// Source 操作的来源
+	Source EditSource
+	// Timestamp 操作发生的时间
+	Timestamp time.Time
+	// 操作包含的编辑，撤销时按相反顺序应用它们的逆编辑
+	Edits []Edit
+	// 操作前的选区，撤销时恢复
+	BeforeSelections []Selection
+	// 操作后的选区，重做时恢复
+	AfterSelections []Selection
 }
 
 // UndoStack 是一个撤销/重做栈
@@ -36,6 +45,8 @@ type UndoStack struct {
 	redoStack []*TextOperation
 	// 最大栈大小
 	maxStackSize int
+	// 下一个操作的ID
+	nextID uint64
 }
 
 // NewUndoStack 创建一个新的UndoStack
@@ -52,6 +63,10 @@ func (us *UndoStack) Push(operation *TextOperation) {
 	// 清空重做栈
 	us.redoStack = []*TextOperation{}
 
+	// 分配操作ID
+	us.nextID++
+	operation.ID = us.nextID
+
 	// 将操作推入撤销栈
 	us.undoStack = append(us.undoStack, operation)
 
@@ -110,3 +125,74 @@ func (us *UndoStack) Clear() {
 	us.undoStack = []*TextOperation{}
 	us.redoStack = []*TextOperation{}
 }
+
+// PeekUndo 获取下一个将被撤销的操作，但不将其弹出
+func (us *UndoStack) PeekUndo() *TextOperation {
+	if len(us.undoStack) == 0 {
+		return nil
+	}
+	return us.undoStack[len(us.undoStack)-1]
+}
+
+// PeekRedo 获取下一个将被重做的操作，但不将其弹出
+func (us *UndoStack) PeekRedo() *TextOperation {
+	if len(us.redoStack) == 0 {
+		return nil
+	}
+	return us.redoStack[len(us.redoStack)-1]
+}
+
+// UndoOperations 获取撤销栈中的所有操作，按从旧到新的顺序排列
+func (us *UndoStack) UndoOperations() []*TextOperation {
+	result := make([]*TextOperation, len(us.undoStack))
+	copy(result, us.undoStack)
+	return result
+}
+
+// RedoOperations 获取重做栈中的所有操作，按下一个将被重做的操作在前的顺序排列
+func (us *UndoStack) RedoOperations() []*TextOperation {
+	result := make([]*TextOperation, len(us.redoStack))
+	for i, operation := range us.redoStack {
+		result[len(us.redoStack)-1-i] = operation
+	}
+	return result
+}
+
+// rebase 把撤销栈和重做栈中的操作变换到一个不记录在撤销栈中的编辑之后的文本上，
+// edit基于应用它之前的文本。撤销时只恢复这些操作修改的部分，保留edit的修改；
+// 与edit重叠的操作无法变换，它和依赖它的操作（更早的撤销、更晚的重做）被移除
+// 变换后的操作不再恢复记录的选区，跟踪的选区随编辑调整
+func (us *UndoStack) rebase(edit Edit) {
+	// 栈顶操作的逆编辑与edit基于同一个文本，逐个向下变换
+	b := edit
+	for i := len(us.undoStack) - 1; i >= 0; i-- {
+		inverse, next, ok := transformEdits(invertEdits(us.undoStack[i].Edits), b)
+		if !ok {
+			us.undoStack = us.undoStack[i+1:]
+			break
+		}
+		us.undoStack[i] = rebasedOperation(us.undoStack[i], invertEdits(inverse))
+		b = next
+	}
+
+	// 下一个重做的操作与edit基于同一个文本
+	b = edit
+	for i := len(us.redoStack) - 1; i >= 0; i-- {
+		edits, next, ok := transformEdits(us.redoStack[i].Edits, b)
+		if !ok {
+			us.redoStack = us.redoStack[i+1:]
+			break
+		}
+		us.redoStack[i] = rebasedOperation(us.redoStack[i], edits)
+		b = next
+	}
+}
+
+// rebasedOperation 复制操作并替换它的编辑，不修改可能被其他地方引用的原操作
+func rebasedOperation(operation *TextOperation, edits []Edit) *TextOperation {
+	result := *operation
+	result.Edits = edits
+	result.BeforeSelections = nil
+	result.AfterSelections = nil
+	return &result
+}
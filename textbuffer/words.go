@@ -0,0 +1,34 @@
+package textbuffer
+
+import (
+	"unicode/utf8"
+)
+
+// GetWordAtPosition 获取包含指定位置的单词的范围，位置紧挨在单词之后也算包含
+// 单词由语言配置的单词规则决定
+func (tb *TextBuffer) GetWordAtPosition(position Position) (Range, bool) {
+	tb.mutex.RLock()
+	defer tb.mutex.RUnlock()
+
+	if position.Line < 0 || position.Line >= tb.gapBuffer.GetLineCount() {
+		return Range{}, false
+	}
+
+	pattern := tb.getLanguageConfiguration().WordPattern
+	if pattern == nil {
+		pattern = DefaultWordPattern
+	}
+
+	text := tb.lineText(position.Line)
+	for _, match := range pattern.FindAllStringIndex(text, -1) {
+		start := utf8.RuneCountInString(text[:match[0]])
+		end := start + utf8.RuneCountInString(text[match[0]:match[1]])
+		if start <= position.Column && position.Column <= end && start < end {
+			return NewRange(
+				Position{Line: position.Line, Column: start},
+				Position{Line: position.Line, Column: end},
+			), true
+		}
+	}
+	return Range{}, false
+}